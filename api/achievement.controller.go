@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/db"
+	"github.com/xedom/codeduel/types"
+)
+
+func (s *Server) GetAchievementRouter() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /achievement", convertToHandleFunc(s.handleGetAchievements))
+	router.HandleFunc("POST /achievement", convertToHandleFunc(s.handleCreateAchievement, AuthMiddleware))
+	router.HandleFunc("PUT /achievement/{id}", convertToHandleFunc(s.handleUpdateAchievement, AuthMiddleware))
+	router.HandleFunc("DELETE /achievement/{id}", convertToHandleFunc(s.handleDeleteAchievement, AuthMiddleware))
+	return router
+}
+
+// @Summary		Get achievements
+// @Description	Get every achievement with its unlock rule
+// @Tags			achievement
+// @Produce		json
+// @Success		200	{object}	[]types.Achievement
+// @Failure		500	{object}	Error
+// @Router			/v1/achievement [get]
+func (s *Server) handleGetAchievements(w http.ResponseWriter, r *http.Request) error {
+	achievements, err := s.db.GetAchievements()
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, achievements)
+}
+
+// @Summary		Create achievement
+// @Description	Create an achievement, admin only. Users that already satisfy the rule unlock it with their next match
+// @Tags			achievement
+// @Accept			json
+// @Produce		json
+// @Param			achievement	body		types.AchievementRequest	true	"Achievement"
+// @Success		200			{object}	types.Achievement
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/achievement [post]
+func (s *Server) handleCreateAchievement(w http.ResponseWriter, r *http.Request) error {
+	achievement, errResponse := s.parseAchievementRequest(w, r)
+	if achievement == nil {
+		return errResponse
+	}
+
+	log.Print("[API] Creating achievement ", achievement.Slug)
+	if err := s.db.CreateAchievement(achievement); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, achievement)
+}
+
+// @Summary		Update achievement
+// @Description	Update an achievement, admin only. Users keep the achievements already unlocked
+// @Tags			achievement
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int							true	"Achievement id"
+// @Param			achievement	body		types.AchievementRequest	true	"Achievement"
+// @Success		200			{object}	types.Achievement
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/achievement/{id} [put]
+func (s *Server) handleUpdateAchievement(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid achievement id"})
+	}
+
+	achievement, errResponse := s.parseAchievementRequest(w, r)
+	if achievement == nil {
+		return errResponse
+	}
+	achievement.Id = id
+
+	log.Print("[API] Updating achievement ", achievement.Slug)
+	if err := s.db.UpdateAchievement(achievement); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, achievement)
+}
+
+// @Summary		Delete achievement
+// @Description	Delete an achievement and revoke it from every user, admin only
+// @Tags			achievement
+// @Param			id	path	int	true	"Achievement id"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/achievement/{id} [delete]
+func (s *Server) handleDeleteAchievement(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if authUser.Role != types.UserRoleAdmin {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid achievement id"})
+	}
+
+	log.Print("[API] Deleting achievement ", id)
+	if err := s.db.DeleteAchievement(id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// parseAchievementRequest checks that the user is an admin and validates the body,
+// when it returns a nil achievement the response has already been written
+func (s *Server) parseAchievementRequest(w http.ResponseWriter, r *http.Request) (*types.Achievement, error) {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return nil, WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if authUser.Role != types.UserRoleAdmin {
+		return nil, WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	body := &types.AchievementRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+
+	if body.Slug == "" || body.Name == "" {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "slug and name are required"})
+	}
+	if !db.IsAchievementMetric(body.Rule.Metric) {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "unknown rule metric"})
+	}
+	if body.Rule.Threshold < 1 {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "rule threshold must be at least 1"})
+	}
+	if body.Rule.Metric == types.AchievementMetricFastSolves && body.Rule.MaxSeconds < 1 {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "fast_solves rules require max_seconds"})
+	}
+	if body.Rule.Metric == types.AchievementMetricAuthoredChallenges && (body.Rule.Mode != "" || body.Rule.Language != "") {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "authored_challenges rules can't be filtered by mode or language"})
+	}
+
+	return &types.Achievement{
+		Slug:        body.Slug,
+		Name:        body.Name,
+		Description: body.Description,
+		Icon:        body.Icon,
+		Rule:        body.Rule,
+	}, nil
+}
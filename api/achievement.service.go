@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log"
+
+	"github.com/xedom/codeduel/db"
+	"github.com/xedom/codeduel/utils"
+)
+
+// onAchievementEvent re-evaluates the locked achievements of the users touched by the event
+func (s *Server) onAchievementEvent(event *Event) {
+	userIds := event.UserIds
+
+	// the plays of a challenge, and its publication, count towards the achievements of its author
+	if (event.Type == EventMatchEnded || event.Type == EventChallengeUpdated) && event.ChallengeId != 0 {
+		ownerId, err := s.db.GetChallengeOwnerID(event.ChallengeId)
+		if err != nil {
+			log.Printf("%s%s %s", utils.GetLogTag("achievement"), utils.GetLogTag("error"), err.Error())
+		} else {
+			userIds = append(userIds, ownerId)
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, userId := range userIds {
+		if seen[userId] {
+			continue
+		}
+		seen[userId] = true
+
+		if err := EvaluateAchievements(s.db, userId); err != nil {
+			log.Printf("%s%s failed to evaluate achievements of user %d: %s", utils.GetLogTag("achievement"), utils.GetLogTag("error"), userId, err.Error())
+		}
+	}
+}
+
+// EvaluateAchievements unlocks every achievement whose rule is satisfied by the user
+func EvaluateAchievements(database db.DB, userId int) error {
+	achievements, err := database.GetLockedAchievements(userId)
+	if err != nil {
+		return err
+	}
+
+	for _, achievement := range achievements {
+		progress, err := database.GetAchievementProgress(userId, &achievement.Rule)
+		if err != nil {
+			return err
+		}
+		if progress < achievement.Rule.Threshold {
+			continue
+		}
+
+		unlocked, err := database.UnlockAchievement(userId, achievement.Id)
+		if err != nil {
+			return err
+		}
+		if unlocked {
+			log.Printf("%s user %d unlocked %s", utils.GetLogTag("achievement"), userId, achievement.Slug)
+		}
+	}
+
+	return nil
+}
@@ -28,6 +28,14 @@ type Server struct {
 	config  *utils.Config
 	address string
 	db      db.DB
+	events  *EventBus
+	clock   utils.Clock
+	mailer  utils.Mailer
+
+	analytics *analyticsCache
+
+	search      db.ChallengeSearcher
+	searchIndex *db.MemoryChallengeIndex // nil when the search runs on MariaDB
 }
 
 type Error struct {
@@ -47,11 +55,41 @@ func (handler Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func NewAPIServer(config *utils.Config, db db.DB) *Server {
-	return &Server{
-		config:  config,
-		db:      db,
-		address: fmt.Sprintf("%s:%s", config.Host, config.Port),
+func NewAPIServer(config *utils.Config, database db.DB) *Server {
+	server := &Server{
+		config:    config,
+		db:        database,
+		events:    NewEventBus(),
+		clock:     utils.SystemClock{},
+		mailer:    utils.NewMailer(config),
+		analytics: newAnalyticsCache(),
+		address:   fmt.Sprintf("%s:%s", config.Host, config.Port),
+	}
+	server.search = database
+	if config.SearchBackend == types.SearchBackendMemory {
+		server.searchIndex = db.NewMemoryChallengeIndex()
+		server.search = server.searchIndex
+	}
+	server.subscribeEventHandlers()
+
+	return server
+}
+
+// subscribeEventHandlers registers the reactions to the domain events
+func (s *Server) subscribeEventHandlers() {
+	for _, eventType := range []string{EventMatchEnded, EventChallengeCreated, EventChallengeUpdated, EventStatsUpdated} {
+		s.events.Subscribe(eventType, s.onAchievementEvent)
+	}
+	for _, eventType := range []string{EventLobbyCreated, EventUserFollowed, EventMatchEnded, EventChallengeApproved, EventChallengeRejected} {
+		s.events.Subscribe(eventType, s.onNotificationEvent)
+	}
+	s.events.Subscribe(EventMatchEnded, s.onDailyEvent)
+	s.events.Subscribe(EventMatchEnded, s.onAnalyticsEvent)
+	s.events.Subscribe(EventMatchEnded, s.onChallengeEvent)
+	if s.searchIndex != nil {
+		for _, eventType := range []string{EventChallengeCreated, EventChallengeUpdated, EventChallengeDeleted} {
+			s.events.Subscribe(eventType, s.onSearchIndexEvent)
+		}
 	}
 }
 
@@ -87,6 +125,14 @@ func (s *Server) Run() error {
 	v1.Handle("/lobby/", s.GetLobbyRouter())
 	v1.Handle("/challenge", s.GetChallengeRouter())
 	v1.Handle("/challenge/", s.GetChallengeRouter())
+	v1.Handle("/leaderboard", s.GetLeaderboardRouter())
+	v1.Handle("/leaderboard/", s.GetLeaderboardRouter())
+	v1.Handle("/daily", s.GetDailyRouter())
+	v1.Handle("/daily/", s.GetDailyRouter())
+	v1.Handle("/tag", s.GetTagRouter())
+	v1.Handle("/tag/", s.GetTagRouter())
+	v1.Handle("/achievement", s.GetAchievementRouter())
+	v1.Handle("/achievement/", s.GetAchievementRouter())
 	v1.Handle("/auth/github", s.GetGithubAuthRouter())
 	v1.Handle("/auth/github/", s.GetGithubAuthRouter())
 	v1.Handle("POST /auth/validate_token", convertToHandleFunc(s.handleValidateToken))
@@ -99,6 +145,12 @@ func (s *Server) Run() error {
 	main.HandleFunc("/docs/", httpSwagger.Handler())
 	main.Handle("/v1/", http.StripPrefix("/v1", v1))
 
+	if s.searchIndex != nil {
+		s.rebuildSearchIndex()
+	}
+	go s.runLeaderboardRefresher()
+	go s.runDailyPublisher()
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -2,34 +2,115 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
 )
 
+const maxChallengesPage = 100
+
 func (s *Server) GetChallengeRouter() http.Handler {
 	router := http.NewServeMux()
-	router.HandleFunc("GET /challenge", convertToHandleFunc(s.handleGetChallenges))
+	router.HandleFunc("GET /challenge", convertToHandleFunc(s.handleGetChallenges, OptionalAuthMiddleware))
 	router.HandleFunc("POST /challenge", convertToHandleFunc(s.handleCreateChallenge, AuthMiddleware))
-	router.HandleFunc("GET /challenge/{id}", convertToHandleFunc(s.handleGetChallengeByID))
-	router.HandleFunc("GET /challenge/random/full", convertToHandleFunc(s.handleGetRandomChallengeFull)) // TODO: add AuthMiddleware
-	router.HandleFunc("GET /challenge/{id}/full", convertToHandleFunc(s.handleGetChallengeByIDFull, AuthMiddleware))
+	router.HandleFunc("POST /challenge/import", convertToHandleFunc(s.handleImportChallenge, AuthMiddleware))
+	router.HandleFunc("GET /challenge/search", convertToHandleFunc(s.handleSearchChallenges))
+	router.HandleFunc("GET /challenge/queue", convertToHandleFunc(s.handleGetChallengeReviewQueue, AuthMiddleware))
+	router.HandleFunc("GET /challenge/{id}", convertToHandleFunc(s.handleGetChallengeByID, OptionalAuthMiddleware))
+	router.HandleFunc("GET /challenge/random/full", convertToHandleFunc(s.handleGetRandomChallengeFull))
+	router.HandleFunc("GET /challenge/{id}/full", convertToHandleFunc(s.handleGetChallengeByIDFull, OptionalAuthMiddleware))
 	router.HandleFunc("PUT /challenge/{id}", convertToHandleFunc(s.handleUpdateChallenge, AuthMiddleware))
 	router.HandleFunc("DELETE /challenge/{id}", convertToHandleFunc(s.handleDeleteChallenge, AuthMiddleware))
+
+	router.HandleFunc("GET /challenge/{id}/export", convertToHandleFunc(s.handleExportChallenge, AuthMiddleware))
+	router.HandleFunc("POST /challenge/{id}/import", convertToHandleFunc(s.handleImportChallengeUpdate, AuthMiddleware))
+
+	router.HandleFunc("PUT /challenge/{id}/status", convertToHandleFunc(s.handleSetChallengeStatus, AuthMiddleware))
+	router.HandleFunc("GET /challenge/{id}/reviews", convertToHandleFunc(s.handleGetChallengeReviews, AuthMiddleware))
+
+	router.HandleFunc("GET /challenge/{id}/tests", convertToHandleFunc(s.handleGetChallengeTests, OptionalAuthMiddleware))
+	router.HandleFunc("PUT /challenge/{id}/tests/{visibility}", convertToHandleFunc(s.handleReplaceChallengeTests, AuthMiddleware))
+	router.HandleFunc("POST /challenge/{id}/tests/{visibility}", convertToHandleFunc(s.handleAddChallengeTest, AuthMiddleware))
+	router.HandleFunc("PUT /challenge/{id}/tests/{visibility}/order", convertToHandleFunc(s.handleReorderChallengeTests, AuthMiddleware))
+	router.HandleFunc("PUT /challenge/{id}/tests/{visibility}/{index}", convertToHandleFunc(s.handleUpdateChallengeTest, AuthMiddleware))
+	router.HandleFunc("DELETE /challenge/{id}/tests/{visibility}/{index}", convertToHandleFunc(s.handleDeleteChallengeTest, AuthMiddleware))
+
+	router.HandleFunc("PUT /challenge/{id}/tags", convertToHandleFunc(s.handleSetChallengeTags, AuthMiddleware))
+
+	router.HandleFunc("GET /challenge/{id}/templates", convertToHandleFunc(s.handleGetChallengeTemplates, OptionalAuthMiddleware))
+	router.HandleFunc("PUT /challenge/{id}/templates/{language}", convertToHandleFunc(s.handleSetChallengeTemplate, AuthMiddleware))
+	router.HandleFunc("DELETE /challenge/{id}/templates/{language}", convertToHandleFunc(s.handleDeleteChallengeTemplate, AuthMiddleware))
+
+	router.HandleFunc("GET /challenge/{id}/rating", convertToHandleFunc(s.handleGetChallengeRating, OptionalAuthMiddleware))
+	router.HandleFunc("PUT /challenge/{id}/rating", convertToHandleFunc(s.handleRateChallenge, AuthMiddleware))
+
+	router.HandleFunc("GET /challenge/{id}/revisions", convertToHandleFunc(s.handleGetChallengeRevisions, OptionalAuthMiddleware))
+	router.HandleFunc("GET /challenge/{id}/revisions/{revision}", convertToHandleFunc(s.handleGetChallengeRevision, OptionalAuthMiddleware))
+	router.HandleFunc("POST /challenge/{id}/revisions/{revision}/rollback", convertToHandleFunc(s.handleRollbackChallenge, AuthMiddleware))
 	return router
 }
 
 // @Summary		Get all challenges
-// @Description	Get all challenges
+// @Description	Get a page of challenge summaries, without content and tests, optionally filtered by tags and difficulty.
+// @Description	Pass the `next_cursor` of a page to get the next one, with the same sort and filters.
+// @Description	Only published challenges are listed, unless a moderator or the owner asks for another status
 // @Tags			challenge
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	types.ChallengeListResponse
+// @Param			tag			query		string	false	"Tag slug, repeat it to require several tags"
+// @Param			difficulty	query		string	false	"easy, medium or hard. The computed difficulty, the author's one until enough matches were played"
+// @Param			owner		query		int		false	"Owner ID"
+// @Param			status		query		string	false	"draft, submitted, approved, rejected or published (default)"
+// @Param			sort		query		string	false	"newest (default), most_played or highest_rated"
+// @Param			cursor		query		string	false	"Cursor of the next page"
+// @Param			limit		query		int		false	"Page size, 20 by default, at most 100"
+// @Success		200			{object}	types.ChallengeListResponse
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
 // @Router			/v1/challenge [get]
-func (s *Server) handleGetChallenges(w http.ResponseWriter, _ *http.Request) error {
-	challenges, err := s.db.GetChallenges()
+func (s *Server) handleGetChallenges(w http.ResponseWriter, r *http.Request) error {
+	urlQuery := r.URL.Query()
+	filter, err := parseChallengeFilter(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	if owner := urlQuery.Get("owner"); owner != "" {
+		if filter.OwnerId, err = strconv.Atoi(owner); err != nil {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid owner id"})
+		}
+	}
+	if status := urlQuery.Get("status"); status != "" && status != filter.Status {
+		if !types.IsChallengeStatus(status) {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("invalid status %q", status)})
+		}
+		// unpublished challenges are only listed to their owner and the moderators
+		authUser := GetAuthUser(r)
+		if authUser == nil || (!types.IsModeratorRole(authUser.Role) && filter.OwnerId != authUser.Id) {
+			return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+		}
+		filter.Status = status
+	}
+
+	sort := urlQuery.Get("sort")
+	if sort != "" && !types.IsChallengeSort(sort) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("invalid sort %q", sort)})
+	}
+	if _, err := utils.DecodeCursor(urlQuery.Get("cursor")); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	challenges, err := s.db.GetChallengeSummaries(&types.ChallengeListQuery{
+		Filter: *filter,
+		Sort:   sort,
+		Cursor: urlQuery.Get("cursor"),
+		Limit:  utils.ParseLimit(urlQuery.Get("limit"), 20, maxChallengesPage),
+	})
 	if err != nil {
 		return err
 	}
@@ -38,12 +119,14 @@ func (s *Server) handleGetChallenges(w http.ResponseWriter, _ *http.Request) err
 }
 
 // @Summary		Create a new challenge
-// @Description	Create a new challenge
+// @Description	Create a new challenge as a draft, it can be played once reviewed and published
 // @Tags			challenge
 // @Accept			json
 // @Produce		json
 // @Param			challenge	body		types.CreateChallengeRequest	true	"Create Challenge Request"
 // @Success		200			{object}	types.ChallengeResponse
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
 // @Router			/v1/challenge [post]
 func (s *Server) handleCreateChallenge(w http.ResponseWriter, r *http.Request) error {
 	createChallengeReq := &types.CreateChallengeRequest{}
@@ -56,6 +139,26 @@ func (s *Server) handleCreateChallenge(w http.ResponseWriter, r *http.Request) e
 		return WriteJSON(w, http.StatusUnauthorized, "")
 	}
 
+	owner, err := s.db.GetUserByID(user.Id)
+	if err != nil {
+		return err
+	}
+	if !owner.EmailVerified {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Verify your email address to create challenges"})
+	}
+
+	for _, testCases := range [][]types.TestCase{createChallengeReq.TestCases, createChallengeReq.HiddenTestCases} {
+		if err := types.ValidateTestCases(testCases); err != nil {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+		}
+	}
+	if createChallengeReq.Difficulty == "" {
+		createChallengeReq.Difficulty = types.DifficultyMedium
+	}
+	if !types.IsChallengeDifficulty(createChallengeReq.Difficulty) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "difficulty must be easy, medium or hard"})
+	}
+
 	log.Print("[API] Creating new challenge ", createChallengeReq)
 
 	challenge := &types.Challenge{
@@ -63,11 +166,17 @@ func (s *Server) handleCreateChallenge(w http.ResponseWriter, r *http.Request) e
 		Title:       createChallengeReq.Title,
 		Description: createChallengeReq.Description,
 		Content:     createChallengeReq.Content,
+		Difficulty:  createChallengeReq.Difficulty,
+		Tags:        []string{},
+
+		TestCases:       createChallengeReq.TestCases,
+		HiddenTestCases: createChallengeReq.HiddenTestCases,
 	}
 
 	if err := s.db.CreateChallenge(challenge); err != nil {
 		return err
 	}
+	s.events.Publish(&Event{Type: EventChallengeCreated, UserIds: []int{user.Id}, ChallengeId: challenge.Id})
 
 	return WriteJSON(w, http.StatusOK, challenge)
 }
@@ -79,6 +188,7 @@ func (s *Server) handleCreateChallenge(w http.ResponseWriter, r *http.Request) e
 // @Produce		json
 // @Param			id	path		int	true	"Challenge ID"
 // @Success		200	{object}	types.Challenge
+// @Failure		404	{object}	Error
 // @Router			/v1/challenge/{id} [get]
 func (s *Server) handleGetChallengeByID(w http.ResponseWriter, r *http.Request) error {
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -86,6 +196,11 @@ func (s *Server) handleGetChallengeByID(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
 	log.Print("[API] Fetching challenge ", id)
 	challenge, err := s.db.GetChallengeByID(id)
 	if err != nil {
@@ -96,55 +211,94 @@ func (s *Server) handleGetChallengeByID(w http.ResponseWriter, r *http.Request)
 }
 
 // @Summary		Get challenge by ID with full details
-// @Description	Get challenge by ID with full details
+// @Description	Get challenge by ID with full details, the hidden tests are included for the owner, admins and the lobby service.
+// @Description	With `language` the template of the language is included, when the author wrote one
 // @Tags			challenge
 // @Accept			json
 // @Produce		json
-// @Param			id	path		int	true	"Challenge ID"
-// @Success		200	{object}	types.ChallengeFull
+// @Param			id			path		int		true	"Challenge ID"
+// @Param			language	query		string	false	"Language name"
+// @Success		200			{object}	types.ChallengeFull
+// @Failure		400			{object}	Error
+// @Failure		401			{object}	Error
+// @Failure		404			{object}	Error
 // @Router			/v1/challenge/{id}/full [get]
 func (s *Server) handleGetChallengeByIDFull(w http.ResponseWriter, r *http.Request) error {
-	// TODO check if the request is from the lobby service
+	if GetAuthUser(r) == nil && !IsInternalServiceRequest(s.config, r) {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		return err
 	}
 
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
 	log.Print("[API] Fetching challenge ", id)
 	challenge, err := s.db.GetChallengeByIDFull(id)
 	if err != nil {
 		return err
 	}
 
+	canViewHidden, err := s.canViewHiddenTests(r, id)
+	if err != nil {
+		return err
+	}
+	if !canViewHidden {
+		challenge.HiddenTestCases = nil
+	}
+	if ok, err := s.attachChallengeTemplate(w, r, challenge); !ok {
+		return err
+	}
+
 	return WriteJSON(w, http.StatusOK, challenge)
 }
 
 // @Summary		Get random challenge with full details
-// @Description	Get random challenge with full details
+// @Description	Get random published challenge with full details, picked within the tags and difficulty when given. The hidden tests are only included for the lobby service.
+// @Description	With `language` the template of the language is included, when the author wrote one
 // @Tags			challenge
 // @Accept			json
 // @Produce		json
-// @Success		200	{object}	types.ChallengeFull
+// @Param			tag			query		string	false	"Tag slug, repeat it to require several tags"
+// @Param			difficulty	query		string	false	"easy, medium or hard"
+// @Param			language	query		string	false	"Language name"
+// @Success		200			{object}	types.ChallengeFull
+// @Failure		400			{object}	Error
 // @Router			/v1/challenge/random/full [get]
-func (s *Server) handleGetRandomChallengeFull(w http.ResponseWriter, _ *http.Request) error {
-	// TODO check if the request is from the lobby service
+func (s *Server) handleGetRandomChallengeFull(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseChallengeFilter(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
 
-	challenge, err := s.db.GetRandomChallengeFull()
+	challenge, err := s.db.GetRandomChallengeFull(filter)
 	if err != nil {
 		return err
 	}
+	if !IsInternalServiceRequest(s.config, r) {
+		challenge.HiddenTestCases = nil
+	}
+	if ok, err := s.attachChallengeTemplate(w, r, challenge); !ok {
+		return err
+	}
 
 	return WriteJSON(w, http.StatusOK, challenge)
 }
 
 // @Summary		Update challenge by ID
-// @Description	Update challenge by ID
+// @Description	Update challenge by ID, every change is saved as a new revision
 // @Tags			challenge
 // @Accept			json
 // @Produce		json
 // @Param			id			path	int								true	"Challenge ID"
 // @Param			challenge	body	types.UpdateChallengeRequest	true	"Update Challenge Request"
 // @Success		200
+// @Failure		400	{object}	Error
 // @Router			/v1/challenge/{id} [put]
 func (s *Server) handleUpdateChallenge(w http.ResponseWriter, r *http.Request) error {
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -179,8 +333,28 @@ func (s *Server) handleUpdateChallenge(w http.ResponseWriter, r *http.Request) e
 		Description: updateChallengeReq.Description,
 		Content:     updateChallengeReq.Content,
 	}
+	if updateChallengeReq.Difficulty != "" && !types.IsChallengeDifficulty(updateChallengeReq.Difficulty) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "difficulty must be easy, medium or hard"})
+	}
+	challenge.Difficulty = updateChallengeReq.Difficulty
+	if updateChallengeReq.TestCases != nil {
+		challenge.TestCases = append([]types.TestCase{}, *updateChallengeReq.TestCases...)
+	}
+	if updateChallengeReq.HiddenTestCases != nil {
+		challenge.HiddenTestCases = append([]types.TestCase{}, *updateChallengeReq.HiddenTestCases...)
+	}
+	for _, testCases := range [][]types.TestCase{challenge.TestCases, challenge.HiddenTestCases} {
+		if err := types.ValidateTestCases(testCases); err != nil {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+		}
+	}
 
-	return s.db.UpdateChallenge(challenge)
+	if err := s.db.UpdateChallenge(challenge, user.Id); err != nil {
+		return err
+	}
+
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: user.Id, ChallengeId: id})
+	return nil
 }
 
 // @Summary		Delete challenge by ID
@@ -214,5 +388,30 @@ func (s *Server) handleDeleteChallenge(w http.ResponseWriter, r *http.Request) e
 	}
 
 	log.Print("[API] Deleting challenge ", id)
-	return s.db.DeleteChallenge(id)
+	if err := s.db.DeleteChallenge(id); err != nil {
+		return err
+	}
+
+	s.events.Publish(&Event{Type: EventChallengeDeleted, ActorId: user.Id, ChallengeId: id})
+	return nil
+}
+
+// parseChallengeFilter reads the `tag` and `difficulty` query parameters, only published challenges match
+func parseChallengeFilter(r *http.Request) (*types.ChallengeFilter, error) {
+	urlQuery := r.URL.Query()
+	filter := &types.ChallengeFilter{
+		Tags:       []string{},
+		Difficulty: urlQuery.Get("difficulty"),
+		Status:     types.ChallengeStatusPublished,
+	}
+	if filter.Difficulty != "" && !types.IsChallengeDifficulty(filter.Difficulty) {
+		return nil, fmt.Errorf("invalid difficulty %q", filter.Difficulty)
+	}
+	for _, tag := range urlQuery["tag"] {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	return filter, nil
 }
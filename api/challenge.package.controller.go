@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/challengepkg"
+)
+
+// @Summary		Export challenge
+// @Description	Download a challenge as a ZIP package: challenge.yaml, statement.md, tests/ and hidden/. Owner or admin only
+// @Tags			challenge
+// @Produce		application/zip
+// @Param			id	path	int	true	"Challenge ID"
+// @Success		200	{file}	binary
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/export [get]
+func (s *Server) handleExportChallenge(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canManage, err := s.canManageChallenge(authUser, id)
+	if err != nil {
+		return err
+	}
+	if !canManage {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	challenge, err := s.db.GetChallengeByIDFull(id)
+	if err != nil {
+		return err
+	}
+	templates, err := s.db.GetChallengeTemplates(id)
+	if err != nil {
+		return err
+	}
+
+	archive := &bytes.Buffer{}
+	if err := challengepkg.FromChallenge(challenge, templates).WriteZip(archive); err != nil {
+		return err
+	}
+
+	log.Printf("[API] Exporting challenge %d", id)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="challenge-%d.zip"`, id))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(archive.Bytes())
+	return err
+}
+
+// @Summary		Import challenge
+// @Description	Create a challenge, as a draft, from a ZIP package. With `dry_run` the package is only validated and the diff returned.
+// @Description	With `format` the body is a problem of another platform, a Polygon or Kattis ZIP package or a LeetCode JSON question, converted and reported
+// @Tags			challenge
+// @Accept			application/zip
+// @Accept			json
+// @Produce		json
+// @Param			dry_run	query		bool	false	"Validate and diff without saving"
+// @Param			format	query		string	false	"Format of the body"	Enums(codeduel, polygon, kattis, leetcode)
+// @Success		200		{object}	types.ChallengeImportResult
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/import [post]
+func (s *Server) handleImportChallenge(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	owner, err := s.db.GetUserByID(authUser.Id)
+	if err != nil {
+		return err
+	}
+	if !owner.EmailVerified {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Verify your email address to create challenges"})
+	}
+
+	return s.importChallengePackage(w, r, challengepkg.ImportOptions{AuthorId: authUser.Id})
+}
+
+// @Summary		Import challenge update
+// @Description	Update a challenge from a ZIP package, the changes are saved as a new revision. Owner or admin only.
+// @Description	With `dry_run` the package is only validated and the diff from the current challenge returned.
+// @Description	With `format` the body is a problem of another platform, a Polygon or Kattis ZIP package or a LeetCode JSON question, converted and reported
+// @Tags			challenge
+// @Accept			application/zip
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int		true	"Challenge ID"
+// @Param			dry_run	query		bool	false	"Validate and diff without saving"
+// @Param			format	query		string	false	"Format of the body"	Enums(codeduel, polygon, kattis, leetcode)
+// @Success		200		{object}	types.ChallengeImportResult
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/{id}/import [post]
+func (s *Server) handleImportChallengeUpdate(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canManage, err := s.canManageChallenge(authUser, id)
+	if err != nil {
+		return err
+	}
+	if !canManage {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	return s.importChallengePackage(w, r, challengepkg.ImportOptions{ChallengeId: id, AuthorId: authUser.Id})
+}
+
+func (s *Server) importChallengePackage(w http.ResponseWriter, r *http.Request, options challengepkg.ImportOptions) error {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = challengepkg.FormatCodeDuel
+	}
+	if format != challengepkg.FormatCodeDuel && !challengepkg.IsConvertibleFormat(format) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "format must be codeduel, polygon, kattis or leetcode"})
+	}
+
+	maxSize := challengepkg.MaxSize
+	if format != challengepkg.FormatCodeDuel {
+		maxSize = challengepkg.MaxConvertSize
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxSize)))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("the package can't be larger than %d bytes", maxSize)})
+	}
+
+	var pkg *challengepkg.Package
+	if format == challengepkg.FormatCodeDuel {
+		pkg, err = challengepkg.ReadZip(data)
+	} else {
+		pkg, options.Conversion, err = challengepkg.Convert(format, data)
+	}
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	options.DryRun = r.URL.Query().Get("dry_run") == "true"
+	result, err := challengepkg.Import(s.db, pkg, options)
+	var validationErr *challengepkg.ValidationError
+	if errors.As(err, &validationErr) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: validationErr.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.DryRun && len(result.Diff) > 0 {
+		log.Printf("[API] Imported challenge %d", result.ChallengeId)
+		if result.Created {
+			s.events.Publish(&Event{Type: EventChallengeCreated, UserIds: []int{options.AuthorId}, ChallengeId: result.ChallengeId})
+		} else {
+			s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: options.AuthorId, ChallengeId: result.ChallengeId})
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get challenge rating
+// @Description	Get the average rating of a challenge, with the one given by the authenticated user
+// @Tags			challenge
+// @Produce		json
+// @Param			id	path		int	true	"Challenge ID"
+// @Success		200	{object}	types.ChallengeRating
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/rating [get]
+func (s *Server) handleGetChallengeRating(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	userId := 0
+	if authUser := GetAuthUser(r); authUser != nil {
+		userId = authUser.Id
+	}
+
+	rating, err := s.db.GetChallengeRating(id, userId)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, rating)
+}
+
+// @Summary		Rate challenge
+// @Description	Rate a challenge from 1 to 5, replacing the previous rating. Only players that finished a match on it can rate it, the owner can't
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int							true	"Challenge ID"
+// @Param			rating	body		types.RateChallengeRequest	true	"Rating"
+// @Success		200		{object}	types.ChallengeRating
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		404		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/{id}/rating [put]
+func (s *Server) handleRateChallenge(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	body := &types.RateChallengeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid rating"})
+	}
+	if body.Rating < types.MinChallengeRating || body.Rating > types.MaxChallengeRating {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("rating must be between %d and %d", types.MinChallengeRating, types.MaxChallengeRating)})
+	}
+
+	ownerId, err := s.db.GetChallengeOwnerID(id)
+	if err != nil {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+	if ownerId == authUser.Id {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "you can't rate your own challenge"})
+	}
+
+	log.Printf("[API] User %d rating challenge %d: %d", authUser.Id, id, body.Rating)
+	rated, err := s.db.RateChallenge(id, authUser.Id, body.Rating)
+	if err != nil {
+		return err
+	}
+	if !rated {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "only players that finished a match on the challenge can rate it"})
+	}
+
+	rating, err := s.db.GetChallengeRating(id, authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, rating)
+}
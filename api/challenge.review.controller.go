@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+const maxReviewCommentLength = 2000
+
+// @Summary		Change challenge status
+// @Description	Move a challenge through the review workflow: draft → submitted → approved/rejected → published.
+// @Description	Moderators approve or reject the submitted challenges of the others, a comment is required to reject.
+// @Description	The owner (or a moderator) submits, withdraws, publishes and unpublishes. Only published challenges can be played.
+// @Description	Editing the content, tests or templates of a submitted, approved or published challenge sends it back to draft, unless a moderator does it
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int								true	"Challenge ID"
+// @Param			status	body		types.SetChallengeStatusRequest	true	"New status"
+// @Success		200		{object}	types.ChallengeReview
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		404		{object}	Error
+// @Failure		409		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/{id}/status [put]
+func (s *Server) handleSetChallengeStatus(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	body := &types.SetChallengeStatusRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+	body.Comment = strings.TrimSpace(body.Comment)
+	if !types.IsChallengeStatus(body.Status) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("invalid status %q", body.Status)})
+	}
+	if len(body.Comment) > maxReviewCommentLength {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("comment can't be longer than %d characters", maxReviewCommentLength)})
+	}
+	if body.Status == types.ChallengeStatusRejected && body.Comment == "" {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "a comment is required to reject a challenge"})
+	}
+
+	ownerId, err := s.db.GetChallengeOwnerID(id)
+	if err != nil {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+	status, err := s.db.GetChallengeStatus(id)
+	if err != nil {
+		return err
+	}
+
+	isModerator := types.IsModeratorRole(authUser.Role)
+	if types.IsChallengeReviewDecision(body.Status) {
+		// nobody reviews their own challenges
+		if !isModerator || ownerId == authUser.Id {
+			return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+		}
+	} else if !isModerator && ownerId != authUser.Id {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+	if !types.CanTransitionChallenge(status, body.Status) {
+		return WriteJSON(w, http.StatusConflict, Error{Err: fmt.Sprintf("a %s challenge can't become %s", status, body.Status)})
+	}
+
+	log.Printf("[API] Moving challenge %d from %s to %s", id, status, body.Status)
+	review, err := s.db.SetChallengeStatus(id, authUser.Id, status, body.Status, body.Comment)
+	if err != nil {
+		return WriteJSON(w, http.StatusConflict, Error{Err: "the challenge status changed in the meantime"})
+	}
+
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id, ChallengeId: id})
+	switch body.Status {
+	case types.ChallengeStatusApproved:
+		s.events.Publish(&Event{Type: EventChallengeApproved, UserIds: []int{ownerId}, ActorId: authUser.Id, ChallengeId: id})
+	case types.ChallengeStatusRejected:
+		s.events.Publish(&Event{Type: EventChallengeRejected, UserIds: []int{ownerId}, ActorId: authUser.Id, ChallengeId: id})
+	}
+
+	return WriteJSON(w, http.StatusOK, review)
+}
+
+// @Summary		Get challenge reviews
+// @Description	Get the status changes of a challenge with the reviewers' comments, newest first. Owner and moderators only
+// @Tags			challenge
+// @Produce		json
+// @Param			id	path		int	true	"Challenge ID"
+// @Success		200	{object}	[]types.ChallengeReview
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/reviews [get]
+func (s *Server) handleGetChallengeReviews(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	if !types.IsModeratorRole(authUser.Role) {
+		ownerId, err := s.db.GetChallengeOwnerID(id)
+		if err != nil {
+			return err
+		}
+		if ownerId != authUser.Id {
+			return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+		}
+	}
+
+	reviews, err := s.db.GetChallengeReviews(id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, reviews)
+}
+
+// @Summary		Get review queue
+// @Description	Get a page of the challenges waiting for a review, the ones submitted first come first. Moderators only
+// @Tags			challenge
+// @Produce		json
+// @Param			cursor	query		string	false	"Cursor of the next page"
+// @Param			limit	query		int		false	"Page size, 20 by default, at most 100"
+// @Success		200		{object}	types.ChallengeListResponse
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/queue [get]
+func (s *Server) handleGetChallengeReviewQueue(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if !types.IsModeratorRole(authUser.Role) {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	urlQuery := r.URL.Query()
+	if _, err := utils.DecodeCursor(urlQuery.Get("cursor")); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	queue, err := s.db.GetChallengeReviewQueue(urlQuery.Get("cursor"), utils.ParseLimit(urlQuery.Get("limit"), 20, maxChallengesPage))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, queue)
+}
+
+// canViewChallenge hides the challenges that aren't published yet to everyone but their owner,
+// the moderators and the lobby service
+func (s *Server) canViewChallenge(r *http.Request, challengeId int) (bool, error) {
+	status, err := s.db.GetChallengeStatus(challengeId)
+	if err != nil || status == "" {
+		return false, err
+	}
+	if status == types.ChallengeStatusPublished || IsInternalServiceRequest(s.config, r) {
+		return true, nil
+	}
+
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return false, nil
+	}
+	if types.IsModeratorRole(authUser.Role) {
+		return true, nil
+	}
+	return s.canManageChallenge(authUser, challengeId)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get challenge revisions
+// @Description	Get the history of a challenge, newest first
+// @Tags			challenge
+// @Produce		json
+// @Param			id	path		int	true	"Challenge ID"
+// @Success		200	{object}	[]types.ChallengeRevisionSummary
+// @Failure		400	{object}	Error
+// @Failure		404	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/revisions [get]
+func (s *Server) handleGetChallengeRevisions(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
+	revisions, err := s.db.GetChallengeRevisions(id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, revisions)
+}
+
+// @Summary		Get challenge revision
+// @Description	Get the content of a challenge at a revision, its templates included, and its diff from the previous one.
+// @Description	The hidden tests and their diff are included for the owner, admins and the lobby service
+// @Tags			challenge
+// @Produce		json
+// @Param			id			path		int	true	"Challenge ID"
+// @Param			revision	path		int	true	"Revision number"
+// @Success		200			{object}	types.ChallengeRevision
+// @Failure		400			{object}	Error
+// @Failure		404			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/revisions/{revision} [get]
+func (s *Server) handleGetChallengeRevision(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+	number, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid revision"})
+	}
+
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
+	revision, err := s.db.GetChallengeRevision(id, number)
+	if err != nil {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Revision not found"})
+	}
+
+	canViewHidden, err := s.canViewHiddenTests(r, id)
+	if err != nil {
+		return err
+	}
+	if !canViewHidden {
+		hideRevisionTests(revision)
+	}
+
+	return WriteJSON(w, http.StatusOK, revision)
+}
+
+// @Summary		Roll back challenge
+// @Description	Restore the content of an older revision as a new revision, owner or admin only. Lobbies stay pinned to the revision they were played on
+// @Tags			challenge
+// @Produce		json
+// @Param			id			path		int	true	"Challenge ID"
+// @Param			revision	path		int	true	"Revision number to restore"
+// @Success		200			{object}	types.ChallengeRevision
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		404			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/revisions/{revision}/rollback [post]
+func (s *Server) handleRollbackChallenge(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+	number, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid revision"})
+	}
+
+	canManage, err := s.canManageChallenge(authUser, id)
+	if err != nil {
+		return err
+	}
+	if !canManage {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	if _, err := s.db.GetChallengeRevision(id, number); err != nil {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Revision not found"})
+	}
+
+	log.Printf("[API] Rolling back challenge %d to revision %d", id, number)
+	revision, err := s.db.RollbackChallenge(id, number, authUser.Id)
+	if err != nil {
+		return err
+	}
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id, ChallengeId: id})
+
+	return WriteJSON(w, http.StatusOK, revision)
+}
+
+// hideRevisionTests removes the hidden tests and their diff from the revision
+func hideRevisionTests(revision *types.ChallengeRevision) {
+	revision.HiddenTestCases = nil
+
+	diff := []types.RevisionChange{}
+	for _, change := range revision.Diff {
+		if change.Field != types.RevisionFieldHiddenTests {
+			diff = append(diff, change)
+		}
+	}
+	revision.Diff = diff
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+const maxSearchQueryLength = 200
+
+// @Summary		Search challenges
+// @Description	Full-text search on the title, description and content of the published challenges, best matches first.
+// @Description	Every result has snippets of the matching fields with the highlighted ranges, in characters
+// @Tags			challenge
+// @Produce		json
+// @Param			q			query		string	true	"Search text"
+// @Param			tag			query		string	false	"Tag slug, repeat it to require several tags"
+// @Param			difficulty	query		string	false	"easy, medium or hard"
+// @Param			limit		query		int		false	"Max results, 20 by default, at most 50"
+// @Success		200			{object}	types.ChallengeSearchResponse
+// @Failure		400			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/search [get]
+func (s *Server) handleSearchChallenges(w http.ResponseWriter, r *http.Request) error {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(utils.SearchTerms(text)) == 0 {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "q must contain at least a word"})
+	}
+	if len(text) > maxSearchQueryLength {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "q is too long"})
+	}
+
+	filter, err := parseChallengeFilter(r)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	results, err := s.search.SearchChallenges(&types.ChallengeSearchQuery{
+		Query:  text,
+		Filter: *filter,
+		Limit:  utils.ParseLimit(r.URL.Query().Get("limit"), 20, 50),
+	})
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, types.ChallengeSearchResponse{Results: results})
+}
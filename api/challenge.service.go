@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+
+	"github.com/xedom/codeduel/utils"
+)
+
+// onChallengeEvent refreshes the computed difficulty of the challenge once a match on it ends, then reindexes it
+func (s *Server) onChallengeEvent(event *Event) {
+	if event.ChallengeId == 0 {
+		return
+	}
+
+	if err := s.db.RefreshComputedDifficulty(event.ChallengeId); err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("challenge"), utils.GetLogTag("error"), err.Error())
+	}
+
+	// reindexed here rather than by its own subscriber, which could read the challenge before the new difficulty is stored
+	if s.searchIndex != nil {
+		s.onSearchIndexEvent(event)
+	}
+}
+
+// onSearchIndexEvent keeps the in-memory search index in sync, a zero challenge id reindexes everything
+func (s *Server) onSearchIndexEvent(event *Event) {
+	if event.ChallengeId == 0 {
+		s.rebuildSearchIndex()
+		return
+	}
+
+	if event.Type == EventChallengeDeleted {
+		s.searchIndex.Remove(event.ChallengeId)
+		return
+	}
+
+	challenge, err := s.db.GetChallengeByID(event.ChallengeId)
+	if err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("search"), utils.GetLogTag("error"), err.Error())
+		return
+	}
+	s.searchIndex.Index(challenge)
+}
+
+func (s *Server) rebuildSearchIndex() {
+	challenges, err := s.db.GetChallenges(nil)
+	if err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("search"), utils.GetLogTag("error"), err.Error())
+		return
+	}
+
+	for i := range *challenges {
+		s.searchIndex.Index(&(*challenges)[i])
+	}
+	log.Printf("%s indexed %d challenges", utils.GetLogTag("search"), len(*challenges))
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get challenge templates
+// @Description	Get the starter code and the function signature of every language the author wrote a template for
+// @Tags			challenge
+// @Produce		json
+// @Param			id	path		int	true	"Challenge ID"
+// @Success		200	{object}	[]types.ChallengeTemplate
+// @Failure		400	{object}	Error
+// @Failure		404	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/templates [get]
+func (s *Server) handleGetChallengeTemplates(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
+	templates, err := s.db.GetChallengeTemplates(id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, templates)
+}
+
+// @Summary		Set challenge template
+// @Description	Create or replace the starter code and the function signature of a language, owner or admin only.
+// @Description	A changed template is saved as a new revision, the lobbies already pinned to a revision keep its templates
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int								true	"Challenge ID"
+// @Param			language	path		string							true	"Language name"
+// @Param			template	body		types.ChallengeTemplateRequest	true	"Template"
+// @Success		200			{object}	types.ChallengeTemplate
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/templates/{language} [put]
+func (s *Server) handleSetChallengeTemplate(w http.ResponseWriter, r *http.Request) error {
+	id, errResponse := s.parseChallengeTemplateRequest(w, r)
+	if id == 0 {
+		return errResponse
+	}
+
+	body := &types.ChallengeTemplateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid template"})
+	}
+	template := &types.ChallengeTemplate{
+		Language:    r.PathValue("language"),
+		Signature:   strings.TrimSpace(body.Signature),
+		StarterCode: body.StarterCode,
+	}
+	if err := types.ValidateChallengeTemplate(template); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+	known, err := s.isLanguage(template.Language)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("unknown language %q", template.Language)})
+	}
+
+	log.Printf("[API] Setting the %s template of challenge %d", template.Language, id)
+	authUser := GetAuthUser(r)
+	if err := s.db.SetChallengeTemplate(id, authUser.Id, template); err != nil {
+		return err
+	}
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id, ChallengeId: id})
+
+	saved, err := s.db.GetChallengeTemplate(id, template.Language)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, saved)
+}
+
+// @Summary		Delete challenge template
+// @Description	Remove the template of a language, owner or admin only
+// @Tags			challenge
+// @Param			id			path	int		true	"Challenge ID"
+// @Param			language	path	string	true	"Language name"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/templates/{language} [delete]
+func (s *Server) handleDeleteChallengeTemplate(w http.ResponseWriter, r *http.Request) error {
+	id, errResponse := s.parseChallengeTemplateRequest(w, r)
+	if id == 0 {
+		return errResponse
+	}
+
+	log.Printf("[API] Deleting the %s template of challenge %d", r.PathValue("language"), id)
+	authUser := GetAuthUser(r)
+	if err := s.db.DeleteChallengeTemplate(id, authUser.Id, r.PathValue("language")); err != nil {
+		return err
+	}
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id, ChallengeId: id})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// parseChallengeTemplateRequest checks that the user can manage the templates of the challenge,
+// on failure the response is already written and the returned id is 0
+func (s *Server) parseChallengeTemplateRequest(w http.ResponseWriter, r *http.Request) (int, error) {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return 0, WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canManage, err := s.canManageChallenge(authUser, id)
+	if err != nil {
+		return 0, err
+	}
+	if !canManage {
+		return 0, WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	return id, nil
+}
+
+// attachChallengeTemplate sets the template of the language asked with `?language=` on the challenge,
+// on failure the response is already written and the returned bool is false
+func (s *Server) attachChallengeTemplate(w http.ResponseWriter, r *http.Request, challenge *types.ChallengeFull) (bool, error) {
+	language := r.URL.Query().Get("language")
+	if language == "" {
+		return true, nil
+	}
+
+	known, err := s.isLanguage(language)
+	if err != nil {
+		return false, err
+	}
+	if !known {
+		return false, WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("unknown language %q", language)})
+	}
+
+	challenge.Template, err = s.db.GetChallengeTemplate(challenge.Id, language)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isLanguage reports whether the matches can be played in the language
+func (s *Server) isLanguage(name string) (bool, error) {
+	languages, err := s.db.GetLanguages()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(languages, func(l *types.Language) bool { return l.Name == name }), nil
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get challenge tests
+// @Description	Get the public tests of a challenge, the hidden ones are included for the owner, admins and the lobby service
+// @Tags			challenge
+// @Produce		json
+// @Param			id	path		int	true	"Challenge ID"
+// @Success		200	{object}	types.ChallengeTestCases
+// @Failure		400	{object}	Error
+// @Failure		404	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/challenge/{id}/tests [get]
+func (s *Server) handleGetChallengeTests(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	canView, err := s.canViewChallenge(r, id)
+	if err != nil || !canView {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+
+	testCases, err := s.db.GetChallengeTestCases(id)
+	if err != nil {
+		return err
+	}
+
+	canViewHidden, err := s.canViewHiddenTests(r, id)
+	if err != nil {
+		return err
+	}
+	if !canViewHidden {
+		testCases.HiddenTestCases = nil
+	}
+
+	return WriteJSON(w, http.StatusOK, testCases)
+}
+
+// @Summary		Replace challenge tests
+// @Description	Replace the whole public or hidden test list of a challenge, owner or admin only
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int					true	"Challenge ID"
+// @Param			visibility	path		string				true	"public or hidden"
+// @Param			tests		body		[]types.TestCase	true	"Tests"
+// @Success		200			{object}	[]types.TestCase
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/tests/{visibility} [put]
+func (s *Server) handleReplaceChallengeTests(w http.ResponseWriter, r *http.Request) error {
+	target, errResponse := s.getEditableTestCases(w, r)
+	if target == nil {
+		return errResponse
+	}
+
+	testCases := []types.TestCase{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*types.MaxTestCasesSize)).Decode(&testCases); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid tests"})
+	}
+
+	log.Printf("[API] Replacing %s tests of challenge %d", target.visibility, target.challengeId)
+	return s.saveTestCases(w, target, testCases)
+}
+
+// @Summary		Add challenge test
+// @Description	Append a test to the public or hidden list of a challenge, owner or admin only
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int				true	"Challenge ID"
+// @Param			visibility	path		string			true	"public or hidden"
+// @Param			test		body		types.TestCase	true	"Test"
+// @Success		200			{object}	[]types.TestCase
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/tests/{visibility} [post]
+func (s *Server) handleAddChallengeTest(w http.ResponseWriter, r *http.Request) error {
+	target, errResponse := s.getEditableTestCases(w, r)
+	if target == nil {
+		return errResponse
+	}
+
+	testCase := types.TestCase{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*types.MaxTestCaseFieldSize)).Decode(&testCase); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid test"})
+	}
+
+	log.Printf("[API] Adding %s test to challenge %d", target.visibility, target.challengeId)
+	return s.saveTestCases(w, target, append(target.testCases, testCase))
+}
+
+// @Summary		Edit challenge test
+// @Description	Replace a single test of the public or hidden list of a challenge, owner or admin only
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int				true	"Challenge ID"
+// @Param			visibility	path		string			true	"public or hidden"
+// @Param			index		path		int				true	"Position of the test, starting from 0"
+// @Param			test		body		types.TestCase	true	"Test"
+// @Success		200			{object}	[]types.TestCase
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		404			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/tests/{visibility}/{index} [put]
+func (s *Server) handleUpdateChallengeTest(w http.ResponseWriter, r *http.Request) error {
+	target, errResponse := s.getEditableTestCases(w, r)
+	if target == nil {
+		return errResponse
+	}
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(target.testCases) {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "test not found"})
+	}
+
+	testCase := types.TestCase{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*types.MaxTestCaseFieldSize)).Decode(&testCase); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid test"})
+	}
+	target.testCases[index] = testCase
+
+	log.Printf("[API] Updating %s test %d of challenge %d", target.visibility, index, target.challengeId)
+	return s.saveTestCases(w, target, target.testCases)
+}
+
+// @Summary		Delete challenge test
+// @Description	Remove a test from the public or hidden list of a challenge, owner or admin only
+// @Tags			challenge
+// @Produce		json
+// @Param			id			path		int		true	"Challenge ID"
+// @Param			visibility	path		string	true	"public or hidden"
+// @Param			index		path		int		true	"Position of the test, starting from 0"
+// @Success		200			{object}	[]types.TestCase
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		404			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/tests/{visibility}/{index} [delete]
+func (s *Server) handleDeleteChallengeTest(w http.ResponseWriter, r *http.Request) error {
+	target, errResponse := s.getEditableTestCases(w, r)
+	if target == nil {
+		return errResponse
+	}
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(target.testCases) {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "test not found"})
+	}
+
+	log.Printf("[API] Deleting %s test %d of challenge %d", target.visibility, index, target.challengeId)
+	return s.saveTestCases(w, target, append(target.testCases[:index], target.testCases[index+1:]...))
+}
+
+// @Summary		Reorder challenge tests
+// @Description	Reorder the public or hidden tests of a challenge, `order` lists every current index in the new order. Owner or admin only
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int								true	"Challenge ID"
+// @Param			visibility	path		string							true	"public or hidden"
+// @Param			order		body		types.ReorderTestCasesRequest	true	"New order"
+// @Success		200			{object}	[]types.TestCase
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/challenge/{id}/tests/{visibility}/order [put]
+func (s *Server) handleReorderChallengeTests(w http.ResponseWriter, r *http.Request) error {
+	target, errResponse := s.getEditableTestCases(w, r)
+	if target == nil {
+		return errResponse
+	}
+
+	body := &types.ReorderTestCasesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid order"})
+	}
+	if len(body.Order) != len(target.testCases) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "order must list every test exactly once"})
+	}
+
+	seen := make([]bool, len(target.testCases))
+	reordered := make([]types.TestCase, 0, len(target.testCases))
+	for _, index := range body.Order {
+		if index < 0 || index >= len(target.testCases) || seen[index] {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: "order must list every test exactly once"})
+		}
+		seen[index] = true
+		reordered = append(reordered, target.testCases[index])
+	}
+
+	log.Printf("[API] Reordering %s tests of challenge %d", target.visibility, target.challengeId)
+	return s.saveTestCases(w, target, reordered)
+}
+
+// testCasesTarget is the test list a request is editing
+type testCasesTarget struct {
+	challengeId int
+	authorId    int
+	visibility  string
+	testCases   []types.TestCase
+}
+
+// getEditableTestCases loads the list addressed by the path once the user is allowed to edit it,
+// on failure the response is already written and the returned target is nil
+func (s *Server) getEditableTestCases(w http.ResponseWriter, r *http.Request) (*testCasesTarget, error) {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return nil, WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+	visibility := r.PathValue("visibility")
+	if !types.IsTestCaseVisibility(visibility) {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "visibility must be public or hidden"})
+	}
+
+	canManage, err := s.canManageChallenge(authUser, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage {
+		return nil, WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	testCases, err := s.db.GetChallengeTestCases(id)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &testCasesTarget{challengeId: id, authorId: authUser.Id, visibility: visibility, testCases: testCases.TestCases}
+	if visibility == types.TestCaseVisibilityHidden {
+		target.testCases = testCases.HiddenTestCases
+	}
+	return target, nil
+}
+
+func (s *Server) saveTestCases(w http.ResponseWriter, target *testCasesTarget, testCases []types.TestCase) error {
+	if err := types.ValidateTestCases(testCases); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	if err := s.db.SetChallengeTestCases(target.challengeId, target.authorId, target.visibility, testCases); err != nil {
+		return err
+	}
+	// the challenge may be back to draft
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: target.authorId, ChallengeId: target.challengeId})
+
+	return WriteJSON(w, http.StatusOK, testCases)
+}
+
+// canManageChallenge reports whether the user can edit the challenge and see its hidden tests
+func (s *Server) canManageChallenge(authUser *types.UserRequestHeader, challengeId int) (bool, error) {
+	if authUser.Role == types.UserRoleAdmin {
+		return true, nil
+	}
+
+	ownerId, err := s.db.GetChallengeOwnerID(challengeId)
+	if err != nil {
+		return false, err
+	}
+	return ownerId == authUser.Id, nil
+}
+
+// canViewHiddenTests lets the lobby service run the hidden tests, users only see them on their own challenges
+func (s *Server) canViewHiddenTests(r *http.Request, challengeId int) (bool, error) {
+	if IsInternalServiceRequest(s.config, r) {
+		return true, nil
+	}
+
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return false, nil
+	}
+	return s.canManageChallenge(authUser, challengeId)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+func (s *Server) GetDailyRouter() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /daily", convertToHandleFunc(s.handleGetDaily, OptionalAuthMiddleware))
+	router.HandleFunc("GET /daily/{date}", convertToHandleFunc(s.handleGetDaily, OptionalAuthMiddleware))
+	router.HandleFunc("PUT /daily/{date}", convertToHandleFunc(s.handleSetDaily, AuthMiddleware))
+	return router
+}
+
+// @Summary		Get daily challenge
+// @Description	Get the daily challenge of today, or of a past day. Days start at midnight UTC
+// @Tags			daily
+// @Produce		json
+// @Param			date	path		string	false	"Day (YYYY-MM-DD), default today"
+// @Success		200		{object}	types.DailyChallengeResponse
+// @Failure		400		{object}	Error
+// @Failure		404		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/daily [get]
+// @Router			/v1/daily/{date} [get]
+func (s *Server) handleGetDaily(w http.ResponseWriter, r *http.Request) error {
+	today := utils.DailyDate(s.clock.Now())
+
+	date := r.PathValue("date")
+	if date == "" {
+		date = today
+	}
+	startsAt, endsAt, err := utils.DailyWindow(date)
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid date, expected YYYY-MM-DD"})
+	}
+	// the challenges of the next days are not revealed
+	if date > today {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "the daily challenge of this day is not published yet"})
+	}
+
+	daily, err := s.db.GetDailyChallenge(date)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := s.db.GetChallengeByID(daily.ChallengeId)
+	if err != nil {
+		return err
+	}
+
+	solversCount, err := s.db.GetDailySolversCount(date)
+	if err != nil {
+		return err
+	}
+
+	response := &types.DailyChallengeResponse{
+		Date:         date,
+		StartsAt:     startsAt.Format(time.RFC3339),
+		EndsAt:       endsAt.Format(time.RFC3339),
+		Challenge:    challenge,
+		SolversCount: solversCount,
+	}
+
+	if authUser := GetAuthUser(r); authUser != nil {
+		solved, err := s.db.HasSolvedDaily(authUser.Id, date)
+		if err != nil {
+			return err
+		}
+		streak, err := s.db.GetUserStreak(authUser.Id, today)
+		if err != nil {
+			return err
+		}
+		response.Solved = &solved
+		response.Streak = streak
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+// @Summary		Set daily challenge
+// @Description	Override the automatic selection of the daily challenge of today or of a next day, admin only
+// @Tags			daily
+// @Accept			json
+// @Produce		json
+// @Param			date		path		string							true	"Day (YYYY-MM-DD)"
+// @Param			challenge	body		types.SetDailyChallengeRequest	true	"Challenge"
+// @Success		200			{object}	types.DailyChallenge
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/daily/{date} [put]
+func (s *Server) handleSetDaily(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if authUser.Role != types.UserRoleAdmin {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	date := r.PathValue("date")
+	if _, _, err := utils.DailyWindow(date); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid date, expected YYYY-MM-DD"})
+	}
+	// past days keep their challenge, their solves and streaks were computed on it
+	if date < utils.DailyDate(s.clock.Now()) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "the daily challenge of a past day can't be changed"})
+	}
+
+	body := &types.SetDailyChallengeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+	status, err := s.db.GetChallengeStatus(body.ChallengeId)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "challenge not found"})
+	}
+	if status != types.ChallengeStatusPublished {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "the challenge is not published"})
+	}
+
+	log.Printf("[API] Setting daily challenge of %s to %d", date, body.ChallengeId)
+	if err := s.db.SetDailyChallenge(date, body.ChallengeId); err != nil {
+		return err
+	}
+
+	daily, err := s.db.GetDailyChallenge(date)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, daily)
+}
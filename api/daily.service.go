@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"github.com/xedom/codeduel/utils"
+)
+
+// onDailyEvent records the solves of the daily challenge when a match on it ends
+func (s *Server) onDailyEvent(event *Event) {
+	today := utils.DailyDate(s.clock.Now())
+
+	daily, err := s.db.GetDailyChallenge(today)
+	if err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("daily"), utils.GetLogTag("error"), err.Error())
+		return
+	}
+	if daily.ChallengeId != event.ChallengeId {
+		return
+	}
+
+	solverIds, err := s.db.GetLobbySolverIDs(event.LobbyUniqueId)
+	if err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("daily"), utils.GetLogTag("error"), err.Error())
+		return
+	}
+
+	for _, userId := range solverIds {
+		if err := s.db.RecordDailySolve(userId, today, event.LobbyUniqueId); err != nil {
+			log.Printf("%s%s failed to record the daily solve of user %d: %s", utils.GetLogTag("daily"), utils.GetLogTag("error"), userId, err.Error())
+		}
+	}
+}
+
+// runDailyPublisher picks the daily challenge as soon as each UTC day starts
+func (s *Server) runDailyPublisher() {
+	for {
+		today := utils.DailyDate(s.clock.Now())
+		if daily, err := s.db.GetDailyChallenge(today); err != nil {
+			log.Printf("%s%s failed to publish the daily challenge: %s", utils.GetLogTag("daily"), utils.GetLogTag("error"), err.Error())
+		} else {
+			log.Printf("%s challenge %d is the daily challenge of %s", utils.GetLogTag("daily"), daily.ChallengeId, today)
+		}
+
+		_, endsAt, _ := utils.DailyWindow(today)
+		time.Sleep(max(endsAt.Sub(s.clock.Now()), time.Minute))
+	}
+}
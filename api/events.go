@@ -0,0 +1,65 @@
+package api
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/xedom/codeduel/utils"
+)
+
+const (
+	EventMatchEnded        = "match_ended"
+	EventChallengeCreated  = "challenge_created"
+	EventStatsUpdated      = "stats_updated"
+	EventLobbyCreated      = "lobby_created"
+	EventUserFollowed      = "user_followed"
+	EventChallengeApproved = "challenge_approved"
+	EventChallengeRejected = "challenge_rejected"
+	EventChallengeUpdated  = "challenge_updated"
+	EventChallengeDeleted  = "challenge_deleted"
+)
+
+// Event is a domain event, published after the change it describes is stored
+type Event struct {
+	Type          string
+	UserIds       []int // users the event is about
+	ActorId       int   // user that caused the event, if any
+	LobbyUniqueId string
+	ChallengeId   int
+}
+
+type EventHandler func(*Event)
+
+// EventBus dispatches the events to the subscribed handlers, in the background
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: map[string][]EventHandler{}}
+}
+
+func (b *EventBus) Subscribe(eventType string, handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *EventBus) Publish(event *Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		go func(handler EventHandler) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.Printf("%s%s handler of %s panicked: %v\n%s", utils.GetLogTag("events"), utils.GetLogTag("error"), event.Type, err, debug.Stack())
+				}
+			}()
+			handler(event)
+		}(handler)
+	}
+}
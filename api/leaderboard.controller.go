@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+func (s *Server) GetLeaderboardRouter() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /leaderboard", convertToHandleFunc(s.handleGetLeaderboard, OptionalAuthMiddleware))
+	router.HandleFunc("GET /leaderboard/seasons", convertToHandleFunc(s.handleGetSeasons))
+	router.HandleFunc("POST /leaderboard/seasons", convertToHandleFunc(s.handleCreateSeason, AuthMiddleware))
+	return router
+}
+
+// runLeaderboardRefresher recomputes the leaderboards now and then every LEADERBOARD_REFRESH_MINUTES
+func (s *Server) runLeaderboardRefresher() {
+	ticker := time.NewTicker(time.Minute * time.Duration(max(s.config.LeaderboardRefreshMinutes, 1)))
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		if err := s.db.RefreshLeaderboards(start); err != nil {
+			log.Printf("%s%s failed to refresh leaderboards: %s", utils.GetLogTag("leaderboard"), utils.GetLogTag("error"), err.Error())
+		} else {
+			log.Printf("%s leaderboards refreshed in %v", utils.GetLogTag("leaderboard"), time.Since(start))
+		}
+		<-ticker.C
+	}
+}
+
+// @Summary		Get leaderboard
+// @Description	Get a page of a leaderboard, with the position of the authenticated user even when outside the page. Leaderboards are recomputed periodically
+// @Tags			leaderboard
+// @Produce		json
+// @Param			metric		query		string	false	"Ranking metric (rating, wins, solves), default rating"
+// @Param			mode		query		string	false	"Game mode, required for rating (default speed)"
+// @Param			language	query		string	false	"Language, not supported by rating"
+// @Param			window		query		string	false	"Time window (all, monthly, weekly, season), only all for rating"
+// @Param			season		query		int		false	"Season id, required with the season window"
+// @Param			friends		query		bool	false	"Only the authenticated user and their friends"
+// @Param			cursor		query		string	false	"Cursor returned by the previous page"
+// @Param			limit		query		int		false	"Page size (max 100)"
+// @Success		200			{object}	types.LeaderboardResponse
+// @Failure		400			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/leaderboard [get]
+func (s *Server) handleGetLeaderboard(w http.ResponseWriter, r *http.Request) error {
+	urlQuery := r.URL.Query()
+
+	leaderboardQuery := &types.LeaderboardQuery{
+		Metric:   urlQuery.Get("metric"),
+		Mode:     urlQuery.Get("mode"),
+		Language: urlQuery.Get("language"),
+		Window:   urlQuery.Get("window"),
+		SeasonId: utils.ToInt(urlQuery.Get("season"), 0),
+		Friends:  urlQuery.Get("friends") == "true",
+		Cursor:   urlQuery.Get("cursor"),
+		Limit:    utils.ParseLimit(urlQuery.Get("limit"), 50, 100),
+	}
+	if leaderboardQuery.Metric == "" {
+		leaderboardQuery.Metric = types.LeaderboardMetricRating
+	}
+	if leaderboardQuery.Window == "" {
+		leaderboardQuery.Window = types.LeaderboardWindowAll
+	}
+	if authUser := GetAuthUser(r); authUser != nil {
+		leaderboardQuery.ViewerId = authUser.Id
+	}
+
+	switch leaderboardQuery.Metric {
+	case types.LeaderboardMetricRating:
+		if leaderboardQuery.Language != "" || leaderboardQuery.Window != types.LeaderboardWindowAll {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: "rating leaderboards can't be filtered by language or time window"})
+		}
+		if leaderboardQuery.Mode == "" {
+			leaderboardQuery.Mode = defaultRatingMode
+		}
+	case types.LeaderboardMetricWins, types.LeaderboardMetricSolves:
+	default:
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid metric"})
+	}
+
+	switch leaderboardQuery.Window {
+	case types.LeaderboardWindowAll, types.LeaderboardWindowMonthly, types.LeaderboardWindowWeekly:
+	case types.LeaderboardWindowSeason:
+		if leaderboardQuery.SeasonId == 0 {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: "season is required"})
+		}
+	default:
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid window"})
+	}
+
+	if leaderboardQuery.Friends && leaderboardQuery.ViewerId == 0 {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if _, err := utils.DecodeCursor(leaderboardQuery.Cursor); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	leaderboard, err := s.db.GetLeaderboard(leaderboardQuery)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, leaderboard)
+}
+
+// @Summary		Get seasons
+// @Description	Get all the leaderboard seasons
+// @Tags			leaderboard
+// @Produce		json
+// @Success		200	{object}	[]types.Season
+// @Failure		500	{object}	Error
+// @Router			/v1/leaderboard/seasons [get]
+func (s *Server) handleGetSeasons(w http.ResponseWriter, _ *http.Request) error {
+	seasons, err := s.db.GetSeasons()
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, seasons)
+}
+
+// @Summary		Create season
+// @Description	Create a leaderboard season, admin only. Dates are UTC in the "2006-01-02 15:04:05" format
+// @Tags			leaderboard
+// @Accept			json
+// @Produce		json
+// @Param			season	body		types.Season	true	"Season"
+// @Success		200		{object}	types.Season
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/leaderboard/seasons [post]
+func (s *Server) handleCreateSeason(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if authUser.Role != types.UserRoleAdmin {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	season := &types.Season{}
+	if err := json.NewDecoder(r.Body).Decode(season); err != nil {
+		return err
+	}
+
+	startsAt, errStart := time.Parse(time.DateTime, season.StartsAt)
+	endsAt, errEnd := time.Parse(time.DateTime, season.EndsAt)
+	if season.Name == "" || errStart != nil || errEnd != nil || !endsAt.After(startsAt) {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid season"})
+	}
+
+	log.Print("[API] Creating season ", season.Name)
+	if err := s.db.CreateSeason(season); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, season)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 
@@ -14,16 +15,16 @@ func (s *Server) GetLobbyRouter() http.Handler {
 	// router.HandleFunc("PATCH /lobby/{id}", makeHTTPHandleFunc(s.handleGetLobbyByID))
 	router.HandleFunc("PATCH /lobby/{lobbyUniqueId}/submission", convertToHandleFunc(s.handleLobbyUserSubmission))
 	router.HandleFunc("PATCH /lobby/{lobbyUniqueId}/endgame", convertToHandleFunc(s.handleLobbyEnd))
-	router.HandleFunc("GET /lobby/results/{lobbyUniqueId}", convertToHandleFunc(s.handleGetResults))
+	router.HandleFunc("GET /lobby/results/{lobbyUniqueId}", convertToHandleFunc(s.handleGetResults, OptionalAuthMiddleware))
 	router.HandleFunc("OPTIONS /lobby/{lobbyUniqueId}/sharecode", convertToHandleFunc(s.handleShareCodeOptions))
 	router.HandleFunc("PATCH /lobby/{lobbyUniqueId}/sharecode", convertToHandleFunc(s.handleShareCode, AuthMiddleware))
-	router.HandleFunc("GET /lobby/user/{username}", convertToHandleFunc(s.handleGetMatchByUsername))
+	router.HandleFunc("GET /lobby/user/{username}", convertToHandleFunc(s.handleGetMatchByUsername, OptionalAuthMiddleware))
 
 	return router
 }
 
 // @Summary		Get lobby results
-// @Description	Get lobby results
+// @Description	Get lobby results. The code of a player is only included if they shared it with the viewer
 // @Tags			lobby
 // @Produce		json
 // @Param			lobbyUniqueId	path		string	true	"Lobby unique id"
@@ -34,7 +35,7 @@ func (s *Server) handleGetResults(w http.ResponseWriter, r *http.Request) error
 	lobbyUniqueId := r.PathValue("lobbyUniqueId")
 	log.Print("[API] Getting results for lobby ", lobbyUniqueId)
 
-	results, err := s.db.GetLobbyResults(lobbyUniqueId)
+	results, err := s.db.GetLobbyResults(lobbyUniqueId, GetViewerId(r))
 	if err != nil {
 		return err
 	}
@@ -49,6 +50,9 @@ func (s *Server) handleGetResults(w http.ResponseWriter, r *http.Request) error
 // @Produce		json
 // @Param			lobby	body	types.CreateLobbyRequest	true	"Create Lobby Request"
 // @Success		204
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		404	{object}	Error
 // @Failure		500	{object}	Error
 // @Router			/v1/lobby [post]
 func (s *Server) handleCreateLobby(w http.ResponseWriter, r *http.Request) error {
@@ -57,20 +61,68 @@ func (s *Server) handleCreateLobby(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
+	// blocked users can't be invited to, or join, a lobby together
+	blocked, err := s.db.HasBlockBetween(append([]int{createLobbyPayload.OwnerId}, createLobbyPayload.UsersId...))
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Some of the players blocked each other"})
+	}
+
+	// friends-only lobbies can only be joined by the friends of the owner
+	if createLobbyPayload.Settings.FriendsOnly {
+		for _, userId := range createLobbyPayload.UsersId {
+			if userId == createLobbyPayload.OwnerId {
+				continue
+			}
+			friends, err := s.db.AreFriends(createLobbyPayload.OwnerId, userId)
+			if err != nil {
+				return err
+			}
+			if !friends {
+				return WriteJSON(w, http.StatusForbidden, Error{Err: fmt.Sprintf("user %d is not a friend of the lobby owner", userId)})
+			}
+		}
+	}
+
+	// only published challenges can be played
+	status, err := s.db.GetChallengeStatus(createLobbyPayload.ChallengeId)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Challenge not found"})
+	}
+	if status != types.ChallengeStatusPublished {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "the challenge is not published"})
+	}
+
 	if err := s.db.CreateLobby(&types.Lobby{
 		UniqueId:    createLobbyPayload.LobbyUniqueId,
 		OwnerId:     createLobbyPayload.OwnerId,
 		UsersId:     createLobbyPayload.UsersId,
 		ChallengeId: createLobbyPayload.ChallengeId,
 
+		ChallengeRevisionId: createLobbyPayload.ChallengeRevisionId,
+
 		Mode:             createLobbyPayload.Settings.Mode,
 		MaxPlayers:       createLobbyPayload.Settings.MaxPlayers,
 		GameDuration:     createLobbyPayload.Settings.GameDuration,
 		AllowedLanguages: createLobbyPayload.Settings.AllowedLanguages,
+		Ranked:           createLobbyPayload.Settings.Ranked,
 	}); err != nil {
 		return err
 	}
 
+	invitedIds := []int{}
+	for _, userId := range createLobbyPayload.UsersId {
+		if userId != createLobbyPayload.OwnerId {
+			invitedIds = append(invitedIds, userId)
+		}
+	}
+	s.events.Publish(&Event{Type: EventLobbyCreated, UserIds: invitedIds, ActorId: createLobbyPayload.OwnerId, LobbyUniqueId: createLobbyPayload.LobbyUniqueId})
+
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
@@ -127,8 +179,30 @@ func (s *Server) handleLobbyEnd(w http.ResponseWriter, r *http.Request) error {
 	lobbyUniqueId := r.PathValue("lobbyUniqueId")
 	log.Print("[API] Lobby end ", lobbyUniqueId)
 
+	ended, err := s.db.EndLobby(lobbyUniqueId)
+	if err != nil {
+		return err
+	}
+	// the lobby service may retry, the match is only announced by the call that ended it
+	if !ended {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	results, err := s.db.GetLobbyResults(lobbyUniqueId, 0)
+	if err != nil {
+		return err
+	}
+
+	userIds := []int{}
+	for _, result := range results.Results {
+		userIds = append(userIds, result.UserId)
+	}
+	s.events.Publish(&Event{Type: EventMatchEnded, UserIds: userIds, LobbyUniqueId: lobbyUniqueId, ChallengeId: results.Lobby.ChallengeId})
+	s.events.Publish(&Event{Type: EventStatsUpdated, UserIds: userIds})
+
 	w.WriteHeader(http.StatusNoContent)
-	return s.db.EndLobby(lobbyUniqueId)
+	return nil
 }
 
 // @Summary		Share code
@@ -171,17 +245,24 @@ func (s *Server) handleShareCodeOptions(w http.ResponseWriter, r *http.Request)
 }
 
 // @Summary		Get match by username
-// @Description	Get match by username
+// @Description	Get the ended matches of the user, if their match history is visible to the viewer
 // @Tags			match
 // @Produce		json
 // @Param			username	path		string	true	"Username"
 // @Success		200			{object}	[]types.SingleMatchResult
+// @Failure		403			{object}	Error
 // @Failure		500			{object}	Error
 // @Router			/match/user/{username} [get]
 func (s *Server) handleGetMatchByUsername(w http.ResponseWriter, r *http.Request) error {
 	username := r.PathValue("username")
 	log.Print("[API] Fetching match for user ", username)
-	matches, err := s.db.GetMatchByUsername(username)
+
+	_, ok, err := s.getUserWithVisibleMatches(w, r)
+	if !ok {
+		return err
+	}
+
+	matches, err := s.db.GetMatchByUsername(username, GetViewerId(r))
 	if err != nil {
 		return err
 	}
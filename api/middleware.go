@@ -78,6 +78,12 @@ func OnlyInternalServiceMiddleware(config *utils.Config, next http.Handler) http
 	})
 }
 
+// IsInternalServiceRequest reports whether the request carries the service token of the lobby service
+func IsInternalServiceRequest(config *utils.Config, r *http.Request) bool {
+	token := r.Header.Get("x-token")
+	return config.ServiceToken != "" && token == config.ServiceToken
+}
+
 type contextKey string
 
 const AuthUser contextKey = "middleware.auth.user"
@@ -108,6 +114,25 @@ func AuthMiddleware(w http.ResponseWriter, r *http.Request) *http.Request {
 	return r
 }
 
+// OptionalAuthMiddleware adds the user to the context when the request carries a valid token,
+// anonymous requests are let through untouched
+func OptionalAuthMiddleware(_ http.ResponseWriter, r *http.Request) *http.Request {
+	tokenString := r.Header.Get("x-token")
+	if tokenString == "" {
+		tokenString = getCookie(r, "access_token")
+	}
+	if tokenString == "" {
+		return r
+	}
+
+	userHeader, err := utils.ValidateUserJWT(tokenString)
+	if err != nil {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), AuthUser, userHeader))
+}
+
 func GetAuthUser(r *http.Request) *types.UserRequestHeader {
 	user := r.Context().Value(AuthUser)
 	if user == nil {
@@ -116,3 +141,11 @@ func GetAuthUser(r *http.Request) *types.UserRequestHeader {
 
 	return user.(*types.UserRequestHeader)
 }
+
+// GetViewerId returns the id of the authenticated user, 0 when anonymous
+func GetViewerId(r *http.Request) int {
+	if user := GetAuthUser(r); user != nil {
+		return user.Id
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// onNotificationEvent notifies the users the event is about
+func (s *Server) onNotificationEvent(event *Event) {
+	var notificationType string
+	data := map[string]any{}
+
+	switch event.Type {
+	case EventLobbyCreated:
+		notificationType = types.NotificationLobbyInvite
+		data["lobby_id"] = event.LobbyUniqueId
+	case EventUserFollowed:
+		notificationType = types.NotificationNewFollower
+	case EventMatchEnded:
+		notificationType = types.NotificationMatchResults
+		data["lobby_id"] = event.LobbyUniqueId
+	case EventChallengeApproved:
+		notificationType = types.NotificationChallengeApproved
+		data["challenge_id"] = event.ChallengeId
+	case EventChallengeRejected:
+		notificationType = types.NotificationChallengeRejected
+		data["challenge_id"] = event.ChallengeId
+	default:
+		return
+	}
+
+	if event.ActorId != 0 {
+		actor, err := s.db.GetUserByID(event.ActorId)
+		if err != nil {
+			log.Printf("%s%s %s", utils.GetLogTag("notification"), utils.GetLogTag("error"), err.Error())
+			return
+		}
+		data["username"] = actor.Username
+	}
+
+	if err := s.db.CreateNotifications(event.UserIds, notificationType, data); err != nil {
+		log.Printf("%s%s failed to create %s notifications: %s", utils.GetLogTag("notification"), utils.GetLogTag("error"), notificationType, err.Error())
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+)
+
+const maxTagNameLength = 50
+
+var tagSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func (s *Server) GetTagRouter() http.Handler {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /tag", convertToHandleFunc(s.handleGetTags))
+	router.HandleFunc("POST /tag", convertToHandleFunc(s.handleCreateTag, AuthMiddleware))
+	router.HandleFunc("PUT /tag/{slug}", convertToHandleFunc(s.handleUpdateTag, AuthMiddleware))
+	router.HandleFunc("DELETE /tag/{slug}", convertToHandleFunc(s.handleDeleteTag, AuthMiddleware))
+	return router
+}
+
+// @Summary		Get tags
+// @Description	Get the tag catalogue with the number of challenges of every tag
+// @Tags			tag
+// @Produce		json
+// @Success		200	{object}	[]types.Tag
+// @Failure		500	{object}	Error
+// @Router			/v1/tag [get]
+func (s *Server) handleGetTags(w http.ResponseWriter, _ *http.Request) error {
+	tags, err := s.db.GetTags()
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, tags)
+}
+
+// @Summary		Create tag
+// @Description	Add a tag to the catalogue, moderators only
+// @Tags			tag
+// @Accept			json
+// @Produce		json
+// @Param			tag	body		types.TagRequest	true	"Tag"
+// @Success		200	{object}	types.Tag
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/tag [post]
+func (s *Server) handleCreateTag(w http.ResponseWriter, r *http.Request) error {
+	tag, errResponse := parseTagRequest(w, r)
+	if tag == nil {
+		return errResponse
+	}
+
+	log.Print("[API] Creating tag ", tag.Slug)
+	if err := s.db.CreateTag(tag); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, tag)
+}
+
+// @Summary		Update tag
+// @Description	Rename a tag, moderators only. The challenges keep it
+// @Tags			tag
+// @Accept			json
+// @Produce		json
+// @Param			slug	path		string				true	"Tag slug"
+// @Param			tag		body		types.TagRequest	true	"Tag"
+// @Success		200		{object}	types.Tag
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/tag/{slug} [put]
+func (s *Server) handleUpdateTag(w http.ResponseWriter, r *http.Request) error {
+	tag, errResponse := parseTagRequest(w, r)
+	if tag == nil {
+		return errResponse
+	}
+
+	log.Printf("[API] Updating tag %s to %s", r.PathValue("slug"), tag.Slug)
+	if err := s.db.UpdateTag(r.PathValue("slug"), tag); err != nil {
+		return err
+	}
+	// every challenge with the tag changed
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: GetAuthUser(r).Id})
+
+	return WriteJSON(w, http.StatusOK, tag)
+}
+
+// @Summary		Delete tag
+// @Description	Remove a tag from the catalogue and from every challenge, moderators only
+// @Tags			tag
+// @Param			slug	path	string	true	"Tag slug"
+// @Success		204
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Router			/v1/tag/{slug} [delete]
+func (s *Server) handleDeleteTag(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if !types.IsModeratorRole(authUser.Role) {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	log.Print("[API] Deleting tag ", r.PathValue("slug"))
+	if err := s.db.DeleteTag(r.PathValue("slug")); err != nil {
+		return err
+	}
+	// every challenge with the tag changed
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Set challenge tags
+// @Description	Replace the tags of a challenge with tags of the catalogue, owner or moderators only
+// @Tags			challenge
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int								true	"Challenge ID"
+// @Param			tags	body		types.SetChallengeTagsRequest	true	"Tag slugs"
+// @Success		200		{object}	types.SetChallengeTagsRequest
+// @Failure		400		{object}	Error
+// @Failure		403		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/challenge/{id}/tags [put]
+func (s *Server) handleSetChallengeTags(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid challenge id"})
+	}
+
+	if !types.IsModeratorRole(authUser.Role) {
+		ownerId, err := s.db.GetChallengeOwnerID(id)
+		if err != nil {
+			return err
+		}
+		if ownerId != authUser.Id {
+			return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+		}
+	}
+
+	body := &types.SetChallengeTagsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid tags"})
+	}
+	if len(body.Tags) > types.MaxChallengeTags {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("a challenge can't have more than %d tags", types.MaxChallengeTags)})
+	}
+
+	log.Printf("[API] Setting tags of challenge %d: %v", id, body.Tags)
+	if err := s.db.SetChallengeTags(id, body.Tags); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+	s.events.Publish(&Event{Type: EventChallengeUpdated, ActorId: authUser.Id, ChallengeId: id})
+
+	return WriteJSON(w, http.StatusOK, body)
+}
+
+// parseTagRequest decodes and validates the tag of a moderator's request,
+// on failure the response is already written and the returned tag is nil
+func parseTagRequest(w http.ResponseWriter, r *http.Request) (*types.Tag, error) {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return nil, WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if !types.IsModeratorRole(authUser.Role) {
+		return nil, WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
+	body := &types.TagRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid tag"})
+	}
+
+	tag := &types.Tag{
+		Slug: strings.TrimSpace(body.Slug),
+		Name: strings.TrimSpace(body.Name),
+	}
+	if len(tag.Slug) > maxTagNameLength || !tagSlugPattern.MatchString(tag.Slug) {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: "slug must be lowercase letters and digits separated by dashes"})
+	}
+	if tag.Name == "" || len(tag.Name) > maxTagNameLength {
+		return nil, WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("name must be between 1 and %d characters", maxTagNameLength)})
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get activity
+// @Description	Get the number of matches played by the user each day of the last year (UTC days), if their match history is visible to the viewer
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Success		200			{object}	types.ActivityResponse
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/activity [get]
+func (s *Server) handleGetUserActivity(w http.ResponseWriter, r *http.Request) error {
+	user, ok, err := s.getUserWithVisibleMatches(w, r)
+	if !ok {
+		return err
+	}
+
+	activity, err := s.getUserActivity(user.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, activity)
+}
+
+// @Summary		Get language stats
+// @Description	Get the matches, win rate and average tests passed of the user in each language, if their match history is visible to the viewer
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Success		200			{object}	[]types.LanguageStats
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/languages [get]
+func (s *Server) handleGetUserLanguageStats(w http.ResponseWriter, r *http.Request) error {
+	user, ok, err := s.getUserWithVisibleMatches(w, r)
+	if !ok {
+		return err
+	}
+
+	languages, err := s.getUserLanguageStats(user.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, languages)
+}
+
+// getUserWithVisibleMatches returns the user of the `username` path value if the viewer can see
+// their match history, otherwise the response is written and ok is false
+func (s *Server) getUserWithVisibleMatches(w http.ResponseWriter, r *http.Request) (*types.User, bool, error) {
+	user, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return nil, false, err
+	}
+
+	privacy, err := s.db.GetUserPrivacy(user.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	canView, err := s.db.CanView(GetViewerId(r), user.Id, privacy.Matches)
+	if err != nil {
+		return nil, false, err
+	}
+	if !canView {
+		return nil, false, WriteJSON(w, http.StatusForbidden, Error{Err: "The match history of this user is private"})
+	}
+
+	return user, true, nil
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// activityDays is how many days the activity calendar covers, today included
+const activityDays = 365
+
+type userAnalytics struct {
+	activity  *types.ActivityResponse // computed on activity.To, stale the day after
+	languages []*types.LanguageStats
+}
+
+// maxAnalyticsCacheUsers bounds the users kept in the analytics cache, the least recently viewed are dropped first
+const maxAnalyticsCacheUsers = 1000
+
+// analyticsCache keeps the profile analytics of each user until a match they played ends.
+// Every entry has a generation, so a result computed while the entry was invalidated is dropped instead of cached.
+type analyticsCache struct {
+	mu         sync.Mutex
+	users      map[int]*list.Element // of *analyticsEntry, the most recently viewed first
+	order      *list.List
+	generation uint64
+}
+
+type analyticsEntry struct {
+	userId     int
+	generation uint64
+	analytics  userAnalytics
+}
+
+func newAnalyticsCache() *analyticsCache {
+	return &analyticsCache{users: map[int]*list.Element{}, order: list.New()}
+}
+
+// get returns the cached analytics of the user and the generation to pass to update with what's computed
+func (c *analyticsCache) get(userId int) (userAnalytics, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.users[userId]; ok {
+		c.order.MoveToFront(element)
+		entry := element.Value.(*analyticsEntry)
+		return entry.analytics, entry.generation
+	}
+
+	c.generation++
+	c.users[userId] = c.order.PushFront(&analyticsEntry{userId: userId, generation: c.generation})
+	if c.order.Len() > maxAnalyticsCacheUsers {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.users, oldest.Value.(*analyticsEntry).userId)
+	}
+	return userAnalytics{}, c.generation
+}
+
+// update caches what was computed from the analytics of `generation`, unless they were invalidated or dropped since
+func (c *analyticsCache) update(userId int, generation uint64, update func(*userAnalytics)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.users[userId]
+	if !ok {
+		return
+	}
+	if entry := element.Value.(*analyticsEntry); entry.generation == generation {
+		update(&entry.analytics)
+	}
+}
+
+func (c *analyticsCache) invalidate(userIds ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, userId := range userIds {
+		if element, ok := c.users[userId]; ok {
+			c.generation++
+			element.Value = &analyticsEntry{userId: userId, generation: c.generation}
+		}
+	}
+}
+
+// onAnalyticsEvent drops the cached analytics of the players of the ended match
+func (s *Server) onAnalyticsEvent(event *Event) {
+	s.analytics.invalidate(event.UserIds...)
+}
+
+func (s *Server) getUserActivity(userId int) (*types.ActivityResponse, error) {
+	today := utils.DailyDate(s.clock.Now())
+	cached, generation := s.analytics.get(userId)
+	if activity := cached.activity; activity != nil && activity.To == today {
+		return activity, nil
+	}
+
+	end, _, err := utils.DailyWindow(today)
+	if err != nil {
+		return nil, err
+	}
+	start := end.AddDate(0, 0, -(activityDays - 1))
+
+	days, err := s.db.GetUserActivity(userId, start.Format(utils.DailyDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	matchesByDay := map[string]int{}
+	for _, day := range days {
+		matchesByDay[day.Date] = day.Matches
+	}
+
+	activity := &types.ActivityResponse{
+		From: start.Format(utils.DailyDateLayout),
+		To:   today,
+		Days: make([]*types.ActivityDay, 0, activityDays),
+	}
+	for day := start; !day.After(end); day = day.Add(24 * time.Hour) {
+		date := day.Format(utils.DailyDateLayout)
+		activity.Days = append(activity.Days, &types.ActivityDay{Date: date, Matches: matchesByDay[date]})
+		activity.Total += matchesByDay[date]
+	}
+
+	s.analytics.update(userId, generation, func(analytics *userAnalytics) {
+		analytics.activity = activity
+	})
+	return activity, nil
+}
+
+func (s *Server) getUserLanguageStats(userId int) ([]*types.LanguageStats, error) {
+	cached, generation := s.analytics.get(userId)
+	if languages := cached.languages; languages != nil {
+		return languages, nil
+	}
+
+	languages, err := s.db.GetUserLanguageStats(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.analytics.update(userId, generation, func(analytics *userAnalytics) {
+		analytics.languages = languages
+	})
+	return languages, nil
+}
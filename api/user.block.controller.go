@@ -0,0 +1,114 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// @Summary		Get blocked users
+// @Description	Get the users blocked by the authenticated user
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	[]types.UserResponse
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/blocks [get]
+func (s *Server) handleGetBlockedUsers(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	users, err := s.db.GetBlockedUsers(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, users)
+}
+
+// @Summary		Block user
+// @Description	Block the user with the given username. Blocked users can't invite you or join your lobbies, and you won't be paired together
+// @Tags			user
+// @Produce		json
+// @Param			username	path	string	true	"Username"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/{username}/block [post]
+func (s *Server) handleBlockUser(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	blocked, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+	if blocked.Id == authUser.Id {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "You cannot block yourself"})
+	}
+
+	log.Printf("[API] User %d blocks %d", authUser.Id, blocked.Id)
+	if err := s.db.BlockUser(authUser.Id, blocked.Id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Unblock user
+// @Description	Unblock the user with the given username
+// @Tags			user
+// @Produce		json
+// @Param			username	path	string	true	"Username"
+// @Success		204
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/{username}/block [delete]
+func (s *Server) handleUnblockUser(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	blocked, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[API] User %d unblocks %d", authUser.Id, blocked.Id)
+	if err := s.db.UnblockUser(authUser.Id, blocked.Id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Get blocked user ids
+// @Description	Get the ids of the users that must never be paired with the given user (blocked by or blocking them). Used from the matchmaking service
+// @Tags			user
+// @Produce		json
+// @Param			user_id	query		int	true	"User ID"
+// @Success		200		{object}	[]int
+// @Failure		400		{object}	Error
+// @Failure		500		{object}	Error
+// @Router			/v1/user/blocks [get]
+func (s *Server) handleGetBlockedUserIDs(w http.ResponseWriter, r *http.Request) error {
+	userId, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid user_id"})
+	}
+
+	ids, err := s.db.GetBlockedUserIDs(userId)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, ids)
+}
@@ -2,58 +2,121 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+const (
+	maxUsersBatch     = 100
+	defaultRatingMode = "speed"
 )
 
 func (s *Server) GetUserRouter() http.Handler {
 	router := http.NewServeMux()
-	router.HandleFunc("GET /user", convertToHandleFunc(s.handleGetUsers))
+	router.HandleFunc("GET /user", convertToHandleFunc(s.handleGetUsers, OptionalAuthMiddleware))
 	router.HandleFunc("POST /user", convertToHandleFunc(s.handleCreateUser, AuthMiddleware))
-	router.HandleFunc("GET /user/{username}", convertToHandleFunc(s.handleGetUserByUsername))
+	router.HandleFunc("GET /user/{username}", convertToHandleFunc(s.handleGetUserByUsername, OptionalAuthMiddleware))
 	router.HandleFunc("DELETE /user/{username}", convertToHandleFunc(s.handleDeleteUserByUsername, AuthMiddleware))
 	router.HandleFunc("GET /user/profile", convertToHandleFunc(s.handleProfile, AuthMiddleware))
+	router.HandleFunc("POST /user/profile/delete", convertToHandleFunc(s.handleRequestAccountDeletion, AuthMiddleware))
+	router.HandleFunc("DELETE /user/profile", convertToHandleFunc(s.handleDeleteAccount, AuthMiddleware))
+	router.HandleFunc("GET /user/profile/friends/playing", convertToHandleFunc(s.handleGetFriendsPlaying, AuthMiddleware))
+	router.HandleFunc("POST /user/profile/email/verify", convertToHandleFunc(s.handleSendEmailVerification, AuthMiddleware))
+	router.HandleFunc("PUT /user/profile/email", convertToHandleFunc(s.handleChangeEmail, AuthMiddleware))
+	router.HandleFunc("POST /user/email/confirm", convertToHandleFunc(s.handleConfirmEmail))
+	router.HandleFunc("GET /user/profile/preferences", convertToHandleFunc(s.handleGetPreferences, AuthMiddleware))
+	router.HandleFunc("PUT /user/profile/preferences", convertToHandleFunc(s.handleUpdatePreferences, AuthMiddleware))
+	router.HandleFunc("GET /user/profile/preferences/schema", convertToHandleFunc(s.handleGetPreferencesSchema))
+	router.HandleFunc("GET /user/profile/notifications", convertToHandleFunc(s.handleGetNotifications, AuthMiddleware))
+	router.HandleFunc("POST /user/profile/notifications/read", convertToHandleFunc(s.handleMarkAllNotificationsRead, AuthMiddleware))
+	router.HandleFunc("POST /user/profile/notifications/{id}/read", convertToHandleFunc(s.handleMarkNotificationRead, AuthMiddleware))
+	router.HandleFunc("GET /user/profile/notifications/preferences", convertToHandleFunc(s.handleGetNotificationPreferences, AuthMiddleware))
+	router.HandleFunc("PUT /user/profile/notifications/preferences", convertToHandleFunc(s.handleUpdateNotificationPreferences, AuthMiddleware))
+	router.HandleFunc("POST /user/{username}/follow", convertToHandleFunc(s.handleFollowUser, AuthMiddleware))
+	router.HandleFunc("DELETE /user/{username}/follow", convertToHandleFunc(s.handleUnfollowUser, AuthMiddleware))
+	router.HandleFunc("GET /user/{username}/followers", convertToHandleFunc(s.handleGetFollowers))
+	router.HandleFunc("GET /user/{username}/following", convertToHandleFunc(s.handleGetFollowing))
+	router.HandleFunc("GET /user/{username}/ratings/{mode}/history", convertToHandleFunc(s.handleGetRatingHistory))
+	router.HandleFunc("GET /user/{username}/versus/{opponent}", convertToHandleFunc(s.handleGetVersus, OptionalAuthMiddleware))
+	router.HandleFunc("GET /user/{username}/activity", convertToHandleFunc(s.handleGetUserActivity, OptionalAuthMiddleware))
+	router.HandleFunc("GET /user/{username}/languages", convertToHandleFunc(s.handleGetUserLanguageStats, OptionalAuthMiddleware))
+	router.HandleFunc("GET /user/profile/blocks", convertToHandleFunc(s.handleGetBlockedUsers, AuthMiddleware))
+	router.HandleFunc("POST /user/{username}/block", convertToHandleFunc(s.handleBlockUser, AuthMiddleware))
+	router.HandleFunc("DELETE /user/{username}/block", convertToHandleFunc(s.handleUnblockUser, AuthMiddleware))
+	router.HandleFunc("POST /user/profile/export", convertToHandleFunc(s.handleCreateUserExport, AuthMiddleware))
+	router.HandleFunc("GET /user/profile/export/{id}", convertToHandleFunc(s.handleGetUserExport, AuthMiddleware))
+	router.HandleFunc("GET /user/export/download/{token}", convertToHandleFunc(s.handleDownloadUserExport))
+	router.Handle("GET /user/blocks", OnlyInternalServiceMiddleware(s.config, convertToHandleFunc(s.handleGetBlockedUserIDs)))
 
 	return router
 }
 
-// @Summary		Get all users
-// @Description	Get all users from the database
+// @Summary		Get users
+// @Description	Get a page of users, optionally filtered by a search term, or a batch of users by id
 // @Tags			user
 // @Produce		json
-// @Success		200	{object}	[]types.UserResponse
-// @Failure		500	{object}	Error
+// @Param			q		query		string	false	"Search by username or name"
+// @Param			sort	query		string	false	"Sort order (newest, games, rating)"
+// @Param			mode	query		string	false	"Game mode of the rating when sorting by rating (default speed)"
+// @Param			cursor	query		string	false	"Cursor returned by the previous page"
+// @Param			limit	query		int		false	"Page size (max 100)"
+// @Param			ids		query		string	false	"Comma separated list of user ids"
+// @Success		200		{object}	types.UserListResponse
+// @Failure		400		{object}	Error
+// @Failure		500		{object}	Error
 // @Router			/v1/user [get]
 func (s *Server) handleGetUsers(w http.ResponseWriter, r *http.Request) error {
-	users := []*types.UserResponse{}
+	urlQuery := r.URL.Query()
 
-	if r.URL.Query().Get("id") != "" {
-		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	// batch lookup by ids (`id` is kept for backward compatibility)
+	if urlQuery.Has("ids") || urlQuery.Has("id") {
+		ids, err := utils.ParseIntList(urlQuery.Get("ids") + "," + urlQuery.Get("id"))
 		if err != nil {
-			return err
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
 		}
-		user, err := s.db.GetUserByID(id)
-		if err != nil {
-			return err
+		if len(ids) > maxUsersBatch {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("too many ids, max %d", maxUsersBatch)})
 		}
-		users = append(users, &types.UserResponse{
-			Name:          user.Name,
-			Username:      user.Username,
-			Avatar:        user.Avatar,
-			BackgroundImg: user.BackgroundImg,
-			Bio:           user.Bio,
-			Role:          user.Role,
-			CreatedAt:     user.CreatedAt,
-		})
-	} else {
-		dbUsers, err := s.db.GetUsers()
+
+		users, err := s.db.GetUsersByIDs(ids)
 		if err != nil {
 			return err
 		}
-		users = dbUsers
+
+		return WriteJSON(w, http.StatusOK, &types.UserListResponse{Users: users})
+	}
+
+	sort := urlQuery.Get("sort")
+	if sort != "" && sort != types.UserSortNewest && sort != types.UserSortGames && sort != types.UserSortRating {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: fmt.Sprintf("invalid sort %q", sort)})
+	}
+	if _, err := utils.DecodeCursor(urlQuery.Get("cursor")); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	listQuery := &types.UserListQuery{
+		Search: strings.TrimSpace(urlQuery.Get("q")),
+		Sort:   sort,
+		Mode:   urlQuery.Get("mode"),
+		Cursor: urlQuery.Get("cursor"),
+		Limit:  utils.ParseLimit(urlQuery.Get("limit"), 20, maxUsersBatch),
+	}
+	if listQuery.Mode == "" {
+		listQuery.Mode = defaultRatingMode
+	}
+	if authUser := GetAuthUser(r); authUser != nil {
+		listQuery.ViewerId = authUser.Id
+	}
+
+	users, err := s.db.GetUsers(listQuery)
+	if err != nil {
+		return err
 	}
 
 	return WriteJSON(w, http.StatusOK, users)
@@ -87,11 +150,12 @@ func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) error
 }
 
 // @Summary		Get user by username
-// @Description	Get user by username from the database
+// @Description	Get the profile of the user, if visible to the viewer
 // @Tags			user
 // @Produce		json
 // @Param			username	path		string	true	"Username"
-// @Success		200			{object}	types.User
+// @Success		200			{object}	types.ProfileResponse
+// @Failure		403			{object}	Error
 // @Failure		500			{object}	Error
 // @Router			/v1/user/{username} [get]
 func (s *Server) handleGetUserByUsername(w http.ResponseWriter, r *http.Request) error {
@@ -102,34 +166,234 @@ func (s *Server) handleGetUserByUsername(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, user)
+	privacy, err := s.db.GetUserPrivacy(user.Id)
+	if err != nil {
+		return err
+	}
+	canView, err := s.db.CanView(GetViewerId(r), user.Id, privacy.Profile)
+	if err != nil {
+		return err
+	}
+	if !canView {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "This profile is private"})
+	}
+
+	profile, err := s.getProfile(user, GetViewerId(r))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, profile)
 }
 
 // @Summary		Delete user by username
-// @Description	Delete user by username from the database
+// @Description	Delete the account of a user, admin only. Users delete their own account through /v1/user/profile/delete
 // @Tags			user
 // @Accept			json
 // @Produce		json
 // @Param			username	path	string	true	"Username"
-// @Success		200
+// @Success		204
+// @Failure		403	{object}	Error
 // @Failure		500	{object}	Error
 // @Router			/v1/user/{username} [delete]
-func (s *Server) handleDeleteUserByUsername(_ http.ResponseWriter, r *http.Request) error {
+func (s *Server) handleDeleteUserByUsername(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+	if authUser.Role != types.UserRoleAdmin {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "Forbidden"})
+	}
+
 	username := r.PathValue("username")
+	user, err := s.db.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+
 	log.Print("[API] Deleting user ", username)
-	return s.db.DeleteUserByUsername(username)
+	if err := s.db.DeleteUser(user.Id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Request account deletion
+// @Description	First step of the account deletion, returns a short-lived token to confirm the deletion with
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	types.DeleteAccountConfirmation
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/delete [post]
+func (s *Server) handleRequestAccountDeletion(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	token, err := utils.GenerateAccountDeletionToken(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, &types.DeleteAccountConfirmation{
+		ConfirmationToken: token.Jwt,
+		ExpiresAt:         token.ExpiresAt,
+	})
+}
+
+// @Summary		Delete account
+// @Description	Delete the account of the authenticated user, confirmed with the token from /v1/user/profile/delete. Sessions are revoked, authored challenges are reassigned and personal data is erased
+// @Tags			user
+// @Accept			json
+// @Produce		json
+// @Param			confirmation	body	types.DeleteAccountRequest	true	"Delete Account Request"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile [delete]
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	deleteAccountReq := &types.DeleteAccountRequest{}
+	if err := json.NewDecoder(r.Body).Decode(deleteAccountReq); err != nil {
+		return err
+	}
+
+	payload := &types.AccountDeletionPayload{}
+	if err := utils.ValidateAndParseJWT(deleteAccountReq.ConfirmationToken, payload); err != nil ||
+		payload.Purpose != utils.AccountDeletionPurpose || payload.UserID != authUser.Id {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid confirmation token"})
+	}
+
+	log.Printf("[API] Deleting account of user %d", authUser.Id)
+	if err := s.db.DeleteUser(authUser.Id); err != nil {
+		return err
+	}
+
+	for _, cookieName := range []string{"refresh_token", "access_token", "logged_in"} {
+		w.Header().Add("Set-Cookie", s.createCookie(cookieName, "", time.Now().Add(-1*(time.Minute*60*24))).String())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
 // @Summary		Get Profile
 // @Description	Get user profile when authenticated with JWT in the cookie
 // @Tags			user
 // @Produce		json
-// @Success		200	{object}	types.UserRequestHeader
+// @Success		200	{object}	types.ProfileResponse
 // @Failure		500	{object}	Error
 // @Security		CookieAuth
 // @Router			/v1/user/profile [get]
 func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) error {
-	user := GetAuthUser(r)
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
 
-	return WriteJSON(w, http.StatusOK, user)
+	user, err := s.db.GetUserByID(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	profile, err := s.getProfile(user, user.Id)
+	if err != nil {
+		return err
+	}
+
+	unreadNotifications, err := s.db.GetUnreadNotificationCount(user.Id)
+	if err != nil {
+		return err
+	}
+	profile.UnreadNotifications = &unreadNotifications
+
+	return WriteJSON(w, http.StatusOK, profile)
+}
+
+// getProfile collects everything shown on the profile page of `user` to the viewer (0 when anonymous)
+func (s *Server) getProfile(user *types.User, viewerId int) (*types.ProfileResponse, error) {
+	stats, err := s.db.GetUserStats(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	followCounts, err := s.db.GetFollowCounts(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := s.db.GetUserRatings(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	achievements, err := s.db.GetUserAchievements(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	streak, err := s.db.GetUserStreak(user.Id, utils.DailyDate(s.clock.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	// rivals reveal who the user played with, they follow the privacy of the match history
+	privacy, err := s.db.GetUserPrivacy(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	canViewMatches, err := s.db.CanView(viewerId, user.Id, privacy.Matches)
+	if err != nil {
+		return nil, err
+	}
+	var rivals []*types.Rival
+	if canViewMatches {
+		if rivals, err = s.db.GetUserRivals(user.Id, profileRivalsLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.ProfileResponse{
+		User:           user,
+		Stats:          stats,
+		FollowersCount: followCounts.Followers,
+		FollowingCount: followCounts.Following,
+		Ratings:        ratings,
+		Achievements:   achievements,
+		CurrentStreak:  streak.Current,
+		LongestStreak:  streak.Longest,
+		Rivals:         rivals,
+	}, nil
+}
+
+// @Summary		Get rating history
+// @Description	Get the rating of the user after each ranked match of the given mode, to draw the rating graph
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Param			mode		path		string	true	"Game mode"
+// @Success		200			{object}	[]types.RatingHistoryEntry
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/ratings/{mode}/history [get]
+func (s *Server) handleGetRatingHistory(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	history, err := s.db.GetUserRatingHistory(user.Id, r.PathValue("mode"))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, history)
 }
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// maxEmailLength is the size of user.email
+const maxEmailLength = 50
+
+// @Summary		Send verification email
+// @Description	Send a link to verify the current email address of the authenticated user
+// @Tags			user
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/email/verify [post]
+func (s *Server) handleSendEmailVerification(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	user, err := s.db.GetUserByID(authUser.Id)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "You have no email address, set one through /v1/user/profile/email"})
+	}
+	if user.EmailVerified {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "Your email address is already verified"})
+	}
+
+	token := utils.GenerateRandomToken(32)
+	if err := s.db.CreateEmailVerification(user.Id, user.Email, token, s.emailTokenExpiresAt()); err != nil {
+		return err
+	}
+
+	log.Printf("[API] Sending verification email to user %d", user.Id)
+	if err := s.mailer.Send(user.Email, "Verify your email address", fmt.Sprintf(
+		"Hi %s,\n\nopen this link to verify your email address on CodeDuel:\n%s\n",
+		user.Username, s.emailConfirmLink(token),
+	)); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Change email
+// @Description	Start changing the email address of the authenticated user. A link is sent to both the current and the new address, the change is applied when both are opened
+// @Tags			user
+// @Accept			json
+// @Param			email	body	types.ChangeEmailRequest	true	"New email address"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/email [put]
+func (s *Server) handleChangeEmail(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	body := &types.ChangeEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+	address, err := mail.ParseAddress(body.Email)
+	if err != nil || address.Address != body.Email || len(body.Email) > maxEmailLength {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid email address"})
+	}
+
+	user, err := s.db.GetUserByID(authUser.Id)
+	if err != nil {
+		return err
+	}
+	if user.Email == body.Email {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "This is already your email address"})
+	}
+
+	// a user without an address only has the new one to confirm
+	oldToken := ""
+	if user.Email != "" {
+		oldToken = utils.GenerateRandomToken(32)
+	}
+	newToken := utils.GenerateRandomToken(32)
+
+	if err := s.db.CreateEmailChange(user.Id, user.Email, body.Email, oldToken, newToken, s.emailTokenExpiresAt()); err != nil {
+		return err
+	}
+
+	log.Printf("[API] Sending email change confirmations for user %d", user.Id)
+	if oldToken != "" {
+		if err := s.mailer.Send(user.Email, "Confirm your new email address", fmt.Sprintf(
+			"Hi %s,\n\nsomeone asked to change the email address of your CodeDuel account to %s.\nOpen this link to confirm, otherwise ignore this email:\n%s\n",
+			user.Username, body.Email, s.emailConfirmLink(oldToken),
+		)); err != nil {
+			return err
+		}
+	}
+	if err := s.mailer.Send(body.Email, "Confirm your new email address", fmt.Sprintf(
+		"Hi %s,\n\nopen this link to use this address on CodeDuel:\n%s\n",
+		user.Username, s.emailConfirmLink(newToken),
+	)); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Confirm email
+// @Description	Confirm an email address with the token of the link sent to it. A change of address is applied once both addresses are confirmed
+// @Tags			user
+// @Accept			json
+// @Produce		json
+// @Param			token	body		types.ConfirmEmailRequest	true	"Token from the email"
+// @Success		200		{object}	types.ConfirmEmailResponse
+// @Failure		400		{object}	Error
+// @Router			/v1/user/email/confirm [post]
+func (s *Server) handleConfirmEmail(w http.ResponseWriter, r *http.Request) error {
+	body := &types.ConfirmEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil || body.Token == "" {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+
+	response, err := s.db.ConfirmEmailToken(body.Token)
+	if err != nil {
+		log.Printf("%s%s %s", utils.GetLogTag("API"), utils.GetLogTag("warn"), err.Error())
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "Invalid or expired link"})
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) emailTokenExpiresAt() time.Time {
+	return time.Now().Add(time.Minute * time.Duration(s.config.EmailTokenExpiresInMinutes))
+}
+
+// emailConfirmLink is the frontend page that posts the token to /v1/user/email/confirm
+func (s *Server) emailConfirmLink(token string) string {
+	return s.config.FrontendURL + "/email/confirm?token=" + url.QueryEscape(token)
+}
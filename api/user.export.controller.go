@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// @Summary		Request data export
+// @Description	Start assembling a ZIP archive with everything stored about the authenticated user. Poll the returned export until it is ready, then download it before it expires
+// @Tags			user
+// @Produce		json
+// @Success		202	{object}	types.UserExport
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/export [post]
+func (s *Server) handleCreateUserExport(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	export := &types.UserExport{
+		UserId: authUser.Id,
+		Token:  utils.GenerateRandomToken(32),
+	}
+	expiresAt := time.Now().Add(time.Minute * time.Duration(s.config.UserExportExpiresInMinutes))
+	if err := s.db.CreateUserExport(export, expiresAt); err != nil {
+		return err
+	}
+
+	log.Printf("[API] User %d requested data export %d", authUser.Id, export.Id)
+	go RunUserExport(s.db, export)
+
+	return WriteJSON(w, http.StatusAccepted, export)
+}
+
+// @Summary		Get data export
+// @Description	Get the status of a data export of the authenticated user, with the download link once ready
+// @Tags			user
+// @Produce		json
+// @Param			id	path		int	true	"Export ID"
+// @Success		200	{object}	types.UserExport
+// @Failure		404	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/export/{id} [get]
+func (s *Server) handleGetUserExport(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+
+	export, err := s.db.GetUserExport(id)
+	if err != nil || export.UserId != authUser.Id {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Export not found"})
+	}
+
+	if export.Status == types.UserExportReady {
+		export.DownloadUrl = fmt.Sprintf("/v1/user/export/download/%s", export.Token)
+	}
+
+	return WriteJSON(w, http.StatusOK, export)
+}
+
+// @Summary		Download data export
+// @Description	Download the ZIP archive of a data export, the link expires with the export
+// @Tags			user
+// @Produce		application/zip
+// @Param			token	path	string	true	"Export token"
+// @Success		200
+// @Failure		404	{object}	Error
+// @Router			/v1/user/export/download/{token} [get]
+func (s *Server) handleDownloadUserExport(w http.ResponseWriter, r *http.Request) error {
+	archive, err := s.db.GetUserExportArchive(r.PathValue("token"))
+	if err != nil {
+		return WriteJSON(w, http.StatusNotFound, Error{Err: "Export not found or expired"})
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="codeduel-export.zip"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(archive)
+	return err
+}
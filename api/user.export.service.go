@@ -0,0 +1,79 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/db"
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// BuildUserExportArchive creates a ZIP with a JSON file for each kind of data held about the user
+func BuildUserExportArchive(data *types.UserExportData) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"user.json", data.User},
+		{"auth.json", data.Auth},
+		{"stats.json", data.Stats},
+		{"challenges.json", data.Challenges},
+		{"submissions.json", data.Submissions},
+		{"sessions.json", data.Sessions},
+	}
+
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// RunUserExport assembles the archive of a pending export, it is meant to run in the background
+func RunUserExport(db db.DB, export *types.UserExport) {
+	fail := func(err error) {
+		log.Printf("%s%s export %d failed: %s", utils.GetLogTag("export"), utils.GetLogTag("error"), export.Id, err.Error())
+		if err := db.FailUserExport(export.Id, "failed to assemble the archive"); err != nil {
+			log.Printf("%s%s %s", utils.GetLogTag("export"), utils.GetLogTag("error"), err.Error())
+		}
+	}
+
+	data, err := db.GetUserExportData(export.UserId)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	archive, err := BuildUserExportArchive(data)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := db.CompleteUserExport(export.Id, archive); err != nil {
+		fail(err)
+		return
+	}
+
+	log.Printf("%s export %d ready (%d bytes)", utils.GetLogTag("export"), export.Id, len(archive))
+}
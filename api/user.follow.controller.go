@@ -0,0 +1,149 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/xedom/codeduel/utils"
+)
+
+// @Summary		Follow user
+// @Description	Follow the user with the given username
+// @Tags			user
+// @Produce		json
+// @Param			username	path	string	true	"Username"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		403	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/{username}/follow [post]
+func (s *Server) handleFollowUser(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	followee, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+	if followee.Id == authUser.Id {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "You cannot follow yourself"})
+	}
+
+	blocked, err := s.db.HasBlockBetween([]int{authUser.Id, followee.Id})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return WriteJSON(w, http.StatusForbidden, Error{Err: "You cannot follow this user"})
+	}
+
+	log.Printf("[API] User %d follows %d", authUser.Id, followee.Id)
+	if err := s.db.FollowUser(authUser.Id, followee.Id); err != nil {
+		return err
+	}
+	s.events.Publish(&Event{Type: EventUserFollowed, UserIds: []int{followee.Id}, ActorId: authUser.Id})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Unfollow user
+// @Description	Stop following the user with the given username
+// @Tags			user
+// @Produce		json
+// @Param			username	path	string	true	"Username"
+// @Success		204
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/{username}/follow [delete]
+func (s *Server) handleUnfollowUser(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	followee, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[API] User %d unfollows %d", authUser.Id, followee.Id)
+	if err := s.db.UnfollowUser(authUser.Id, followee.Id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Get followers
+// @Description	Get a page of the users following the given user
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Param			cursor		query		string	false	"Cursor returned by the previous page"
+// @Param			limit		query		int		false	"Page size (max 100)"
+// @Success		200			{object}	types.UserListResponse
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/followers [get]
+func (s *Server) handleGetFollowers(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	followers, err := s.db.GetFollowers(user.Id, r.URL.Query().Get("cursor"), utils.ParseLimit(r.URL.Query().Get("limit"), 20, maxUsersBatch))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, followers)
+}
+
+// @Summary		Get following
+// @Description	Get a page of the users followed by the given user
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Param			cursor		query		string	false	"Cursor returned by the previous page"
+// @Param			limit		query		int		false	"Page size (max 100)"
+// @Success		200			{object}	types.UserListResponse
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/following [get]
+func (s *Server) handleGetFollowing(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.db.GetUserByUsername(r.PathValue("username"))
+	if err != nil {
+		return err
+	}
+
+	following, err := s.db.GetFollowing(user.Id, r.URL.Query().Get("cursor"), utils.ParseLimit(r.URL.Query().Get("limit"), 20, maxUsersBatch))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, following)
+}
+
+// @Summary		Friends playing now
+// @Description	Get the friends (users that follow each other) of the authenticated user that are currently in a match
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	[]types.FriendPlaying
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/friends/playing [get]
+func (s *Server) handleGetFriendsPlaying(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	friends, err := s.db.GetFriendsPlaying(authUser.Id)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, friends)
+}
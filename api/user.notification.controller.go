@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// @Summary		Get notifications
+// @Description	Get a page of the notifications of the authenticated user, newest first
+// @Tags			user
+// @Produce		json
+// @Param			unread	query		bool	false	"Only unread notifications"
+// @Param			cursor	query		string	false	"Cursor returned by the previous page"
+// @Param			limit	query		int		false	"Page size (max 100)"
+// @Success		200		{object}	types.NotificationListResponse
+// @Failure		500		{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/notifications [get]
+func (s *Server) handleGetNotifications(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	urlQuery := r.URL.Query()
+	notifications, err := s.db.GetNotifications(
+		authUser.Id,
+		urlQuery.Get("unread") == "true",
+		urlQuery.Get("cursor"),
+		utils.ParseLimit(urlQuery.Get("limit"), 20, 100),
+	)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, notifications)
+}
+
+// @Summary		Mark notification as read
+// @Description	Mark a notification of the authenticated user as read
+// @Tags			user
+// @Param			id	path	int	true	"Notification id"
+// @Success		204
+// @Failure		400	{object}	Error
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/notifications/{id}/read [post]
+func (s *Server) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid notification id"})
+	}
+
+	if err := s.db.MarkNotificationRead(authUser.Id, id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Mark all notifications as read
+// @Description	Mark every notification of the authenticated user as read
+// @Tags			user
+// @Success		204
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/notifications/read [post]
+func (s *Server) handleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	if err := s.db.MarkAllNotificationsRead(authUser.Id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// @Summary		Get notification preferences
+// @Description	Get, for each notification type, whether the authenticated user receives it
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	types.NotificationPreferences
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/notifications/preferences [get]
+func (s *Server) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	preferences, err := s.db.GetNotificationPreferences(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, preferences)
+}
+
+// @Summary		Update notification preferences
+// @Description	Opt in or out of notification types, the types not in the body are left unchanged
+// @Tags			user
+// @Accept			json
+// @Produce		json
+// @Param			preferences	body		types.NotificationPreferences	true	"Notification type to enabled"
+// @Success		200			{object}	types.NotificationPreferences
+// @Failure		400			{object}	Error
+// @Failure		500			{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/notifications/preferences [put]
+func (s *Server) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	preferences := types.NotificationPreferences{}
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body"})
+	}
+	for notificationType := range preferences {
+		if !types.IsNotificationType(notificationType) {
+			return WriteJSON(w, http.StatusBadRequest, Error{Err: "unknown notification type " + notificationType})
+		}
+	}
+
+	if err := s.db.UpdateNotificationPreferences(authUser.Id, preferences); err != nil {
+		return err
+	}
+
+	updated, err := s.db.GetNotificationPreferences(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, updated)
+}
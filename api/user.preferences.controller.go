@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// @Summary		Get preferences
+// @Description	Get the preferences of the authenticated user, the defaults if never saved
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	types.UserPreferences
+// @Failure		500	{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/preferences [get]
+func (s *Server) handleGetPreferences(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	preferences, err := s.db.GetUserPreferences(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, preferences)
+}
+
+// @Summary		Update preferences
+// @Description	Replace the preferences of the authenticated user. The body must match the schema of the current version, see /v1/user/profile/preferences/schema. Notification types not in the body are left unchanged
+// @Tags			user
+// @Accept			json
+// @Produce		json
+// @Param			preferences	body		types.UserPreferences	true	"Preferences"
+// @Success		200			{object}	types.UserPreferences
+// @Failure		400			{object}	Error
+// @Failure		500			{object}	Error
+// @Security		CookieAuth
+// @Router			/v1/user/profile/preferences [put]
+func (s *Server) handleUpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	authUser := GetAuthUser(r)
+	if authUser == nil {
+		return WriteJSON(w, http.StatusUnauthorized, Error{Err: "Unauthorized"})
+	}
+
+	preferences := &types.UserPreferences{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(preferences); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "invalid request body: " + err.Error()})
+	}
+	if err := validateUserPreferences(preferences); err != nil {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: err.Error()})
+	}
+
+	if err := s.db.UpdateUserPreferences(authUser.Id, preferences); err != nil {
+		return err
+	}
+
+	updated, err := s.db.GetUserPreferences(authUser.Id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, updated)
+}
+
+// @Summary		Get preferences schema
+// @Description	Get the JSON schema of the current version of the preferences
+// @Tags			user
+// @Produce		json
+// @Success		200	{object}	object
+// @Router			/v1/user/profile/preferences/schema [get]
+func (s *Server) handleGetPreferencesSchema(w http.ResponseWriter, r *http.Request) error {
+	return WriteJSON(w, http.StatusOK, json.RawMessage(types.UserPreferencesSchema))
+}
+
+// validateUserPreferences checks what types.UserPreferencesSchema describes
+func validateUserPreferences(preferences *types.UserPreferences) error {
+	if preferences.Version != types.UserPreferencesVersion {
+		return fmt.Errorf("preferences version %d is not supported, expected %d", preferences.Version, types.UserPreferencesVersion)
+	}
+	if len(preferences.DefaultLanguage) > 50 {
+		return fmt.Errorf("default_language is too long")
+	}
+
+	if preferences.Editor.Theme == "" {
+		preferences.Editor.Theme = types.EditorThemeSystem
+	}
+	if !slices.Contains([]string{types.EditorThemeSystem, types.EditorThemeLight, types.EditorThemeDark}, preferences.Editor.Theme) {
+		return fmt.Errorf("unknown editor theme %q", preferences.Editor.Theme)
+	}
+
+	if preferences.Editor.Keybindings == "" {
+		preferences.Editor.Keybindings = types.EditorKeybindingsDefault
+	}
+	if !slices.Contains([]string{types.EditorKeybindingsDefault, types.EditorKeybindingsVim, types.EditorKeybindingsEmacs}, preferences.Editor.Keybindings) {
+		return fmt.Errorf("unknown editor keybindings %q", preferences.Editor.Keybindings)
+	}
+
+	for notificationType := range preferences.Notifications {
+		if !types.IsNotificationType(notificationType) {
+			return fmt.Errorf("unknown notification type %q", notificationType)
+		}
+	}
+
+	privacy := []struct {
+		name       string
+		visibility *string
+	}{
+		{"profile", &preferences.Privacy.Profile},
+		{"matches", &preferences.Privacy.Matches},
+		{"code", &preferences.Privacy.Code},
+	}
+	for _, setting := range privacy {
+		if *setting.visibility == "" {
+			*setting.visibility = types.VisibilityPublic
+		}
+		if !slices.Contains([]string{types.VisibilityPublic, types.VisibilityFollowers, types.VisibilityPrivate}, *setting.visibility) {
+			return fmt.Errorf("unknown %s visibility %q", setting.name, *setting.visibility)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// number of rivals shown on the profile
+const profileRivalsLimit = 3
+
+// @Summary		Get head-to-head record
+// @Description	Get every ended match the two users played together, with the record of the first user against the second, overall and per mode
+// @Tags			user
+// @Produce		json
+// @Param			username	path		string	true	"Username"
+// @Param			opponent	path		string	true	"Username of the opponent"
+// @Success		200			{object}	types.VersusResponse
+// @Failure		400			{object}	Error
+// @Failure		403			{object}	Error
+// @Failure		500			{object}	Error
+// @Router			/v1/user/{username}/versus/{opponent} [get]
+func (s *Server) handleGetVersus(w http.ResponseWriter, r *http.Request) error {
+	if r.PathValue("username") == r.PathValue("opponent") {
+		return WriteJSON(w, http.StatusBadRequest, Error{Err: "A user can't play against themselves"})
+	}
+
+	users := make([]*types.User, 2)
+	for i, username := range []string{r.PathValue("username"), r.PathValue("opponent")} {
+		user, err := s.db.GetUserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		privacy, err := s.db.GetUserPrivacy(user.Id)
+		if err != nil {
+			return err
+		}
+		canView, err := s.db.CanView(GetViewerId(r), user.Id, privacy.Matches)
+		if err != nil {
+			return err
+		}
+		if !canView {
+			return WriteJSON(w, http.StatusForbidden, Error{Err: "The match history of " + user.Username + " is private"})
+		}
+
+		users[i] = user
+	}
+
+	matches, err := s.db.GetVersusMatches(users[0].Id, users[1].Id)
+	if err != nil {
+		return err
+	}
+
+	responses, err := s.db.GetUsersByIDs([]int{users[0].Id, users[1].Id})
+	if err != nil {
+		return err
+	}
+
+	versus := buildVersusResponse(matches)
+	for _, user := range responses {
+		if user.Id == users[0].Id {
+			versus.User = user
+		} else {
+			versus.Opponent = user
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, versus)
+}
+
+// buildVersusResponse sums up the matches in the overall and per mode records
+func buildVersusResponse(matches []*types.VersusMatch) *types.VersusResponse {
+	versus := &types.VersusResponse{
+		Modes:   []*types.VersusModeRecord{},
+		Matches: matches,
+	}
+
+	modes := map[string]*types.VersusModeRecord{}
+	for _, match := range matches {
+		mode, ok := modes[match.Mode]
+		if !ok {
+			mode = &types.VersusModeRecord{Mode: match.Mode}
+			modes[match.Mode] = mode
+			versus.Modes = append(versus.Modes, mode)
+		}
+
+		for _, record := range []*types.VersusRecord{&versus.Overall, &mode.VersusRecord} {
+			record.Matches++
+			switch match.Result {
+			case types.VersusWin:
+				record.Wins++
+			case types.VersusLoss:
+				record.Losses++
+			default:
+				record.Ties++
+			}
+
+			// summed here, averaged below
+			record.AvgTestsPassed += float64(match.TestsPassed)
+			record.OpponentAvgTestsPassed += float64(match.OpponentTestsPassed)
+		}
+	}
+
+	records := []*types.VersusRecord{&versus.Overall}
+	for _, mode := range versus.Modes {
+		records = append(records, &mode.VersusRecord)
+	}
+	for _, record := range records {
+		if record.Matches > 0 {
+			record.AvgTestsPassed /= float64(record.Matches)
+			record.OpponentAvgTestsPassed /= float64(record.Matches)
+		}
+	}
+
+	return versus
+}
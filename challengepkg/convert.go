@@ -0,0 +1,333 @@
+package challengepkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// formats of the problems converted from other platforms, FormatCodeDuel is the package format itself
+const (
+	FormatCodeDuel = "codeduel"
+	FormatPolygon  = "polygon"
+	FormatKattis   = "kattis"
+	FormatLeetCode = "leetcode"
+)
+
+func IsConvertibleFormat(format string) bool {
+	return format == FormatPolygon || format == FormatKattis || format == FormatLeetCode
+}
+
+// MaxConvertSize is the maximum size of the ZIP archive or the JSON file of a problem of another platform
+const MaxConvertSize = 32 << 20
+
+// MaxConvertUncompressedSize is the maximum uncompressed size of the files of an archive to convert.
+// Their tests are often larger than a challenge allows, the ones that don't fit are skipped without reading them past the limit.
+const MaxConvertUncompressedSize = 256 << 20
+
+// Convert converts the problem of another platform in `data`,
+// a ZIP archive for Polygon and Kattis or a JSON file for LeetCode
+func Convert(format string, data []byte) (*Package, *types.ChallengeConversionReport, error) {
+	if len(data) > MaxConvertSize {
+		return nil, nil, fmt.Errorf("the problem is larger than %d bytes", MaxConvertSize)
+	}
+	if format == FormatLeetCode {
+		return convert(format, nil, data)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid zip archive: %s", err.Error())
+	}
+
+	var size uint64
+	for _, file := range archive.File {
+		size += file.UncompressedSize64
+	}
+	if size > MaxConvertUncompressedSize {
+		return nil, nil, fmt.Errorf("the problem is larger than %d bytes uncompressed", MaxConvertUncompressedSize)
+	}
+
+	return convert(format, archive, nil)
+}
+
+// ConvertPath converts the problem of another platform at `name`, a directory or a file as for Convert
+func ConvertPath(format, name string) (*Package, *types.ChallengeConversionReport, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() && format != FormatLeetCode {
+		return convert(format, os.DirFS(name), nil)
+	}
+
+	if info.Size() > MaxConvertSize {
+		return nil, nil, fmt.Errorf("%s is larger than %d bytes", name, MaxConvertSize)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Convert(format, data)
+}
+
+func convert(format string, fsys fs.FS, data []byte) (*Package, *types.ChallengeConversionReport, error) {
+	c := &converter{
+		fsys:    fsys,
+		pkg:     &Package{Manifest: Manifest{Tags: []string{}}, Tests: []types.TestCase{}, HiddenTests: []types.TestCase{}},
+		report:  &types.ChallengeConversionReport{Format: format, Unconverted: []string{}},
+		skipped: map[string][]string{},
+	}
+
+	var err error
+	switch format {
+	case FormatPolygon:
+		err = c.polygon()
+	case FormatKattis:
+		err = c.kattis()
+	case FormatLeetCode:
+		err = c.leetCode(data)
+	default:
+		err = fmt.Errorf("unknown format %q, expected %s, %s or %s", format, FormatPolygon, FormatKattis, FormatLeetCode)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.finish()
+	return c.pkg, c.report, nil
+}
+
+// converter builds a package from a problem of another platform, noting in the report whatever doesn't fit
+type converter struct {
+	fsys   fs.FS
+	pkg    *Package
+	report *types.ChallengeConversionReport
+
+	publicSize, hiddenSize int // bytes of the tests so far
+	skippedReasons         []string
+	skipped                map[string][]string // names of the tests left out by reason
+}
+
+func (c *converter) unconverted(format string, args ...any) {
+	c.report.Unconverted = append(c.report.Unconverted, fmt.Sprintf(format, args...))
+}
+
+// skip leaves the test out, the tests skipped for the same reason are reported together
+func (c *converter) skip(reason, name string) {
+	if _, ok := c.skipped[reason]; !ok {
+		c.skippedReasons = append(c.skippedReasons, reason)
+	}
+	c.skipped[reason] = append(c.skipped[reason], name)
+}
+
+// root moves the converter in the directory of the problem, the root or its only directory when the root has no `marker`
+func (c *converter) root(marker string) error {
+	root, err := findRoot(c.fsys, marker)
+	if err != nil {
+		return err
+	}
+	c.fsys = root
+	return nil
+}
+
+func (c *converter) exists(name string) bool {
+	_, err := fs.Stat(c.fsys, name)
+	return err == nil
+}
+
+// readTestFile reads at most MaxTestCaseFieldSize bytes, larger files are reported by `tooLarge` without reading the rest
+func (c *converter) readTestFile(name string) (data []byte, tooLarge bool, err error) {
+	file, err := c.fsys.Open(name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	data, err = io.ReadAll(io.LimitReader(file, types.MaxTestCaseFieldSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	return data, len(data) > types.MaxTestCaseFieldSize, nil
+}
+
+// addTestFiles adds the test read from the input and output files when it fits in the challenge
+func (c *converter) addTestFiles(hidden bool, name, inputName, outputName string) error {
+	if c.testCount(hidden) >= types.MaxTestCases {
+		c.skip(fmt.Sprintf("over the limit of %d %s tests", types.MaxTestCases, visibility(hidden)), name)
+		return nil
+	}
+
+	input, inputTooLarge, err := c.readTestFile(inputName)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.skip("missing their input file", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output, outputTooLarge, err := c.readTestFile(outputName)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.skip("missing their output file", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if inputTooLarge || outputTooLarge {
+		c.skip(fmt.Sprintf("with an input or output larger than %d bytes", types.MaxTestCaseFieldSize), name)
+		return nil
+	}
+
+	c.addTestCase(hidden, types.TestCase{Name: name, Input: string(input), Output: string(output)})
+	return nil
+}
+
+// addTestCase adds the test when it fits in the challenge
+func (c *converter) addTestCase(hidden bool, testCase types.TestCase) {
+	testCase.Name = truncate(testCase.Name, types.MaxTestCaseNameLength)
+	list, size := &c.pkg.Tests, &c.publicSize
+	if hidden {
+		list, size = &c.pkg.HiddenTests, &c.hiddenSize
+	}
+
+	switch testSize := len(testCase.Name) + len(testCase.Input) + len(testCase.Output); {
+	case len(*list) >= types.MaxTestCases:
+		c.skip(fmt.Sprintf("over the limit of %d %s tests", types.MaxTestCases, visibility(hidden)), testCase.Name)
+	case len(testCase.Input) > types.MaxTestCaseFieldSize || len(testCase.Output) > types.MaxTestCaseFieldSize:
+		c.skip(fmt.Sprintf("with an input or output larger than %d bytes", types.MaxTestCaseFieldSize), testCase.Name)
+	case *size+testSize > types.MaxTestCasesSize:
+		c.skip(fmt.Sprintf("over the limit of %d bytes of %s tests", types.MaxTestCasesSize, visibility(hidden)), testCase.Name)
+	default:
+		*list = append(*list, testCase)
+		*size += testSize
+	}
+}
+
+func (c *converter) testCount(hidden bool) int {
+	if hidden {
+		return len(c.pkg.HiddenTests)
+	}
+	return len(c.pkg.Tests)
+}
+
+func visibility(hidden bool) string {
+	if hidden {
+		return types.TestCaseVisibilityHidden
+	}
+	return types.TestCaseVisibilityPublic
+}
+
+// finish fills what the other platforms don't have and fits the manifest in the limits of a challenge
+func (c *converter) finish() {
+	for _, reason := range c.skippedReasons {
+		c.unconverted("tests %s were left out: %s", reason, strings.Join(c.skipped[reason], ", "))
+	}
+
+	manifest := &c.pkg.Manifest
+	manifest.FormatVersion = FormatVersion
+	manifest.Title = strings.TrimSpace(manifest.Title)
+	if len(manifest.Title) > types.MaxChallengeTitleLength {
+		manifest.Title = truncate(manifest.Title, types.MaxChallengeTitleLength)
+		c.unconverted("title shortened to %q", manifest.Title)
+	}
+	if manifest.Description == "" {
+		manifest.Description = summarize(c.pkg.Statement)
+	}
+	if !types.IsChallengeDifficulty(manifest.Difficulty) {
+		manifest.Difficulty = types.DifficultyMedium
+		c.unconverted("difficulty set to %s, %s problems don't have one", manifest.Difficulty, c.report.Format)
+	}
+
+	tags := []string{}
+	for _, tag := range manifest.Tags {
+		if slug := tagSlug(tag); slug != "" && !slices.Contains(tags, slug) {
+			tags = append(tags, slug)
+		}
+	}
+	manifest.Tags = tags
+	c.pkg.Statement = strings.TrimSpace(c.pkg.Statement) + "\n"
+}
+
+func findRoot(fsys fs.FS, marker string) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, marker); err == nil {
+		return fsys, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		if _, err := fs.Stat(fsys, path.Join(entries[0].Name(), marker)); err == nil {
+			return fs.Sub(fsys, entries[0].Name())
+		}
+	}
+	return nil, fmt.Errorf("%s not found", marker)
+}
+
+// truncate cuts the text to at most `max` bytes, on a space when there is one
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	const ellipsis = "..."
+	cut := strings.ToValidUTF8(text[:max-len(ellipsis)], "")
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimSpace(cut) + ellipsis
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^#+\s`)
+	markdownMarks   = regexp.MustCompile("[*_`]+")
+)
+
+// summarize returns the first paragraph of the statement as plain text, fitting in a description
+func summarize(statement string) string {
+	for _, paragraph := range strings.Split(statement, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" || markdownHeading.MatchString(paragraph) || strings.HasPrefix(paragraph, "```") {
+			continue
+		}
+		text := strings.Join(strings.Fields(markdownMarks.ReplaceAllString(paragraph, "")), " ")
+		return truncate(text, types.MaxChallengeDescriptionLength)
+	}
+	return ""
+}
+
+// tagAliases maps the usual tags of the other platforms to the slugs of the catalogue
+var tagAliases = map[string]string{
+	"array":               "arrays",
+	"string":              "strings",
+	"mathematics":         "math",
+	"number-theory":       "math",
+	"sortings":            "sorting",
+	"dynamic-programming": "dp",
+	"graph":               "graphs",
+	"graph-theory":        "graphs",
+	"tree":                "trees",
+	"binary-tree":         "trees",
+}
+
+var tagSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+func tagSlug(tag string) string {
+	slug := strings.Trim(tagSeparators.ReplaceAllString(strings.ToLower(tag), "-"), "-")
+	if alias, ok := tagAliases[slug]; ok {
+		return alias
+	}
+	return slug
+}
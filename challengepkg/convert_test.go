@@ -0,0 +1,158 @@
+package challengepkg
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xedom/codeduel/types"
+)
+
+func testNames(testCases []types.TestCase) []string {
+	names := []string{}
+	for _, testCase := range testCases {
+		names = append(names, testCase.Name)
+	}
+	return names
+}
+
+// checkConversion checks the converted package is valid, has the tests named `public` and `hidden`,
+// and that the report notes every part of `unconverted`
+func checkConversion(t *testing.T, p *Package, report *types.ChallengeConversionReport, public, hidden, unconverted []string) {
+	t.Helper()
+	if err := p.Validate(); err != nil {
+		t.Errorf("the converted package is invalid: %s", err)
+	}
+	if got := testNames(p.Tests); !slices.Equal(got, public) {
+		t.Errorf("public tests = %q, want %q", got, public)
+	}
+	if got := testNames(p.HiddenTests); !slices.Equal(got, hidden) {
+		t.Errorf("hidden tests = %q, want %q", got, hidden)
+	}
+	for _, part := range unconverted {
+		if !slices.ContainsFunc(report.Unconverted, func(line string) bool { return strings.Contains(line, part) }) {
+			t.Errorf("the report doesn't mention %q: %q", part, report.Unconverted)
+		}
+	}
+}
+
+func TestConvertPolygon(t *testing.T) {
+	archive := zipFiles(t, map[string]string{
+		"sum/problem.xml": `<?xml version="1.0" encoding="utf-8"?>
+<problem short-name="sum">
+  <names><name language="english" value="Sum of two"/></names>
+  <statements><statement language="english" path="statements/english/problem.tex" type="application/x-tex"/></statements>
+  <judging input-file="" output-file="">
+    <testset name="tests">
+      <time-limit>2000</time-limit>
+      <memory-limit>268435456</memory-limit>
+      <input-path-pattern>tests/%02d</input-path-pattern>
+      <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+      <tests>
+        <test method="manual" sample="true"/>
+        <test method="manual"/>
+        <test method="generated" cmd="gen 10"/>
+      </tests>
+    </testset>
+  </judging>
+  <assets><checker name="std::wcmp.cpp"/></assets>
+  <tags><tag value="math"/></tags>
+</problem>`,
+		"sum/statement-sections/english/legend.tex": "Add $$$a$$$ and $$$b$$$. % a comment\n",
+		"sum/statement-sections/english/input.tex":  "Two integers \\textbf{a} and \\textbf{b}.",
+		"sum/statement-sections/english/output.tex": "Their sum.",
+		"sum/tests/01":   "1 2\n",
+		"sum/tests/01.a": "3\n",
+		"sum/tests/02":   "5 5\n",
+		"sum/tests/02.a": "10\n",
+	})
+
+	p, report, err := Convert(FormatPolygon, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Manifest.Title != "Sum of two" || !slices.Equal(p.Manifest.Tags, []string{"math"}) {
+		t.Errorf("manifest = %+v, want the english name and the tags", p.Manifest)
+	}
+	if want := "Add $a$ and $b$.\n\n## Input\n\nTwo integers **a** and **b**.\n\n## Output\n\nTheir sum.\n"; p.Statement != want {
+		t.Errorf("statement = %q, want %q", p.Statement, want)
+	}
+	if p.Tests[0].Input != "1 2\n" || p.Tests[0].Output != "3\n" {
+		t.Errorf("sample = %+v, want the files of test 1", p.Tests[0])
+	}
+	checkConversion(t, p, report, []string{"sample 1"}, []string{"test 2"}, []string{
+		"tests generated by the package and missing from it were left out: test 3",
+		"time limit of 2000 ms and memory limit of 256 MB",
+		"difficulty set to medium",
+	})
+}
+
+func TestConvertKattis(t *testing.T) {
+	archive := zipFiles(t, map[string]string{
+		"problem.yaml":                      "source: NWERC\nlicense: cc by-sa\nkeywords: graph tree\n",
+		"problem_statement/problem.en.tex":  "\\problemname{Paths}\nCount the paths of the graph.\n\\section*{Input}\nThe edges.",
+		"problem_statement/problem.de.md":   "Zähle die Wege.",
+		"data/sample/1.in":                  "2\n",
+		"data/sample/1.ans":                 "1\n",
+		"data/secret/group1/big.in":         "10\n",
+		"data/secret/group1/big.ans":        "9\n",
+		"data/secret/group1/testdata.yaml":  "grading: default\n",
+		"data/secret/lonely.in":             "3\n",
+		"output_validators/validate/val.cc": "int main() {}",
+	})
+
+	p, report, err := Convert(FormatKattis, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Manifest.Title != "Paths" || !slices.Equal(p.Manifest.Tags, []string{"graphs", "trees"}) {
+		t.Errorf("manifest = %+v, want the name of the statement and the tags", p.Manifest)
+	}
+	if want := "Count the paths of the graph.\n\n## Input\n\nThe edges.\n\n*Source: NWERC, License: cc by-sa*\n"; p.Statement != want {
+		t.Errorf("statement = %q, want %q", p.Statement, want)
+	}
+	checkConversion(t, p, report, []string{"1"}, []string{"group1 big"}, []string{
+		"tests missing their output file were left out: lonely",
+		"output validators",
+		"test groups and testdata.yaml settings of data/secret",
+	})
+}
+
+func TestConvertLeetCode(t *testing.T) {
+	question := `{"data": {"question": {
+		"title": "Two Sum",
+		"titleSlug": "two-sum",
+		"difficulty": "Easy",
+		"content": "<p>Return the indices of the two numbers of <code>nums</code> adding up to <code>target</code>.</p>\n<pre><strong>Input:</strong> nums = [2,7,11,15], target = 9\n<strong>Output:</strong> [0,1]\n</pre>\n<pre><strong>Input:</strong> nums = [3,2,4], target = 6\n<strong>Output:</strong> [1,2]\n</pre>",
+		"exampleTestcases": "[2,7,11,15]\n9\n[3,2,4]\n6",
+		"metaData": "{\"name\": \"twoSum\", \"params\": [{\"name\": \"nums\"}, {\"name\": \"target\"}]}",
+		"hints": ["Use a <b>map</b>."],
+		"topicTags": [{"name": "Array", "slug": "array"}, {"name": "Hash Table", "slug": "hash-table"}],
+		"codeSnippets": [{"langSlug": "go"}],
+		"hiddenTestCases": [{"input": "[3,3]\n6\n", "output": "[0,1]\n"}]
+	}}}`
+
+	p, report, err := Convert(FormatLeetCode, []byte(question))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Manifest.Title != "Two Sum" || p.Manifest.Difficulty != types.DifficultyEasy || !slices.Equal(p.Manifest.Tags, []string{"arrays", "hash-table"}) {
+		t.Errorf("manifest = %+v, want the title, the difficulty and the tags", p.Manifest)
+	}
+	if !strings.HasPrefix(p.Statement, "Return the indices of the two numbers of `nums`") || !strings.HasSuffix(p.Statement, "## Hints\n\n- Use a **map**.\n") {
+		t.Errorf("statement = %q, want the content then the hints", p.Statement)
+	}
+	if want := (types.TestCase{Name: "example 1", Input: "[2,7,11,15]\n9\n", Output: "[0,1]\n"}); p.Tests[0] != want {
+		t.Errorf("first example = %+v, want %+v", p.Tests[0], want)
+	}
+	checkConversion(t, p, report, []string{"example 1", "example 2"}, []string{"test 1"}, []string{
+		"function signature of twoSum",
+		"starter code in 1 languages",
+	})
+}
+
+func TestConvertUnknownFormat(t *testing.T) {
+	if _, _, err := Convert("codeforces", zipFiles(t, map[string]string{"problem.xml": ""})); err == nil {
+		t.Errorf("an unknown format was converted")
+	}
+}
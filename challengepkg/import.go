@@ -0,0 +1,203 @@
+package challengepkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// Store is the part of the database an import needs
+type Store interface {
+	GetTags() ([]*types.Tag, error)
+	GetChallengeByIDFull(int) (*types.ChallengeFull, error)
+	CreateChallenge(*types.Challenge) error
+	DeleteChallenge(int) error
+	UpdateChallenge(*types.Challenge, int) error
+	SetChallengeTags(int, []string) error
+	GetLanguages() ([]*types.Language, error)
+	GetChallengeTemplates(int) ([]*types.ChallengeTemplate, error)
+}
+
+type ImportOptions struct {
+	ChallengeId int  // challenge updated by the package, 0 creates a new one
+	AuthorId    int  // owner of a new challenge, author of the revision otherwise
+	DryRun      bool // only compute the diff
+
+	// Conversion is the report of a package converted from another platform, its tags missing
+	// from the catalogue or over the limit are dropped and noted in the report instead of refusing it
+	Conversion *types.ChallengeConversionReport
+}
+
+// Import validates the package and creates or updates the challenge with it, the changes, templates included, are saved as a new revision.
+// A challenge created by the import is deleted if its tags can't be saved.
+// Returns a *ValidationError when the package is invalid.
+func Import(store Store, p *Package, options ImportOptions) (*types.ChallengeImportResult, error) {
+	tags, err := store.GetTags()
+	if err != nil {
+		return nil, err
+	}
+	if options.Conversion != nil {
+		keepCatalogueTags(p, tags, options.Conversion)
+	}
+
+	problems := []string{}
+	if err := p.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+	for _, slug := range p.Manifest.Tags {
+		if !slices.ContainsFunc(tags, func(tag *types.Tag) bool { return tag.Slug == slug }) {
+			problems = append(problems, fmt.Sprintf("unknown tag %q", slug))
+		}
+	}
+	languages, err := store.GetLanguages()
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range p.Templates {
+		if !slices.ContainsFunc(languages, func(language *types.Language) bool { return language.Name == template.Language }) {
+			problems = append(problems, fmt.Sprintf("unknown language %q", template.Language))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	current := &Package{Tests: []types.TestCase{}, HiddenTests: []types.TestCase{}}
+	if options.ChallengeId != 0 {
+		challenge, err := store.GetChallengeByIDFull(options.ChallengeId)
+		if err != nil {
+			return nil, err
+		}
+		templates, err := store.GetChallengeTemplates(options.ChallengeId)
+		if err != nil {
+			return nil, err
+		}
+		current = FromChallenge(challenge, templates)
+	}
+
+	result := &types.ChallengeImportResult{
+		ChallengeId: options.ChallengeId,
+		Created:     options.ChallengeId == 0,
+		DryRun:      options.DryRun,
+		Conversion:  options.Conversion,
+	}
+	if result.Diff, err = Diff(current, p); err != nil {
+		return nil, err
+	}
+	if options.DryRun || len(result.Diff) == 0 {
+		return result, nil
+	}
+
+	challenge := p.Challenge()
+	challenge.Id = options.ChallengeId
+	challenge.OwnerId = options.AuthorId
+	if options.ChallengeId == 0 {
+		if err := store.CreateChallenge(challenge); err != nil {
+			return nil, err
+		}
+		result.ChallengeId = challenge.Id
+	} else if err := store.UpdateChallenge(challenge, options.AuthorId); err != nil {
+		return nil, err
+	}
+
+	if err := importTags(store, result.ChallengeId, current, p); err != nil {
+		if result.Created {
+			if deleteErr := store.DeleteChallenge(result.ChallengeId); deleteErr != nil {
+				return nil, fmt.Errorf("%s, then the created challenge %d can't be deleted: %s", err.Error(), result.ChallengeId, deleteErr.Error())
+			}
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// importTags saves the tags of the package when they changed, they aren't part of the revisions
+func importTags(store Store, challengeId int, before, after *Package) error {
+	if slices.Equal(sortedTags(before.Manifest.Tags), sortedTags(after.Manifest.Tags)) {
+		return nil
+	}
+	return store.SetChallengeTags(challengeId, after.Manifest.Tags)
+}
+
+// keepCatalogueTags drops the tags of a converted package missing from the catalogue, and the ones over the limit
+func keepCatalogueTags(p *Package, catalogue []*types.Tag, report *types.ChallengeConversionReport) {
+	kept, dropped := []string{}, []string{}
+	for _, slug := range p.Manifest.Tags {
+		if slices.ContainsFunc(catalogue, func(tag *types.Tag) bool { return tag.Slug == slug }) && len(kept) < types.MaxChallengeTags {
+			kept = append(kept, slug)
+		} else {
+			dropped = append(dropped, slug)
+		}
+	}
+	if len(dropped) > 0 {
+		report.Unconverted = append(report.Unconverted, fmt.Sprintf("tags %s, missing from the catalogue or over the limit of %d", strings.Join(dropped, ", "), types.MaxChallengeTags))
+	}
+	p.Manifest.Tags = kept
+}
+
+// Diff returns the changes of every field that differs between two packages, tests are compared as indented JSON
+func Diff(before, after *Package) ([]types.RevisionChange, error) {
+	fields := []struct {
+		name          string
+		before, after any
+	}{
+		{types.RevisionFieldTitle, before.Manifest.Title, after.Manifest.Title},
+		{types.RevisionFieldDescription, before.Manifest.Description, after.Manifest.Description},
+		{types.PackageFieldDifficulty, before.Manifest.Difficulty, after.Manifest.Difficulty},
+		{types.PackageFieldTags, strings.Join(sortedTags(before.Manifest.Tags), "\n"), strings.Join(sortedTags(after.Manifest.Tags), "\n")},
+		{types.RevisionFieldContent, before.Statement, after.Statement},
+		{types.RevisionFieldTests, before.Tests, after.Tests},
+		{types.RevisionFieldHiddenTests, before.HiddenTests, after.HiddenTests},
+		{types.RevisionFieldTemplates, before.Templates, after.Templates},
+	}
+
+	changes := []types.RevisionChange{}
+	for _, field := range fields {
+		beforeText, err := diffText(field.before)
+		if err != nil {
+			return nil, err
+		}
+		afterText, err := diffText(field.after)
+		if err != nil {
+			return nil, err
+		}
+
+		if lines := utils.DiffLines(beforeText, afterText); len(lines) > 0 {
+			changes = append(changes, types.RevisionChange{Field: field.name, Lines: lines})
+		}
+	}
+	return changes, nil
+}
+
+func diffText(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case []types.TestCase:
+		if value == nil {
+			value = []types.TestCase{}
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		return string(data), err
+	case []types.ChallengeTemplate:
+		if value == nil {
+			value = []types.ChallengeTemplate{}
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported package field %T", value)
+	}
+}
+
+// sortedTags returns a sorted copy of the tags, their order doesn't matter
+func sortedTags(tags []string) []string {
+	sorted := slices.Clone(tags)
+	slices.Sort(sorted)
+	return sorted
+}
@@ -0,0 +1,98 @@
+package challengepkg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// memoryStore keeps the challenges created by an import, the tag writes fail when failTags is set
+type memoryStore struct {
+	challenges map[int]*types.Challenge
+	failTags   bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{challenges: map[int]*types.Challenge{}}
+}
+
+func (s *memoryStore) GetTags() ([]*types.Tag, error) {
+	return []*types.Tag{{Id: 1, Slug: "math"}}, nil
+}
+
+func (s *memoryStore) GetLanguages() ([]*types.Language, error) {
+	return []*types.Language{{Id: 1, Name: "go"}, {Id: 2, Name: "python"}}, nil
+}
+
+func (s *memoryStore) GetChallengeByIDFull(id int) (*types.ChallengeFull, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *memoryStore) CreateChallenge(challenge *types.Challenge) error {
+	challenge.Id = len(s.challenges) + 1
+	s.challenges[challenge.Id] = challenge
+	return nil
+}
+
+func (s *memoryStore) DeleteChallenge(id int) error {
+	delete(s.challenges, id)
+	return nil
+}
+
+func (s *memoryStore) UpdateChallenge(challenge *types.Challenge, authorId int) error {
+	s.challenges[challenge.Id] = challenge
+	return nil
+}
+
+func (s *memoryStore) SetChallengeTags(challengeId int, slugs []string) error {
+	if s.failTags {
+		return errors.New("tags not saved")
+	}
+	s.challenges[challengeId].Tags = slugs
+	return nil
+}
+
+func (s *memoryStore) GetChallengeTemplates(challengeId int) ([]*types.ChallengeTemplate, error) {
+	templates := []*types.ChallengeTemplate{}
+	for i := range s.challenges[challengeId].Templates {
+		templates = append(templates, &s.challenges[challengeId].Templates[i])
+	}
+	return templates, nil
+}
+
+func TestImportCreates(t *testing.T) {
+	store := newMemoryStore()
+	result, err := Import(store, testPackage(), ImportOptions{AuthorId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Created || store.challenges[result.ChallengeId] == nil {
+		t.Fatalf("the challenge wasn't created: %+v", result)
+	}
+	if templates := store.challenges[result.ChallengeId].Templates; len(templates) != 2 {
+		t.Errorf("%d templates saved with the challenge, want 2", len(templates))
+	}
+}
+
+func TestImportDeletesTheChallengeOnFailure(t *testing.T) {
+	store := newMemoryStore()
+	store.failTags = true
+	if _, err := Import(store, testPackage(), ImportOptions{AuthorId: 1}); err == nil {
+		t.Fatal("the import succeeded without its tags")
+	}
+	if len(store.challenges) != 0 {
+		t.Errorf("the created challenge is left without its tags")
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	store := newMemoryStore()
+	result, err := Import(store, testPackage(), ImportOptions{AuthorId: 1, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Diff) == 0 || len(store.challenges) != 0 {
+		t.Errorf("a dry run returned %d changes and created %d challenges, want changes and nothing created", len(result.Diff), len(store.challenges))
+	}
+}
@@ -0,0 +1,241 @@
+package challengepkg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kattisManifestFile = "problem.yaml"
+	kattisSampleDir    = "data/sample"
+	kattisSecretDir    = "data/secret"
+	kattisLanguage     = "en"
+
+	kattisAnswerExt = ".ans"
+)
+
+// kattisProblem is the part of the problem.yaml of a Kattis package the conversion reads,
+// in the legacy format or in the 2023-07 one
+type kattisProblem struct {
+	Name           any    `yaml:"name"`     // a string, or the names by language
+	Keywords       any    `yaml:"keywords"` // a string of space separated keywords, or a list
+	Type           string `yaml:"type"`
+	Validation     string `yaml:"validation"`
+	ValidatorFlags string `yaml:"validator_flags"`
+	Source         any    `yaml:"source"`
+	Author         any    `yaml:"author"` // a string, or a list in the 2023-07 format
+	License        string `yaml:"license"`
+	Limits         any    `yaml:"limits"`
+}
+
+// kattisStatementDirs holds the statements in the legacy format and in the 2023-07 one
+var kattisStatementDirs = []string{"problem_statement", "statement"}
+
+var (
+	kattisStatementFile = regexp.MustCompile(`^problem(?:\.([a-z]{2}))?\.(tex|md)$`)
+	kattisProblemName   = regexp.MustCompile(`\\problemname\{([^{}]*)\}`)
+)
+
+// kattis converts a package in the problem package format of Kattis.
+// The tests of data/sample become the public tests and the ones of data/secret, with their groups, the hidden ones.
+func (c *converter) kattis() error {
+	if err := c.root(kattisManifestFile); err != nil {
+		return err
+	}
+
+	data, err := readFile(c.fsys, kattisManifestFile)
+	if err != nil {
+		return err
+	}
+	problem := &kattisProblem{}
+	if err := yaml.Unmarshal(data, problem); err != nil {
+		return fmt.Errorf("%s: %s", kattisManifestFile, err.Error())
+	}
+
+	c.pkg.Manifest.Title = kattisText(problem.Name)
+	switch keywords := problem.Keywords.(type) {
+	case string:
+		c.pkg.Manifest.Tags = append(c.pkg.Manifest.Tags, strings.Fields(keywords)...)
+	case []any:
+		for _, keyword := range keywords {
+			c.pkg.Manifest.Tags = append(c.pkg.Manifest.Tags, fmt.Sprint(keyword))
+		}
+	}
+
+	if err := c.kattisStatement(); err != nil {
+		return err
+	}
+	credits := []string{}
+	if author := kattisText(problem.Author); author != "" {
+		credits = append(credits, "Author: "+author)
+	}
+	if source := kattisText(problem.Source); source != "" {
+		credits = append(credits, "Source: "+source)
+	}
+	if problem.License != "" {
+		credits = append(credits, "License: "+problem.License)
+	}
+	if len(credits) > 0 {
+		c.pkg.Statement += "\n\n*" + strings.Join(credits, ", ") + "*"
+	}
+
+	if problem.Type != "" && problem.Type != "pass-fail" {
+		c.unconverted("problem type %s, only pass-fail problems are supported", problem.Type)
+	}
+	if problem.Validation != "" && problem.Validation != "default" {
+		c.unconverted("%s validation, the output must match the expected one", problem.Validation)
+	}
+	if problem.ValidatorFlags != "" {
+		c.unconverted("validator flags %q", problem.ValidatorFlags)
+	}
+	if c.exists("output_validators") || c.exists("output_validator") {
+		c.unconverted("output validators, the output must match the expected one")
+	}
+	if problem.Limits != nil {
+		c.unconverted("limits %v of %s", problem.Limits, kattisManifestFile)
+	}
+
+	if err := c.kattisTests(kattisSampleDir, false); err != nil {
+		return err
+	}
+	return c.kattisTests(kattisSecretDir, true)
+}
+
+// kattisText returns a text of problem.yaml that may be given by language or as a list
+func kattisText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []any:
+		texts := []string{}
+		for _, item := range value {
+			texts = append(texts, kattisText(item))
+		}
+		return strings.Join(texts, ", ")
+	case map[string]any:
+		if text, ok := value[kattisLanguage].(string); ok {
+			return text
+		}
+		if name, ok := value["name"].(string); ok { // a person
+			return name
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			return fmt.Sprint(value[keys[0]])
+		}
+	}
+	return ""
+}
+
+// kattisStatement reads the english statement, or the first one, preferring markdown to LaTeX
+func (c *converter) kattisStatement() error {
+	var best, bestLanguage, bestFormat string
+	rank := func(language, format string) int {
+		score := 0
+		if language == kattisLanguage || language == "" {
+			score += 2
+		}
+		if format == "md" {
+			score++
+		}
+		return score
+	}
+
+	for _, dir := range kattisStatementDirs {
+		entries, err := fs.ReadDir(c.fsys, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			parts := kattisStatementFile.FindStringSubmatch(entry.Name())
+			if parts == nil {
+				continue
+			}
+			if best == "" || rank(parts[1], parts[2]) > rank(bestLanguage, bestFormat) {
+				best, bestLanguage, bestFormat = path.Join(dir, entry.Name()), parts[1], parts[2]
+			}
+		}
+	}
+	if best == "" {
+		c.unconverted("statement, the package has none")
+		return nil
+	}
+	if bestLanguage != "" && bestLanguage != kattisLanguage {
+		c.unconverted("statement only in %s, it wasn't translated", bestLanguage)
+	}
+
+	data, err := readFile(c.fsys, best)
+	if err != nil {
+		return err
+	}
+	statement := string(data)
+	if bestFormat == "md" {
+		c.pkg.Statement = statement
+		return nil
+	}
+
+	if name := kattisProblemName.FindStringSubmatch(statement); name != nil {
+		if c.pkg.Manifest.Title == "" {
+			c.pkg.Manifest.Title = name[1]
+		}
+		statement = kattisProblemName.ReplaceAllString(statement, "")
+	}
+	var problems []string
+	c.pkg.Statement, problems = latexToMarkdown(statement)
+	for _, problem := range problems {
+		c.unconverted("statement: %s", problem)
+	}
+	return nil
+}
+
+// kattisTests adds the .in and .ans pairs of the directory and of its groups, in the order of their paths
+func (c *converter) kattisTests(dir string, hidden bool) error {
+	if !c.exists(dir) {
+		return nil
+	}
+
+	grouped := false
+	err := fs.WalkDir(c.fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			grouped = grouped || name != dir
+			return nil
+		}
+
+		base := strings.TrimPrefix(name, dir+"/")
+		switch {
+		case strings.HasSuffix(base, inputExt):
+			base = strings.TrimSuffix(base, inputExt)
+			return c.addTestFiles(hidden, strings.ReplaceAll(base, "/", " "), name, strings.TrimSuffix(name, inputExt)+kattisAnswerExt)
+		case strings.HasSuffix(base, ".interaction"):
+			c.skip("of interactive problems", strings.ReplaceAll(strings.TrimSuffix(base, ".interaction"), "/", " "))
+		case path.Base(name) == "testdata.yaml":
+			grouped = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if grouped {
+		c.unconverted("test groups and testdata.yaml settings of %s", dir)
+	}
+	return nil
+}
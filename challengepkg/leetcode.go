@@ -0,0 +1,155 @@
+package challengepkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// leetCodeQuestion is the question of the LeetCode GraphQL API, as saved by the usual scrapers
+type leetCodeQuestion struct {
+	Title               string   `json:"title"`
+	TitleSlug           string   `json:"titleSlug"`
+	Content             string   `json:"content"` // HTML
+	Difficulty          string   `json:"difficulty"`
+	ExampleTestcases    string   `json:"exampleTestcases"`    // arguments of every example, one per line
+	ExampleTestcaseList []string `json:"exampleTestcaseList"` // arguments of every example, one item per example
+	SampleTestCase      string   `json:"sampleTestCase"`      // arguments of the first example
+	MetaData            string   `json:"metaData"`            // JSON of the function to implement
+	Hints               []string `json:"hints"`
+	TopicTags           []struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"topicTags"`
+	CodeSnippets []struct {
+		LangSlug string `json:"langSlug"`
+	} `json:"codeSnippets"`
+
+	// LeetCode doesn't publish its tests, authors who keep theirs in the file list them here
+	HiddenTestCases []types.TestCase `json:"hiddenTestCases"`
+}
+
+type leetCodeMetaData struct {
+	Name   string `json:"name"`
+	Params []struct {
+		Name string `json:"name"`
+	} `json:"params"`
+	SystemDesign bool `json:"systemdesign"`
+}
+
+var leetCodeOutput = regexp.MustCompile(`(?m)Output:\s*(.*?)\s*$`)
+
+// leetCode converts a LeetCode-style JSON question, alone or wrapped in the response of the API.
+// The examples become the public tests, with their expected output taken from the statement, and hiddenTestCases the hidden ones.
+// The arguments of the function are given one per line on the standard input, the expected output is the returned value.
+func (c *converter) leetCode(data []byte) error {
+	question := &leetCodeQuestion{}
+	if err := json.Unmarshal(leetCodeUnwrap(data), question); err != nil {
+		return fmt.Errorf("invalid LeetCode question: %s", err.Error())
+	}
+
+	c.pkg.Manifest.Title = question.Title
+	if c.pkg.Manifest.Title == "" {
+		c.pkg.Manifest.Title = question.TitleSlug
+	}
+	c.pkg.Manifest.Difficulty = strings.ToLower(question.Difficulty)
+	for _, tag := range question.TopicTags {
+		c.pkg.Manifest.Tags = append(c.pkg.Manifest.Tags, tag.Slug)
+	}
+
+	statement, tags := htmlToMarkdown(question.Content)
+	if len(question.Hints) > 0 {
+		statement += "\n\n## Hints\n"
+		for _, hint := range question.Hints {
+			text, hintTags := htmlToMarkdown(hint)
+			statement += "\n- " + text
+			tags = append(tags, hintTags...)
+		}
+	}
+	c.pkg.Statement = statement
+	if question.Content == "" {
+		c.unconverted("statement, the question has no content")
+	}
+	if len(tags) > 0 {
+		c.unconverted("statement: HTML tags %s", strings.Join(tags, ", "))
+	}
+
+	params := 1
+	metaData := &leetCodeMetaData{}
+	if question.MetaData != "" {
+		if err := json.Unmarshal([]byte(question.MetaData), metaData); err != nil {
+			return fmt.Errorf("invalid LeetCode metaData: %s", err.Error())
+		}
+	}
+	switch {
+	case metaData.SystemDesign:
+		c.unconverted("design problem, the calls and their arguments are kept as the examples give them")
+	case metaData.Name != "":
+		params = max(len(metaData.Params), 1)
+		c.unconverted("function signature of %s, its arguments are given one per line on the standard input", metaData.Name)
+	default:
+		c.unconverted("function signature, the question has no metaData so every example is a single line")
+	}
+	if len(question.CodeSnippets) > 0 {
+		c.unconverted("starter code in %d languages", len(question.CodeSnippets))
+	}
+
+	inputs := question.ExampleTestcaseList
+	if len(inputs) == 0 {
+		examples := question.ExampleTestcases
+		if examples == "" {
+			examples = question.SampleTestCase
+		}
+		lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(examples, "\r\n", "\n")), "\n")
+		if examples == "" {
+			lines = nil
+		}
+		if len(lines)%params != 0 {
+			c.unconverted("examples, %d lines of arguments aren't a multiple of the %d arguments", len(lines), params)
+			lines = nil
+		}
+		for i := 0; i < len(lines); i += params {
+			inputs = append(inputs, strings.Join(lines[i:i+params], "\n"))
+		}
+	}
+
+	outputs := leetCodeOutput.FindAllStringSubmatch(html.UnescapeString(htmlTag.ReplaceAllString(question.Content, "")), -1)
+	for i, input := range inputs {
+		name := fmt.Sprintf("example %d", i+1)
+		if i >= len(outputs) {
+			c.skip("without an output in the statement", name)
+			continue
+		}
+		c.addTestCase(false, types.TestCase{Name: name, Input: input + "\n", Output: outputs[i][1] + "\n"})
+	}
+
+	if len(question.HiddenTestCases) == 0 {
+		c.unconverted("hidden tests, LeetCode doesn't publish them, add them to hiddenTestCases")
+	}
+	for i, testCase := range question.HiddenTestCases {
+		if testCase.Name == "" {
+			testCase.Name = fmt.Sprintf("test %d", i+1)
+		}
+		c.addTestCase(true, testCase)
+	}
+	return nil
+}
+
+// leetCodeUnwrap returns the question of a response of the API, {"data": {"question": ...}}, or the data as it is
+func leetCodeUnwrap(data []byte) []byte {
+	wrapper := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return data
+	}
+	if inner, ok := wrapper["data"]; ok {
+		return leetCodeUnwrap(inner)
+	}
+	if question, ok := wrapper["question"]; ok {
+		return question
+	}
+	return data
+}
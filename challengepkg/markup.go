@@ -0,0 +1,167 @@
+package challengepkg
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	latexComment     = regexp.MustCompile(`(?m)(^|[^\\])%.*$`)
+	latexVerbatim    = regexp.MustCompile(`(?s)\\begin\{verbatim\}\n?(.*?)\\end\{verbatim\}`)
+	latexGraphics    = regexp.MustCompile(`\\(?:includegraphics|illustration)(?:\[[^\]]*\])?((?:\{[^{}]*\})+)`)
+	latexEnvironment = regexp.MustCompile(`\\(begin|end)\{([^{}]*)\}(\{[^{}]*\})?`)
+	latexCommand     = regexp.MustCompile(`\\[A-Za-z]+`)
+	latexMath        = regexp.MustCompile(`(?s)\$\$.*?\$\$|\$[^$]*\$`)
+	blankLines       = regexp.MustCompile(`\n{3,}`)
+
+	// commands with a single argument and their markdown replacement, %s is the argument
+	latexFormatting = []struct {
+		pattern     *regexp.Regexp
+		replacement string
+	}{
+		{regexp.MustCompile(`\\section\*?\{([^{}]*)\}`), "\n## %s\n"},
+		{regexp.MustCompile(`\\subsection\*?\{([^{}]*)\}`), "\n### %s\n"},
+		{regexp.MustCompile(`\\textbf\{([^{}]*)\}`), "**%s**"},
+		{regexp.MustCompile(`\\(?:textit|emph)\{([^{}]*)\}`), "*%s*"},
+		{regexp.MustCompile(`\\texttt\{([^{}]*)\}`), "`%s`"},
+		{regexp.MustCompile(`\\url\{([^{}]*)\}`), "<%s>"},
+	}
+
+	// environments that only change the layout, the other ones are reported
+	latexLayoutEnvironments = []string{"itemize", "enumerate", "center", "document", "problem"}
+)
+
+// latexToMarkdown converts the usual formatting of the LaTeX statements, the math is kept between $ as markdown expects.
+// Returns what couldn't be converted: images, environments and commands left in the text.
+func latexToMarkdown(tex string) (string, []string) {
+	problems := []string{}
+	tex = strings.ReplaceAll(tex, "\x00", "") // NUL bytes delimit the placeholders of the text set aside below
+	tex = strings.ReplaceAll(tex, "\r\n", "\n")
+
+	// the code and the math are set aside so the replacements below don't touch them,
+	// the code before the comments are dropped since a % in it isn't one
+	kept := []string{}
+	keep := func(text string) string {
+		kept = append(kept, text)
+		return fmt.Sprintf("\x00%d\x00", len(kept)-1)
+	}
+	tex = latexVerbatim.ReplaceAllStringFunc(tex, func(match string) string {
+		return "\n" + keep("```\n"+latexVerbatim.FindStringSubmatch(match)[1]+"```") + "\n"
+	})
+	tex = latexComment.ReplaceAllString(tex, "$1")
+	tex = strings.ReplaceAll(tex, "$$$", "$") // inline math of Polygon
+	tex = latexMath.ReplaceAllStringFunc(tex, keep)
+
+	tex = latexGraphics.ReplaceAllStringFunc(tex, func(match string) string {
+		// \includegraphics{file} or \illustration{width}{file}{caption}
+		args := strings.Split(strings.Trim(latexGraphics.FindStringSubmatch(match)[1], "{}"), "}{")
+		problems = append(problems, fmt.Sprintf("image %s", args[min(1, len(args)-1)]))
+		return ""
+	})
+	for i := 0; i < 3; i++ { // nested formatting
+		for _, formatting := range latexFormatting {
+			tex = formatting.pattern.ReplaceAllStringFunc(tex, func(match string) string {
+				return fmt.Sprintf(formatting.replacement, formatting.pattern.FindStringSubmatch(match)[1])
+			})
+		}
+	}
+	tex = latexEnvironment.ReplaceAllStringFunc(tex, func(match string) string {
+		parts := latexEnvironment.FindStringSubmatch(match)
+		if parts[1] == "begin" && !slices.Contains(latexLayoutEnvironments, parts[2]) {
+			problems = append(problems, fmt.Sprintf("environment %s", parts[2]))
+		}
+		return "\n"
+	})
+	tex = latexText.Replace(tex)
+
+	commands := []string{}
+	for _, command := range latexCommand.FindAllString(tex, -1) {
+		if !slices.Contains(commands, command) {
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) > 0 {
+		problems = append(problems, fmt.Sprintf("commands %s kept as they are", strings.Join(commands, ", ")))
+	}
+
+	tex = latexKept.ReplaceAllStringFunc(tex, func(match string) string {
+		index, _ := strconv.Atoi(strings.Trim(match, "\x00"))
+		return kept[index]
+	})
+	return cleanMarkdown(tex), problems
+}
+
+var (
+	latexKept = regexp.MustCompile(`\x00\d+\x00`)
+	latexText = strings.NewReplacer(
+		`\item`, "-",
+		`\ldots`, "...",
+		`\dots`, "...",
+		`\\`, "\n",
+		"~", " ",
+		"``", `"`,
+		"''", `"`,
+		"---", "\u2014",
+		"--", "\u2013",
+	)
+)
+
+var (
+	htmlPre        = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+	htmlImage      = regexp.MustCompile(`(?i)<img[^>]*\ssrc="([^"]*)"[^>]*>`)
+	htmlLink       = regexp.MustCompile(`(?is)<a[^>]*\shref="([^"]*)"[^>]*>(.*?)</a>`)
+	htmlTag        = regexp.MustCompile(`</?([A-Za-z][A-Za-z0-9]*)[^>]*>`)
+	htmlSpaceLines = regexp.MustCompile(`(?m)^[ \t]+|[ \t]+$`)
+
+	// tags and their markdown replacement, the other ones are dropped and reported
+	htmlReplacements = map[string]string{
+		"p": "\n\n", "/p": "\n\n", "div": "\n", "/div": "\n", "br": "\n",
+		"strong": "**", "/strong": "**", "b": "**", "/b": "**",
+		"em": "*", "/em": "*", "i": "*", "/i": "*",
+		"code": "`", "/code": "`",
+		"sup": "^", "/sup": "", "sub": "_", "/sub": "",
+		"ul": "\n", "/ul": "\n", "ol": "\n", "/ol": "\n", "li": "- ", "/li": "\n",
+		"span": "", "/span": "", "font": "", "/font": "",
+	}
+)
+
+// htmlToMarkdown converts the formatting of the HTML statements. Returns the tags that couldn't be converted.
+func htmlToMarkdown(text string) (string, []string) {
+	tags := []string{}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = htmlPre.ReplaceAllStringFunc(text, func(match string) string {
+		code := htmlTag.ReplaceAllString(htmlPre.FindStringSubmatch(match)[1], "")
+		return "\n\n```\n" + strings.Trim(code, "\n") + "\n```\n\n"
+	})
+	text = htmlImage.ReplaceAllString(text, "![]($1)")
+	text = htmlLink.ReplaceAllString(text, "[$2]($1)")
+	text = htmlTag.ReplaceAllStringFunc(text, func(match string) string {
+		name := strings.ToLower(htmlTag.FindStringSubmatch(match)[1])
+		if strings.HasPrefix(match, "</") {
+			name = "/" + name
+		}
+		if replacement, ok := htmlReplacements[name]; ok {
+			return replacement
+		}
+		if name = strings.TrimPrefix(name, "/"); !slices.Contains(tags, name) {
+			tags = append(tags, name)
+		}
+		return ""
+	})
+	text = strings.ReplaceAll(html.UnescapeString(text), "\u00a0", " ")
+
+	return cleanMarkdown(text), tags
+}
+
+// cleanMarkdown trims the lines out of the code blocks and collapses the blank lines
+func cleanMarkdown(text string) string {
+	parts := strings.Split(text, "```")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = htmlSpaceLines.ReplaceAllString(parts[i], "")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(parts, "```"), "\n\n"))
+}
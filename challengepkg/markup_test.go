@@ -0,0 +1,72 @@
+package challengepkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLatexToMarkdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		tex          string
+		want         string
+		wantProblems []string
+	}{
+		{"comment", "Hello % comment\nworld", "Hello\nworld", []string{}},
+		{"escaped percent", `Use 50\% of it`, `Use 50\% of it`, []string{}},
+		{"percent in verbatim", "\\begin{verbatim}\nprintf(\"%d\", x);\n\\end{verbatim}", "```\nprintf(\"%d\", x);\n```", []string{}},
+		{"formatting", "\\section{Input}\nThe \\textbf{first} line, \\emph{then} \\texttt{n}", "## Input\n\nThe **first** line, *then* `n`", []string{}},
+		{"nested formatting", `\textbf{\emph{both}}`, "***both***", []string{}},
+		{"math is kept", `$a_1 \le \textbf{n}$ and $$x$$`, `$a_1 \le \textbf{n}$ and $$x$$`, []string{}},
+		{"inline math of Polygon", `$$$1 \le n$$$`, `$1 \le n$`, []string{}},
+		{"lists", "\\begin{itemize}\n\\item one\n\\item two\n\\end{itemize}", "- one\n- two", []string{}},
+		{"punctuation", "a -- b --- c ``q'' x~y \\ldots", "a \u2013 b \u2014 c \"q\" x y ...", []string{}},
+		{"image", "before\n\\includegraphics[width=3cm]{pic.png}\nafter", "before\n\nafter", []string{"image pic.png"}},
+		{"illustration of Polygon", `\illustration{0.5}{tree.png}{A tree}`, "", []string{"image tree.png"}},
+		{"unknown environment", "\\begin{tabular}{ll}a & b\\end{tabular}", "a & b", []string{"environment tabular"}},
+		{"unknown command", `\foo{x} \foo{y} \bar`, `\foo{x} \foo{y} \bar`, []string{`commands \foo, \bar kept as they are`}},
+		{"NUL bytes", "a\x00" + "0\x00b", "a0b", []string{}},
+		{"windows line endings", "one\r\ntwo", "one\ntwo", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, problems := latexToMarkdown(test.tex)
+			if got != test.want {
+				t.Errorf("latexToMarkdown(%q) = %q, want %q", test.tex, got, test.want)
+			}
+			if !slices.Equal(problems, test.wantProblems) {
+				t.Errorf("latexToMarkdown(%q) problems = %q, want %q", test.tex, problems, test.wantProblems)
+			}
+		})
+	}
+}
+
+func TestHtmlToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		want     string
+		wantTags []string
+	}{
+		{"paragraphs", "<p>one</p>\n<p>two</p>", "one\n\ntwo", []string{}},
+		{"formatting", "Given <code>nums</code>, return <strong>the sum</strong> <em>now</em>.", "Given `nums`, return **the sum** *now*.", []string{}},
+		{"code block", "<pre><strong>Input:</strong> 1 2\n<b>Output:</b> 3\n</pre>", "```\nInput: 1 2\nOutput: 3\n```", []string{}},
+		{"lists", "<ul><li>one</li><li>two</li></ul>", "- one\n- two", []string{}},
+		{"image and link", `<img alt="x" src="a.png"> <a href="https://example.com">docs</a>`, "![](a.png) [docs](https://example.com)", []string{}},
+		{"exponents", "2<sup>31</sup> and x<sub>i</sub>", "2^31 and x_i", []string{}},
+		{"entities", "a &lt; b&nbsp;&amp; c", "a < b & c", []string{}},
+		{"unknown tags", "<table><tr><td>1</td></tr></table>", "1", []string{"table", "tr", "td"}},
+		{"case insensitive", "<P>one</P><BR>two", "one\n\ntwo", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, tags := htmlToMarkdown(test.html)
+			if got != test.want {
+				t.Errorf("htmlToMarkdown(%q) = %q, want %q", test.html, got, test.want)
+			}
+			if !slices.Equal(tags, test.wantTags) {
+				t.Errorf("htmlToMarkdown(%q) tags = %q, want %q", test.html, tags, test.wantTags)
+			}
+		})
+	}
+}
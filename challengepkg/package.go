@@ -0,0 +1,497 @@
+// Package challengepkg reads and writes challenges as packages, a directory or a ZIP archive
+// authors can keep in git and move between environments:
+//
+//	challenge.yaml   manifest: format version, title, description, difficulty and tags
+//	statement.md     content of the challenge
+//	tests/           public tests, NNN-name.in and NNN-name.out pairs in order; the names are in the manifest
+//	hidden/          hidden tests, same layout as tests/
+//	templates/       starter code of each language, starter.<language>; the signatures are in the manifest
+package challengepkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatVersion is the version written in the manifest of the exported packages,
+// packages of older versions are still read and the ones of newer versions are refused:
+//
+//	1  manifest, statement and tests
+//	2  templates, the signatures and the test names in the manifest
+const FormatVersion = 2
+
+const (
+	ManifestFile  = "challenge.yaml"
+	StatementFile = "statement.md"
+	TestsDir      = "tests"
+	HiddenDir     = "hidden"
+	TemplatesDir  = "templates"
+
+	inputExt  = ".in"
+	outputExt = ".out"
+
+	starterCodePrefix = "starter."
+)
+
+// MaxSize is the maximum uncompressed size of the files of a package
+const MaxSize = 8 << 20
+
+type Manifest struct {
+	FormatVersion int      `yaml:"format_version"`
+	Title         string   `yaml:"title"`
+	Description   string   `yaml:"description"`
+	Difficulty    string   `yaml:"difficulty"`
+	Tags          []string `yaml:"tags,omitempty"`
+
+	Signatures map[string]string `yaml:"signatures,omitempty"` // function signature by language
+	TestNames  map[string]string `yaml:"test_names,omitempty"` // test name by file without extension, e.g. tests/001-sum
+}
+
+type Package struct {
+	Manifest    Manifest
+	Statement   string
+	Tests       []types.TestCase
+	HiddenTests []types.TestCase
+	Templates   []types.ChallengeTemplate // sorted by language
+}
+
+// ValidationError lists every problem found in a package
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid package: " + strings.Join(e.Problems, "; ")
+}
+
+func FromChallenge(challenge *types.ChallengeFull, templates []*types.ChallengeTemplate) *Package {
+	p := &Package{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			Title:         challenge.Title,
+			Description:   challenge.Description,
+			Difficulty:    challenge.Difficulty,
+			Tags:          challenge.Tags,
+		},
+		Statement:   challenge.Content,
+		Tests:       challenge.TestCases,
+		HiddenTests: challenge.HiddenTestCases,
+		Templates:   []types.ChallengeTemplate{},
+	}
+	for _, template := range templates {
+		p.Templates = append(p.Templates, types.ChallengeTemplate{
+			Language:    template.Language,
+			Signature:   template.Signature,
+			StarterCode: template.StarterCode,
+		})
+	}
+	return p
+}
+
+// Challenge returns the challenge described by the package, without owner.
+// Its templates are never nil, a package without templates removes the ones of the challenge it updates.
+func (p *Package) Challenge() *types.Challenge {
+	templates := p.Templates
+	if templates == nil {
+		templates = []types.ChallengeTemplate{}
+	}
+	return &types.Challenge{
+		Title:           p.Manifest.Title,
+		Description:     p.Manifest.Description,
+		Content:         p.Statement,
+		Difficulty:      p.Manifest.Difficulty,
+		Tags:            p.Manifest.Tags,
+		TestCases:       p.Tests,
+		HiddenTestCases: p.HiddenTests,
+		Templates:       templates,
+	}
+}
+
+// Validate checks the package against the limits of the challenges, returning a *ValidationError
+func (p *Package) Validate() error {
+	problems := []string{}
+	if p.Manifest.FormatVersion < 1 || p.Manifest.FormatVersion > FormatVersion {
+		problems = append(problems, fmt.Sprintf("unsupported format_version %d, expected at most %d", p.Manifest.FormatVersion, FormatVersion))
+	}
+	if title := strings.TrimSpace(p.Manifest.Title); title == "" || len(title) > types.MaxChallengeTitleLength {
+		problems = append(problems, fmt.Sprintf("title must be between 1 and %d characters", types.MaxChallengeTitleLength))
+	}
+	if len(p.Manifest.Description) > types.MaxChallengeDescriptionLength {
+		problems = append(problems, fmt.Sprintf("description can't be longer than %d characters", types.MaxChallengeDescriptionLength))
+	}
+	if !types.IsChallengeDifficulty(p.Manifest.Difficulty) {
+		problems = append(problems, "difficulty must be easy, medium or hard")
+	}
+	if len(p.Manifest.Tags) > types.MaxChallengeTags {
+		problems = append(problems, fmt.Sprintf("a challenge can't have more than %d tags", types.MaxChallengeTags))
+	}
+	if strings.TrimSpace(p.Statement) == "" {
+		problems = append(problems, StatementFile+" is empty")
+	}
+	if err := types.ValidateTestCases(p.Tests); err != nil {
+		problems = append(problems, TestsDir+": "+err.Error())
+	}
+	if err := types.ValidateTestCases(p.HiddenTests); err != nil {
+		problems = append(problems, HiddenDir+": "+err.Error())
+	}
+	for i := range p.Templates {
+		if err := types.ValidateChallengeTemplate(&p.Templates[i]); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Open reads the package at `name`, a directory or a .zip file
+func Open(name string) (*Package, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return Read(os.DirFS(name))
+	}
+
+	if info.Size() > MaxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, MaxSize)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadZip(data)
+}
+
+// ReadZip reads a package archived with its files at the root or in a single top directory
+func ReadZip(data []byte) (*Package, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %s", err.Error())
+	}
+
+	var size uint64
+	for _, file := range archive.File {
+		size += file.UncompressedSize64
+	}
+	if size > MaxSize {
+		return nil, fmt.Errorf("the package is larger than %d bytes uncompressed", MaxSize)
+	}
+
+	return Read(archive)
+}
+
+// Read reads the package at the root of fsys, or in its only directory when the root has no manifest
+func Read(fsys fs.FS) (*Package, error) {
+	root, err := packageRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Package{}
+	problems := []string{}
+
+	manifest, err := readFile(root, ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p.Manifest); err != nil && !errors.Is(err, io.EOF) {
+		problems = append(problems, fmt.Sprintf("%s: %s", ManifestFile, err.Error()))
+	}
+	if p.Manifest.Tags == nil {
+		p.Manifest.Tags = []string{}
+	}
+
+	statement, err := readFile(root, StatementFile)
+	if err != nil {
+		return nil, err
+	}
+	p.Statement = string(statement)
+
+	testNames := map[string]string{}
+	for base, name := range p.Manifest.TestNames {
+		testNames[base] = name
+	}
+	if p.Tests, err = readTestCases(root, TestsDir, testNames, &problems); err != nil {
+		return nil, err
+	}
+	if p.HiddenTests, err = readTestCases(root, HiddenDir, testNames, &problems); err != nil {
+		return nil, err
+	}
+	for base := range testNames {
+		problems = append(problems, fmt.Sprintf("%s: test_names has %s, which isn't a test", ManifestFile, base))
+	}
+	p.Manifest.TestNames = nil
+	if p.Templates, err = readTemplates(root, p.Manifest.Signatures, &problems); err != nil {
+		return nil, err
+	}
+	p.Manifest.Signatures = nil
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return p, nil
+}
+
+func packageRoot(fsys fs.FS) (fs.FS, error) {
+	return findRoot(fsys, ManifestFile)
+}
+
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, MaxSize)
+	}
+	return data, nil
+}
+
+// orderPrefix is the position of a test in its file name
+var orderPrefix = regexp.MustCompile(`^\d+-?`)
+
+// readTestCases reads the NNN-name.in/.out pairs of the directory, sorted by file name.
+// The names are taken, and removed, from `names`; the tests missing from it are named after their files.
+// A missing directory has no tests.
+func readTestCases(fsys fs.FS, dir string, names map[string]string, problems *[]string) ([]types.TestCase, error) {
+	testCases := []types.TestCase{}
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return testCases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := map[string]bool{}
+	outputs := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+			*problems = append(*problems, fmt.Sprintf("%s/%s: unexpected directory", dir, name))
+		case strings.HasSuffix(name, inputExt):
+			inputs[strings.TrimSuffix(name, inputExt)] = true
+		case strings.HasSuffix(name, outputExt):
+			outputs[strings.TrimSuffix(name, outputExt)] = true
+		default:
+			*problems = append(*problems, fmt.Sprintf("%s/%s: expected a %s or %s file", dir, name, inputExt, outputExt))
+		}
+	}
+
+	bases := []string{}
+	for base := range inputs {
+		if !outputs[base] {
+			*problems = append(*problems, fmt.Sprintf("%s/%s%s: missing %s", dir, base, inputExt, outputExt))
+			continue
+		}
+		bases = append(bases, base)
+	}
+	for base := range outputs {
+		if !inputs[base] {
+			*problems = append(*problems, fmt.Sprintf("%s/%s%s: missing %s", dir, base, outputExt, inputExt))
+		}
+	}
+	sort.Strings(bases)
+
+	for _, base := range bases {
+		input, err := readFile(fsys, path.Join(dir, base+inputExt))
+		if err != nil {
+			return nil, err
+		}
+		output, err := readFile(fsys, path.Join(dir, base+outputExt))
+		if err != nil {
+			return nil, err
+		}
+		name, ok := names[path.Join(dir, base)]
+		if ok {
+			delete(names, path.Join(dir, base))
+		} else {
+			name = testCaseName(base)
+		}
+		testCases = append(testCases, types.TestCase{
+			Name:   name,
+			Input:  string(input),
+			Output: string(output),
+		})
+	}
+	return testCases, nil
+}
+
+// readTemplates pairs the starter code of the templates directory with the signatures of the manifest.
+// A missing directory has no starter code.
+func readTemplates(fsys fs.FS, signatures map[string]string, problems *[]string) ([]types.ChallengeTemplate, error) {
+	starterCodes := map[string]string{}
+	entries, err := fs.ReadDir(fsys, TemplatesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		language := strings.TrimPrefix(name, starterCodePrefix)
+		if entry.IsDir() || language == name || language == "" {
+			*problems = append(*problems, fmt.Sprintf("%s/%s: expected a %s<language> file", TemplatesDir, name, starterCodePrefix))
+			continue
+		}
+		code, err := readFile(fsys, path.Join(TemplatesDir, name))
+		if err != nil {
+			return nil, err
+		}
+		starterCodes[language] = string(code)
+	}
+
+	templates := []types.ChallengeTemplate{}
+	for language, code := range starterCodes {
+		templates = append(templates, types.ChallengeTemplate{Language: language, Signature: strings.TrimSpace(signatures[language]), StarterCode: code})
+	}
+	for language, signature := range signatures {
+		if _, ok := starterCodes[language]; !ok {
+			templates = append(templates, types.ChallengeTemplate{Language: language, Signature: strings.TrimSpace(signature)})
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Language < templates[j].Language })
+	return templates, nil
+}
+
+// testCaseName guesses the name of a test from its file name, for the packages written by hand
+func testCaseName(base string) string {
+	return strings.ReplaceAll(orderPrefix.ReplaceAllString(base, ""), "_", " ")
+}
+
+var unsafeFileNameRunes = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// testCaseFileName keeps the order of the tests in the file names, the name is only a hint for who browses the package:
+// the spaces become underscores and the other unsafe characters are dropped, the manifest has the exact one
+func testCaseFileName(index int, name string) string {
+	base := fmt.Sprintf("%03d", index+1)
+	if name = unsafeFileNameRunes.ReplaceAllString(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"), ""); name != "" {
+		base += "-" + name
+	}
+	return base
+}
+
+type packageFile struct {
+	name string
+	data []byte
+}
+
+func (p *Package) files() ([]packageFile, error) {
+	manifest := p.Manifest
+	manifest.FormatVersion = FormatVersion
+	manifest.Signatures = nil
+	manifest.TestNames = nil
+	for _, template := range p.Templates {
+		if template.Signature == "" {
+			continue
+		}
+		if manifest.Signatures == nil {
+			manifest.Signatures = map[string]string{}
+		}
+		manifest.Signatures[template.Language] = template.Signature
+	}
+
+	files := []packageFile{
+		{ManifestFile, nil}, // encoded once the test names are known
+		{StatementFile, []byte(p.Statement)},
+	}
+	for _, list := range []struct {
+		dir       string
+		testCases []types.TestCase
+	}{{TestsDir, p.Tests}, {HiddenDir, p.HiddenTests}} {
+		for i, testCase := range list.testCases {
+			base := path.Join(list.dir, testCaseFileName(i, testCase.Name))
+			if testCase.Name != "" {
+				if manifest.TestNames == nil {
+					manifest.TestNames = map[string]string{}
+				}
+				manifest.TestNames[base] = testCase.Name
+			}
+			files = append(files,
+				packageFile{base + inputExt, []byte(testCase.Input)},
+				packageFile{base + outputExt, []byte(testCase.Output)},
+			)
+		}
+	}
+	for _, template := range p.Templates {
+		if template.StarterCode != "" {
+			files = append(files, packageFile{path.Join(TemplatesDir, starterCodePrefix+template.Language), []byte(template.StarterCode)})
+		}
+	}
+
+	manifestData := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(manifestData)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&manifest); err != nil {
+		return nil, err
+	}
+	files[0].data = manifestData.Bytes()
+	return files, nil
+}
+
+// WriteDir writes the package in the directory, creating it if needed.
+// The test and template directories are emptied first so removed ones don't linger.
+func (p *Package) WriteDir(dir string) error {
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+
+	for _, testsDir := range []string{TestsDir, HiddenDir, TemplatesDir} {
+		if err := os.RemoveAll(filepath.Join(dir, testsDir)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(dir, testsDir), 0o755); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(file.name)), file.data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Package) WriteZip(w io.Writer) error {
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,184 @@
+package challengepkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xedom/codeduel/types"
+)
+
+func testPackage() *Package {
+	return &Package{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			Title:         "Two sum",
+			Description:   "Add two numbers",
+			Difficulty:    types.DifficultyEasy,
+			Tags:          []string{"math"},
+		},
+		Statement: "Print the sum of the two numbers.\n",
+		Tests: []types.TestCase{
+			{Name: "my_case", Input: "1 2\n", Output: "3\n"},
+			{Name: "sum (big)", Input: "1000000 2000000\n", Output: "3000000\n"},
+			{Input: "0 0\n", Output: "0\n"},
+		},
+		HiddenTests: []types.TestCase{
+			{Name: "négatifs", Input: "-1 -2\n", Output: "-3\n"},
+			{Name: "my case", Input: "5 5\n", Output: "10\n"},
+		},
+		Templates: []types.ChallengeTemplate{
+			{Language: "go", Signature: "func sum(a, b int) int", StarterCode: "package main\n"},
+			{Language: "python", Signature: "def sum(a, b):"},
+		},
+	}
+}
+
+func writeTestZip(t *testing.T, p *Package) []byte {
+	t.Helper()
+	archive := &bytes.Buffer{}
+	if err := p.WriteZip(archive); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	p := testPackage()
+	read, err := ReadZip(writeTestZip(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := Diff(p, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Errorf("the package changed in the round trip: %+v", diff)
+	}
+	if read.Manifest.TestNames != nil || read.Manifest.Signatures != nil {
+		t.Errorf("the manifest kept the test names or the signatures, they belong to the tests and templates")
+	}
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	p := testPackage()
+	if err := p.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	p.Tests = p.Tests[:1] // the removed tests don't linger
+	if err := p.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := Diff(p, read); err != nil || len(diff) != 0 {
+		t.Errorf("the package changed in the round trip: %+v, %v", diff, err)
+	}
+}
+
+// zipFiles archives the files by name, for the packages and the archives of other platforms written by hand
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	data := &bytes.Buffer{}
+	archive := zip.NewWriter(data)
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return data.Bytes()
+}
+
+func TestReadTestNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		testNames string
+		want      []string
+		problem   string
+	}{
+		{"from the file names", "", []string{"my case", "sum"}, ""},
+		{"from the manifest", "test_names:\n  tests/001-my_case: my_case\n", []string{"my_case", "sum"}, ""},
+		{"unknown test", "test_names:\n  tests/003-gone: gone\n", nil, "tests/003-gone, which isn't a test"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ReadZip(zipFiles(t, map[string]string{
+				"sum/" + ManifestFile:       "format_version: 1\ntitle: Sum\ndifficulty: easy\n" + test.testNames,
+				"sum/" + StatementFile:      "Sum",
+				"sum/tests/001-my_case.in":  "1 2",
+				"sum/tests/001-my_case.out": "3",
+				"sum/tests/002-sum.in":      "2 2",
+				"sum/tests/002-sum.out":     "4",
+			}))
+			if test.problem != "" {
+				if err == nil || !strings.Contains(err.Error(), test.problem) {
+					t.Fatalf("ReadZip error = %v, want %q", err, test.problem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, testCase := range p.Tests {
+				names = append(names, testCase.Name)
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("test names = %q, want %q", names, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{"first version", "1", false},
+		{"current version", "2", false},
+		{"newer version", "3", true},
+		{"missing version", "0", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ReadZip(zipFiles(t, map[string]string{
+				ManifestFile:        "format_version: " + test.version + "\ntitle: Sum\ndifficulty: easy\n",
+				StatementFile:       "Sum",
+				"tests/001-sum.in":  "1 2",
+				"tests/001-sum.out": "3",
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate = %v, want an error %v", err, test.wantErr)
+			}
+		})
+	}
+
+	written, err := ReadZip(writeTestZip(t, testPackage()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.Manifest.FormatVersion != FormatVersion {
+		t.Errorf("written format_version = %d, want %d", written.Manifest.FormatVersion, FormatVersion)
+	}
+}
@@ -0,0 +1,227 @@
+package challengepkg
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	polygonManifestFile = "problem.xml"
+	polygonTestset      = "tests"
+	polygonLanguage     = "english"
+)
+
+// polygonProblem is the part of the problem.xml of a Polygon package the conversion reads
+type polygonProblem struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Statements []struct {
+		Language string `xml:"language,attr"`
+		Path     string `xml:"path,attr"`
+		Type     string `xml:"type,attr"`
+	} `xml:"statements>statement"`
+	Judging struct {
+		InputFile  string `xml:"input-file,attr"`
+		OutputFile string `xml:"output-file,attr"`
+		Testsets   []struct {
+			Name          string `xml:"name,attr"`
+			TimeLimit     int    `xml:"time-limit"`
+			MemoryLimit   int    `xml:"memory-limit"`
+			InputPattern  string `xml:"input-path-pattern"`
+			AnswerPattern string `xml:"answer-path-pattern"`
+			Tests         []struct {
+				Method string `xml:"method,attr"`
+				Cmd    string `xml:"cmd,attr"`
+				Sample bool   `xml:"sample,attr"`
+				Group  string `xml:"group,attr"`
+				Points string `xml:"points,attr"`
+			} `xml:"tests>test"`
+		} `xml:"testset"`
+	} `xml:"judging"`
+	Checker *struct {
+		Name string `xml:"name,attr"`
+	} `xml:"assets>checker"`
+	Interactor *struct{} `xml:"assets>interactor"`
+	Tags       []struct {
+		Value string `xml:"value,attr"`
+	} `xml:"tags>tag"`
+}
+
+// polygonPathPattern is a path pattern of a testset, numbering the tests with a single integer verb like tests/%02d
+var polygonPathPattern = regexp.MustCompile(`^(?:[^%]|%%)*%\d*d(?:[^%]|%%)*$`)
+
+// polygonStandardCheckers compare the output like the challenges do, token by token
+var polygonStandardCheckers = []string{"std::wcmp.cpp", "std::lcmp.cpp", "std::ncmp.cpp", "std::hcmp.cpp", "std::fcmp.cpp"}
+
+// polygonSections are the files of statement-sections/<language> in the order of the statement, with their heading
+var polygonSections = []struct {
+	file, heading string
+}{
+	{"legend.tex", ""},
+	{"input.tex", "Input"},
+	{"output.tex", "Output"},
+	{"interaction.tex", "Interaction"},
+	{"notes.tex", "Notes"},
+}
+
+// polygon converts a package of Polygon, the format Codeforces uses.
+// The samples become the public tests and the other tests of the `tests` testset the hidden ones.
+// Generated tests are only in the packages built with them, the "full" packages of Polygon.
+func (c *converter) polygon() error {
+	if err := c.root(polygonManifestFile); err != nil {
+		return err
+	}
+
+	data, err := readFile(c.fsys, polygonManifestFile)
+	if err != nil {
+		return err
+	}
+	problem := &polygonProblem{}
+	if err := xml.Unmarshal(data, problem); err != nil {
+		return fmt.Errorf("%s: %s", polygonManifestFile, err.Error())
+	}
+
+	c.pkg.Manifest.Title = problem.ShortName
+	for i, name := range problem.Names {
+		if i == 0 || name.Language == polygonLanguage {
+			c.pkg.Manifest.Title = name.Value
+		}
+	}
+	for _, tag := range problem.Tags {
+		c.pkg.Manifest.Tags = append(c.pkg.Manifest.Tags, tag.Value)
+	}
+
+	if err := c.polygonStatement(problem); err != nil {
+		return err
+	}
+
+	if problem.Judging.InputFile != "" || problem.Judging.OutputFile != "" {
+		c.unconverted("input and output files %q and %q, the tests use the standard input and output", problem.Judging.InputFile, problem.Judging.OutputFile)
+	}
+	if problem.Interactor != nil {
+		c.unconverted("interactor, interactive problems aren't supported")
+	}
+	if problem.Checker != nil && !slices.Contains(polygonStandardCheckers, problem.Checker.Name) {
+		c.unconverted("checker %s, the output must match the expected one", problem.Checker.Name)
+	}
+
+	found := false
+	for _, testset := range problem.Judging.Testsets {
+		if testset.Name != polygonTestset {
+			c.unconverted("testset %s", testset.Name)
+			continue
+		}
+		found = true
+		if !polygonPathPattern.MatchString(testset.InputPattern) || !polygonPathPattern.MatchString(testset.AnswerPattern) {
+			c.unconverted("testset %s, its path patterns %q and %q don't number the tests with a single %%d", testset.Name, testset.InputPattern, testset.AnswerPattern)
+			continue
+		}
+
+		c.unconverted("time limit of %d ms and memory limit of %d MB", testset.TimeLimit, testset.MemoryLimit>>20)
+		grouped := false
+		for i, test := range testset.Tests {
+			grouped = grouped || test.Group != "" || test.Points != ""
+			name := fmt.Sprintf("test %d", i+1)
+			if test.Sample {
+				name = fmt.Sprintf("sample %d", i+1)
+			}
+
+			input := fmt.Sprintf(testset.InputPattern, i+1)
+			if test.Method == "generated" && !c.exists(input) {
+				c.skip("generated by the package and missing from it", name)
+				continue
+			}
+			if err := c.addTestFiles(!test.Sample, name, input, fmt.Sprintf(testset.AnswerPattern, i+1)); err != nil {
+				return err
+			}
+		}
+		if grouped {
+			c.unconverted("test groups and points")
+		}
+	}
+	if !found {
+		c.unconverted("tests, the package has no %s testset", polygonTestset)
+	}
+
+	return nil
+}
+
+// polygonStatement reads the statement sections of the english statement or of the first one,
+// or their copy in problem-properties.json when the package has no statement-sections
+func (c *converter) polygonStatement(problem *polygonProblem) error {
+	language := ""
+	for i, statement := range problem.Statements {
+		if i == 0 || statement.Language == polygonLanguage {
+			language = statement.Language
+		}
+	}
+	if language == "" {
+		c.unconverted("statement, the package has none")
+		return nil
+	}
+	if language != polygonLanguage {
+		c.unconverted("statement only in %s, it wasn't translated", language)
+	}
+
+	sections := map[string]string{}
+	dir := path.Join("statement-sections", language)
+	for _, section := range polygonSections {
+		name := path.Join(dir, section.file)
+		if !c.exists(name) {
+			continue
+		}
+		data, err := readFile(c.fsys, name)
+		if err != nil {
+			return err
+		}
+		sections[section.file] = string(data)
+	}
+
+	if len(sections) == 0 {
+		name := path.Join("statements", language, "problem-properties.json")
+		if !c.exists(name) {
+			c.unconverted("statement, neither statement-sections nor problem-properties.json are in the package")
+			return nil
+		}
+		data, err := readFile(c.fsys, name)
+		if err != nil {
+			return err
+		}
+		properties := map[string]any{}
+		if err := json.Unmarshal(data, &properties); err != nil {
+			return fmt.Errorf("problem-properties.json: %s", err.Error())
+		}
+		for _, section := range polygonSections {
+			if text, ok := properties[strings.TrimSuffix(section.file, ".tex")].(string); ok && text != "" {
+				sections[section.file] = text
+			}
+		}
+	}
+
+	statement := &strings.Builder{}
+	for _, section := range polygonSections {
+		text, ok := sections[section.file]
+		if !ok || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if section.heading != "" {
+			fmt.Fprintf(statement, "\\section*{%s}\n\n", section.heading)
+		}
+		statement.WriteString(text + "\n\n")
+	}
+
+	var problems []string
+	c.pkg.Statement, problems = latexToMarkdown(statement.String())
+	for _, problem := range problems {
+		c.unconverted("statement: %s", problem)
+	}
+	return nil
+}
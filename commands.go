@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xedom/codeduel/challengepkg"
+	"github.com/xedom/codeduel/db"
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+const availableCommands = "rebuild-stats, export-challenge, import-challenge"
+
+// runCommand runs the admin command given on the command line instead of starting the server
+func runCommand(mariaDB *db.MariaDB, args []string) error {
+	switch args[0] {
+	case "rebuild-stats":
+		log.Printf("%s Rebuilding user stats from the lobby history", utils.GetLogTag("cmd"))
+		if err := mariaDB.RebuildUserStats(); err != nil {
+			return err
+		}
+		log.Printf("%s User stats rebuilt", utils.GetLogTag("cmd"))
+		return nil
+	case "export-challenge":
+		return exportChallenge(mariaDB, args[1:])
+	case "import-challenge":
+		return importChallenge(mariaDB, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, available commands: %s", args[0], availableCommands)
+	}
+}
+
+// exportChallenge writes a challenge as a package: export-challenge <challenge id> <directory or .zip file>
+func exportChallenge(mariaDB *db.MariaDB, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export-challenge <challenge id> <directory or .zip file>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid challenge id %q", args[0])
+	}
+
+	challenge, err := mariaDB.GetChallengeByIDFull(id)
+	if err != nil {
+		return err
+	}
+	templates, err := mariaDB.GetChallengeTemplates(id)
+	if err != nil {
+		return err
+	}
+	pkg := challengepkg.FromChallenge(challenge, templates)
+
+	destination := args[1]
+	if strings.HasSuffix(destination, ".zip") {
+		file, err := os.Create(destination)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := pkg.WriteZip(file); err != nil {
+			return err
+		}
+	} else if err := pkg.WriteDir(destination); err != nil {
+		return err
+	}
+
+	log.Printf("%s Challenge %d exported to %s", utils.GetLogTag("cmd"), id, destination)
+	return nil
+}
+
+// importChallenge creates or updates a challenge from a package:
+// import-challenge -author <user id> [-id <challenge id>] [-dry-run] [-format <format>] <directory or file>
+func importChallenge(mariaDB *db.MariaDB, args []string) error {
+	flags := flag.NewFlagSet("import-challenge", flag.ContinueOnError)
+	authorId := flags.Int("author", 0, "owner of a new challenge, author of the revision of an update")
+	challengeId := flags.Int("id", 0, "challenge to update, a new one is created when omitted")
+	dryRun := flags.Bool("dry-run", false, "validate the package and print the diff without saving")
+	format := flags.String("format", challengepkg.FormatCodeDuel, "format of the package: codeduel, or polygon, kattis and leetcode to convert a problem of another platform")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *authorId == 0 {
+		return fmt.Errorf("usage: import-challenge -author <user id> [-id <challenge id>] [-dry-run] [-format <format>] <directory or file>")
+	}
+
+	options := challengepkg.ImportOptions{
+		ChallengeId: *challengeId,
+		AuthorId:    *authorId,
+		DryRun:      *dryRun,
+	}
+	var pkg *challengepkg.Package
+	var err error
+	switch {
+	case *format == challengepkg.FormatCodeDuel:
+		pkg, err = challengepkg.Open(flags.Arg(0))
+	case challengepkg.IsConvertibleFormat(*format):
+		pkg, options.Conversion, err = challengepkg.ConvertPath(*format, flags.Arg(0))
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := challengepkg.Import(mariaDB, pkg, options)
+	if options.Conversion != nil {
+		printConversionReport(options.Conversion)
+	}
+	var validationErr *challengepkg.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("%s is not a valid package", flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	printImportDiff(result.Diff)
+	switch {
+	case result.DryRun:
+		log.Printf("%s Dry run, nothing was saved", utils.GetLogTag("cmd"))
+	case len(result.Diff) == 0:
+		log.Printf("%s Challenge %d is already up to date", utils.GetLogTag("cmd"), result.ChallengeId)
+	case result.Created:
+		log.Printf("%s Challenge %d created as a draft", utils.GetLogTag("cmd"), result.ChallengeId)
+	default:
+		log.Printf("%s Challenge %d updated", utils.GetLogTag("cmd"), result.ChallengeId)
+	}
+	return nil
+}
+
+func printConversionReport(report *types.ChallengeConversionReport) {
+	for _, unconverted := range report.Unconverted {
+		log.Printf("%s Not converted from %s: %s", utils.GetLogTag("cmd"), report.Format, unconverted)
+	}
+}
+
+func printImportDiff(diff []types.RevisionChange) {
+	for _, change := range diff {
+		fmt.Printf("--- %s\n", change.Field)
+		for _, line := range change.Lines {
+			fmt.Println(line)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// achievementMetricQueries compute the value of a metric for the user (first argument),
+// `%s` is replaced by the optional mode/language filters of the rule
+var achievementMetricQueries = map[string]string{
+	types.AchievementMetricGames: `SELECT COUNT(*) FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		WHERE lu.user_id = ?%s;`,
+	types.AchievementMetricWins: `SELECT COUNT(*) FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		WHERE lu.user_id = ? AND ` + lobbyWinCondition + `%s;`,
+	types.AchievementMetricSolves: `SELECT COUNT(*) FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		` + lobbyChallengeJoin + `
+		WHERE lu.user_id = ? AND ` + lobbySolvedCondition + `%s;`,
+	types.AchievementMetricFastSolves: `SELECT COUNT(*) FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		` + lobbyChallengeJoin + `
+		WHERE lu.user_id = ? AND ` + lobbySolvedCondition + `
+		AND TIMESTAMPDIFF(SECOND, l.created_at, lu.submitted_at) <= ?%s;`,
+	types.AchievementMetricLanguagesWon: `SELECT COUNT(DISTINCT lu.language) FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		WHERE lu.user_id = ? AND ` + lobbyWinCondition + `%s;`,
+	types.AchievementMetricAuthoredChallenges: "SELECT COUNT(*) FROM `challenge` ch WHERE ch.owner_id = ? AND ch.status = '" + types.ChallengeStatusPublished + "'%s;",
+	// lobby_user is only joined for the language filter, a lobby is a single play whatever its players
+	types.AchievementMetricAuthoredPlays: `SELECT COALESCE(MAX(plays), 0) FROM (
+			SELECT COUNT(DISTINCT l.id) AS plays FROM challenge ch
+			JOIN lobby l ON l.challenge_id = ch.id AND l.ended = TRUE
+			JOIN lobby_user lu ON lu.lobby_id = l.id
+			WHERE ch.owner_id = ?%s
+			GROUP BY ch.id
+		) plays;`,
+}
+
+func IsAchievementMetric(metric string) bool {
+	_, ok := achievementMetricQueries[metric]
+	return ok
+}
+
+// GetAchievementProgress returns the value of the metric of the rule for the user
+func (m *MariaDB) GetAchievementProgress(userId int, rule *types.AchievementRule) (int, error) {
+	query, ok := achievementMetricQueries[rule.Metric]
+	if !ok {
+		return 0, fmt.Errorf("DB(GetAchievementProgress): unknown metric %q", rule.Metric)
+	}
+
+	args := []any{userId}
+	if rule.Metric == types.AchievementMetricFastSolves {
+		args = append(args, rule.MaxSeconds)
+	}
+
+	filters := ""
+	if rule.Mode != "" {
+		filters += " AND l.mode = ?"
+		args = append(args, rule.Mode)
+	}
+	if rule.Language != "" {
+		filters += " AND lu.language = ?"
+		args = append(args, rule.Language)
+	}
+
+	var progress int
+	if err := m.db.QueryRow(fmt.Sprintf(query, filters), args...).Scan(&progress); err != nil {
+		return 0, fmt.Errorf("DB(GetAchievementProgress): %s", err.Error())
+	}
+	return progress, nil
+}
+
+func (m *MariaDB) GetAchievements() ([]*types.Achievement, error) {
+	query := `SELECT id, slug, name, description, icon, rule, created_at, updated_at FROM achievement ORDER BY id;`
+	return m.queryAchievements(query)
+}
+
+// GetLockedAchievements returns the achievements not unlocked yet by the user
+func (m *MariaDB) GetLockedAchievements(userId int) ([]*types.Achievement, error) {
+	query := `SELECT id, slug, name, description, icon, rule, created_at, updated_at FROM achievement a
+	WHERE NOT EXISTS (SELECT 1 FROM user_achievement ua WHERE ua.achievement_id = a.id AND ua.user_id = ?)
+	ORDER BY id;`
+	return m.queryAchievements(query, userId)
+}
+
+func (m *MariaDB) CreateAchievement(achievement *types.Achievement) error {
+	rule, err := json.Marshal(achievement.Rule)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO achievement (slug, name, description, icon, rule) VALUES (?, ?, ?, ?, ?);`
+	res, err := m.db.Exec(query, achievement.Slug, achievement.Name, achievement.Description, achievement.Icon, string(rule))
+	if err != nil {
+		return fmt.Errorf("DB(CreateAchievement): %s", err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	achievement.Id = int(id)
+	return nil
+}
+
+func (m *MariaDB) UpdateAchievement(achievement *types.Achievement) error {
+	rule, err := json.Marshal(achievement.Rule)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE achievement SET slug = ?, name = ?, description = ?, icon = ?, rule = ? WHERE id = ?;`
+	res, err := m.db.Exec(query, achievement.Slug, achievement.Name, achievement.Description, achievement.Icon, string(rule), achievement.Id)
+	if err != nil {
+		return fmt.Errorf("DB(UpdateAchievement): %s", err.Error())
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return fmt.Errorf("DB(UpdateAchievement): achievement with id %d not found", achievement.Id)
+	}
+	return nil
+}
+
+func (m *MariaDB) DeleteAchievement(id int) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM user_achievement WHERE achievement_id = ?;`, id); err != nil {
+		return fmt.Errorf("DB(DeleteAchievement): %s", err.Error())
+	}
+	if _, err := tx.Exec(`DELETE FROM achievement WHERE id = ?;`, id); err != nil {
+		return fmt.Errorf("DB(DeleteAchievement): %s", err.Error())
+	}
+
+	return tx.Commit()
+}
+
+// UnlockAchievement returns false if the user had already unlocked the achievement
+func (m *MariaDB) UnlockAchievement(userId, achievementId int) (bool, error) {
+	query := `INSERT IGNORE INTO user_achievement (user_id, achievement_id) VALUES (?, ?);`
+	res, err := m.db.Exec(query, userId, achievementId)
+	if err != nil {
+		return false, fmt.Errorf("DB(UnlockAchievement): %s", err.Error())
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+func (m *MariaDB) GetUserAchievements(userId int) ([]*types.UserAchievement, error) {
+	query := `SELECT a.slug, a.name, a.description, a.icon, ua.unlocked_at
+	FROM user_achievement ua
+	JOIN achievement a ON a.id = ua.achievement_id
+	WHERE ua.user_id = ?
+	ORDER BY ua.unlocked_at;`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserAchievements): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserAchievements): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	achievements := []*types.UserAchievement{}
+	for rows.Next() {
+		achievement := &types.UserAchievement{}
+		if err := rows.Scan(&achievement.Slug, &achievement.Name, &achievement.Description, &achievement.Icon, &achievement.UnlockedAt); err != nil {
+			return nil, fmt.Errorf("DB(GetUserAchievements): %s", err.Error())
+		}
+		achievements = append(achievements, achievement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserAchievements): %s", err.Error())
+	}
+
+	return achievements, nil
+}
+
+func (m *MariaDB) queryAchievements(query string, args ...any) ([]*types.Achievement, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(queryAchievements): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(queryAchievements): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	achievements := []*types.Achievement{}
+	for rows.Next() {
+		achievement := &types.Achievement{}
+		var rule string
+		if err := rows.Scan(
+			&achievement.Id,
+			&achievement.Slug,
+			&achievement.Name,
+			&achievement.Description,
+			&achievement.Icon,
+			&rule,
+			&achievement.CreatedAt,
+			&achievement.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(queryAchievements): %s", err.Error())
+		}
+		if err := json.Unmarshal([]byte(rule), &achievement.Rule); err != nil {
+			return nil, fmt.Errorf("DB(queryAchievements): achievement %d has an invalid rule: %s", achievement.Id, err.Error())
+		}
+		achievements = append(achievements, achievement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(queryAchievements): %s", err.Error())
+	}
+
+	return achievements, nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitAchievementTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableAchievement,
+		m.createTableUserAchievement,
+	}
+}
+
+func (m *MariaDB) createTableAchievement() error {
+	query := `CREATE TABLE IF NOT EXISTS achievement (
+		id INT AUTO_INCREMENT,
+		slug VARCHAR(50) NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		description VARCHAR(255) NOT NULL DEFAULT '',
+		icon VARCHAR(255) NOT NULL DEFAULT '',
+		rule JSON NOT NULL,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		UNIQUE INDEX (slug)
+	);`
+	if _, err := m.db.Exec(query); err != nil {
+		return err
+	}
+
+	defaultAchievements := []types.AchievementRequest{
+		{Slug: "first-win", Name: "First blood", Description: "Win a match", Rule: types.AchievementRule{Metric: types.AchievementMetricWins, Threshold: 1}},
+		{Slug: "polyglot", Name: "Polyglot", Description: "Win in 5 different languages", Rule: types.AchievementRule{Metric: types.AchievementMetricLanguagesWon, Threshold: 5}},
+		{Slug: "speedster", Name: "Speedster", Description: "Solve 10 challenges in under 2 minutes", Rule: types.AchievementRule{Metric: types.AchievementMetricFastSolves, Threshold: 10, MaxSeconds: 120}},
+		{Slug: "crowd-pleaser", Name: "Crowd pleaser", Description: "Author a challenge played 100 times", Rule: types.AchievementRule{Metric: types.AchievementMetricAuthoredPlays, Threshold: 100}},
+	}
+
+	for _, achievement := range defaultAchievements {
+		rule, err := json.Marshal(achievement.Rule)
+		if err != nil {
+			return err
+		}
+		query := `INSERT IGNORE INTO achievement (slug, name, description, rule) VALUES (?, ?, ?, ?);`
+		if _, err := m.db.Exec(query, achievement.Slug, achievement.Name, achievement.Description, string(rule)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MariaDB) createTableUserAchievement() error {
+	query := `CREATE TABLE IF NOT EXISTS user_achievement (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		achievement_id INT NOT NULL,
+
+		unlocked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		FOREIGN KEY (achievement_id) REFERENCES achievement(id),
+		UNIQUE INDEX (user_id, achievement_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// GetUserActivity returns the number of matches the user submitted to each day since `from`,
+// days without matches are omitted
+func (m *MariaDB) GetUserActivity(userId int, from string) ([]*types.ActivityDay, error) {
+	query := `SELECT DATE_FORMAT(lu.submitted_at, '%Y-%m-%d') AS day, COUNT(*)
+	FROM lobby_user lu
+	JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+	WHERE lu.user_id = ? AND lu.submitted_at >= ?
+	GROUP BY day
+	ORDER BY day;`
+
+	rows, err := m.db.Query(query, userId, from)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserActivity): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserActivity): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	days := []*types.ActivityDay{}
+	for rows.Next() {
+		day := &types.ActivityDay{}
+		if err := rows.Scan(&day.Date, &day.Matches); err != nil {
+			return nil, fmt.Errorf("DB(GetUserActivity): %s", err.Error())
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserActivity): %s", err.Error())
+	}
+
+	return days, nil
+}
+
+// GetUserLanguageStats returns the results of the user in each language, most played first
+func (m *MariaDB) GetUserLanguageStats(userId int) ([]*types.LanguageStats, error) {
+	query := `SELECT
+		lu.language,
+		COUNT(*),
+		COALESCE(SUM(` + lobbyWinCondition + `), 0),
+		COALESCE(AVG(lu.tests_passed), 0)
+	FROM lobby_user lu
+	JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+	WHERE lu.user_id = ? AND lu.language IS NOT NULL AND lu.submitted_at IS NOT NULL
+	GROUP BY lu.language
+	ORDER BY COUNT(*) DESC, lu.language;`
+
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserLanguageStats): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserLanguageStats): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	languages := []*types.LanguageStats{}
+	for rows.Next() {
+		language := &types.LanguageStats{}
+		if err := rows.Scan(&language.Language, &language.Matches, &language.Wins, &language.AvgTestsPassed); err != nil {
+			return nil, fmt.Errorf("DB(GetUserLanguageStats): %s", err.Error())
+		}
+		if language.Matches > 0 {
+			language.WinRate = float64(language.Wins) / float64(language.Matches)
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserLanguageStats): %s", err.Error())
+	}
+
+	return languages, nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// BlockUser blocks `blockedId` for `blockerId`, removing the follows between the two users
+func (m *MariaDB) BlockUser(blockerId, blockedId int) error {
+	if blockerId == blockedId {
+		return fmt.Errorf("DB(BlockUser): a user cannot block themselves")
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(`INSERT IGNORE INTO user_block (blocker_id, blocked_id) VALUES (?, ?);`, blockerId, blockedId); err != nil {
+		return fmt.Errorf("DB(BlockUser): %s", err.Error())
+	}
+
+	query := `DELETE FROM user_follow WHERE (follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?);`
+	if _, err := tx.Exec(query, blockerId, blockedId, blockedId, blockerId); err != nil {
+		return fmt.Errorf("DB(BlockUser): %s", err.Error())
+	}
+
+	return tx.Commit()
+}
+
+func (m *MariaDB) UnblockUser(blockerId, blockedId int) error {
+	query := `DELETE FROM user_block WHERE blocker_id = ? AND blocked_id = ?;`
+	_, err := m.db.Exec(query, blockerId, blockedId)
+	return err
+}
+
+// GetBlockedUsers returns the users blocked by `userId`
+func (m *MariaDB) GetBlockedUsers(userId int) ([]*types.UserResponse, error) {
+	query := `SELECT ` + userResponseColumns + ` FROM user u
+	JOIN user_block b ON b.blocked_id = u.id
+	WHERE b.blocker_id = ?
+	ORDER BY b.created_at DESC;`
+
+	users, err := m.queryUserResponses(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetBlockedUsers): %s", err.Error())
+	}
+	return users, nil
+}
+
+// GetBlockedUserIDs returns the users that must never be paired with `userId`:
+// the ones blocked by them and the ones that blocked them
+func (m *MariaDB) GetBlockedUserIDs(userId int) ([]int, error) {
+	query := `SELECT blocked_id FROM user_block WHERE blocker_id = ?
+	UNION
+	SELECT blocker_id FROM user_block WHERE blocked_id = ?;`
+
+	rows, err := m.db.Query(query, userId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetBlockedUserIDs): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetBlockedUserIDs): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("DB(GetBlockedUserIDs): %s", err.Error())
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetBlockedUserIDs): %s", err.Error())
+	}
+
+	return ids, nil
+}
+
+// HasBlockBetween reports whether any of the given users blocked another one of them
+func (m *MariaDB) HasBlockBetween(userIds []int) (bool, error) {
+	if len(userIds) < 2 {
+		return false, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(userIds)), ",")
+	args := make([]any, 0, len(userIds)*2)
+	for _, id := range userIds {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+
+	query := `SELECT EXISTS(SELECT 1 FROM user_block
+		WHERE blocker_id IN (` + placeholders + `) AND blocked_id IN (` + placeholders + `));`
+
+	var blocked bool
+	if err := m.db.QueryRow(query, args...).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("DB(HasBlockBetween): %s", err.Error())
+	}
+	return blocked, nil
+}
+
+func (m *MariaDB) createTableUserBlock() error {
+	query := `CREATE TABLE IF NOT EXISTS user_block (
+		id INT AUTO_INCREMENT,
+		blocker_id INT NOT NULL,
+		blocked_id INT NOT NULL,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (blocker_id) REFERENCES user(id),
+		FOREIGN KEY (blocked_id) REFERENCES user(id),
+		UNIQUE INDEX (blocker_id, blocked_id),
+		INDEX (blocked_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -1,17 +1,25 @@
 package db
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/xedom/codeduel/types"
 	"github.com/xedom/codeduel/utils"
 )
 
-func (m *MariaDB) GetChallenges() (*[]types.Challenge, error) {
-	query := "SELECT id, owner_id, title, description, content, tests, created_at, updated_at FROM `challenge`;"
-	rows, err := m.db.Query(query)
+func (m *MariaDB) GetChallenges(filter *types.ChallengeFilter) (*[]types.Challenge, error) {
+	conditions, args := challengeFilterConditions(filter)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := "SELECT " + challengeColumns + " FROM `challenge` c" + where + ";"
+	rows, err := m.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -23,74 +31,163 @@ func (m *MariaDB) GetChallenges() (*[]types.Challenge, error) {
 
 	challenges := &[]types.Challenge{}
 	for rows.Next() {
-		var challenge types.Challenge
-		var testCases string
+		challenge, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		*challenges = append(*challenges, *challenge)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return challenges, m.attachChallengeTags(*challenges)
+}
+
+// GetChallengeSummaries returns a page of challenges without content and tests, keyset paginated on the sort value
+func (m *MariaDB) GetChallengeSummaries(listQuery *types.ChallengeListQuery) (*types.ChallengeListResponse, error) {
+	sortValue := "t.id"
+	switch listQuery.Sort {
+	case "", types.ChallengeSortNewest:
+	case types.ChallengeSortMostPlayed:
+		sortValue = "t.play_count"
+	case types.ChallengeSortHighestRated:
+		// unrated challenges come last
+		sortValue = "t.rating"
+	default:
+		return nil, fmt.Errorf("DB(GetChallengeSummaries): unsupported sort %q", listQuery.Sort)
+	}
 
+	conditions, args := challengeFilterConditions(&listQuery.Filter)
+	return m.queryChallengeSummaries("GetChallengeSummaries", conditions, args, sortValue, false, listQuery.Cursor, listQuery.Limit)
+}
+
+// queryChallengeSummaries returns a page of the challenges matching the conditions on the challenge table
+// (aliased as c), sorted by `sortValue` on the summary columns (aliased as t) and then by id
+func (m *MariaDB) queryChallengeSummaries(funcName string, conditions []string, args []any, sortValue string, ascending bool, cursor string, limit int) (*types.ChallengeListResponse, error) {
+	decodedCursor, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	direction, comparison := "DESC", "<"
+	if ascending {
+		direction, comparison = "ASC", ">"
+	}
+	pageCondition := ""
+	if decodedCursor != nil {
+		pageCondition = "WHERE " + sortValue + " " + comparison + " ? OR (" + sortValue + " = ? AND t.id " + comparison + " ?)"
+		args = append(args, decodedCursor.Value, decodedCursor.Value, decodedCursor.Id)
+	}
+	args = append(args, limit+1)
+
+	// the counters are kept on the challenge so the page condition can use their indexes
+	query := `SELECT t.id, t.owner_id, t.owner_name, t.owner_username, t.owner_avatar, t.title, t.description,
+		t.difficulty, t.computed_difficulty, t.status, t.status_updated_at, t.play_count, IF(t.rating_count > 0, t.rating, NULL), t.rating_count,
+		t.created_at, t.updated_at, ` + sortValue + `
+	FROM (
+		SELECT c.id, c.owner_id, u.name AS owner_name, u.username AS owner_username, u.avatar AS owner_avatar,
+			c.title, c.description, c.difficulty, c.computed_difficulty, c.status, c.status_updated_at, c.created_at, c.updated_at,
+			c.play_count, c.rating, c.rating_count
+		FROM challenge c
+		JOIN user u ON u.id = c.owner_id
+		` + where + `
+	) t
+	` + pageCondition + `
+	ORDER BY ` + sortValue + ` ` + direction + `, t.id ` + direction + `
+	LIMIT ?;`
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(%s): %s", utils.GetLogTag("DB"), funcName, err)
+		}
+	}()
+
+	response := &types.ChallengeListResponse{Challenges: []*types.ChallengeSummary{}}
+	positions := []*utils.Cursor{}
+	for rows.Next() {
+		challenge := &types.ChallengeSummary{Tags: []string{}}
+		position := &utils.Cursor{}
 		if err := rows.Scan(
 			&challenge.Id,
-			&challenge.OwnerId,
-
+			&challenge.Owner.Id,
+			&challenge.Owner.Name,
+			&challenge.Owner.Username,
+			&challenge.Owner.Avatar,
 			&challenge.Title,
 			&challenge.Description,
-			&challenge.Content,
-
-			&testCases,
-
+			&challenge.Difficulty,
+			&challenge.ComputedDifficulty,
+			&challenge.Status,
+			&challenge.StatusUpdatedAt,
+			&challenge.PlayCount,
+			&challenge.Rating,
+			&challenge.RatingCount,
 			&challenge.CreatedAt,
 			&challenge.UpdatedAt,
+			&position.Value,
 		); err != nil {
-			return nil, err
-		}
-
-		if err := json.Unmarshal([]byte(testCases), &challenge.TestCases); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
 		}
+		position.Id = challenge.Id
+		response.Challenges = append(response.Challenges, challenge)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
 
-		*challenges = append(*challenges, challenge)
+	if len(response.Challenges) > limit {
+		response.Challenges = response.Challenges[:limit]
+		response.NextCursor = utils.EncodeCursor(positions[limit-1])
 	}
 
-	if err := rows.Err(); err != nil {
+	ids := make([]int, len(response.Challenges))
+	for i, challenge := range response.Challenges {
+		ids[i] = challenge.Id
+	}
+	tags, err := m.getChallengesTags(ids)
+	if err != nil {
 		return nil, err
 	}
+	for _, challenge := range response.Challenges {
+		challenge.Tags = tags[challenge.Id]
+	}
 
-	return challenges, nil
+	return response, nil
 }
 
 func (m *MariaDB) GetChallengeByID(id int) (*types.Challenge, error) {
-	query := "SELECT id, owner_id, title, description, content, tests, created_at, updated_at FROM `challenge` WHERE id = ? LIMIT 1;"
+	query := "SELECT " + challengeColumns + " FROM `challenge` c WHERE c.id = ? LIMIT 1;"
 	row := m.db.QueryRow(query, id)
 	if row == nil {
 		return nil, fmt.Errorf("challenge not found")
 	}
 
-	challenge := &types.Challenge{}
-	var testCases string
-
-	if err := row.Scan(
-		&challenge.Id,
-		&challenge.OwnerId,
-
-		&challenge.Title,
-		&challenge.Description,
-		&challenge.Content,
-
-		&testCases,
-
-		&challenge.CreatedAt,
-		&challenge.UpdatedAt,
-	); err != nil {
+	challenge, err := scanChallenge(row)
+	if err != nil {
 		return nil, fmt.Errorf("row.Scan | id: %d | %w", id, err)
 	}
 
-	if err := json.Unmarshal([]byte(testCases), &challenge.TestCases); err != nil {
+	tags, err := m.getChallengesTags([]int{challenge.Id})
+	if err != nil {
 		return nil, err
 	}
+	challenge.Tags = tags[challenge.Id]
 
 	return challenge, nil
 }
 
 func (m *MariaDB) GetChallengesByOwnerID(ownerID int) (*[]types.Challenge, error) {
-	query := "SELECT id, owner_id, title, description, content, tests, created_at, updated_at FROM `challenge` WHERE owner_id = ?;"
+	query := "SELECT " + challengeColumns + " FROM `challenge` c WHERE c.owner_id = ?;"
 	rows, err := m.db.Query(query, ownerID)
 	if err != nil {
 		return nil, err
@@ -103,92 +200,104 @@ func (m *MariaDB) GetChallengesByOwnerID(ownerID int) (*[]types.Challenge, error
 
 	challenges := &[]types.Challenge{}
 	for rows.Next() {
-		var challenge types.Challenge
-		var testCases string
-		err := rows.Scan(
-			&challenge.Id,
-			&challenge.OwnerId,
-
-			&challenge.Title,
-			&challenge.Description,
-			&challenge.Content,
-
-			&testCases,
-
-			&challenge.CreatedAt,
-			&challenge.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		err = json.Unmarshal([]byte(testCases), &challenge.TestCases)
+		challenge, err := scanChallenge(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		*challenges = append(*challenges, challenge)
+		*challenges = append(*challenges, *challenge)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return challenges, nil
+	return challenges, m.attachChallengeTags(*challenges)
 }
 
-func (m *MariaDB) GetRandomChallengeFull() (*types.ChallengeFull, error) {
-	query := `
-	SELECT
-	c.id, c.title, c.description, c.content, c.created_at, c.updated_at,
-	c.owner_id, u.name, u.username, u.avatar,
-	c.tests, c.tests_hidden
-	FROM challenge c
-	JOIN user u ON c.owner_id = u.id
-	ORDER BY RAND()
-	LIMIT 1;`
-
-	row := m.db.QueryRow(query)
-	if row == nil {
-		return nil, fmt.Errorf("challenge not found")
-	}
+// challengeColumns are the columns read by scanChallenge, the challenge table is aliased as c
+const challengeColumns = "c.id, c.owner_id, c.title, c.description, c.content, c.difficulty, c.computed_difficulty, c.status, COALESCE(c.tests, '[]'), c.created_at, c.updated_at"
 
-	if row.Err() != nil {
-		return nil, row.Err()
-	}
-
-	challenge := &types.ChallengeFull{}
-	var testCases, hiddenTestCases string
+func scanChallenge(row interface{ Scan(...any) error }) (*types.Challenge, error) {
+	challenge := &types.Challenge{Tags: []string{}}
+	var testCases string
 
-	err := row.Scan(
+	if err := row.Scan(
 		&challenge.Id,
+		&challenge.OwnerId,
+
 		&challenge.Title,
 		&challenge.Description,
 		&challenge.Content,
+
+		&challenge.Difficulty,
+		&challenge.ComputedDifficulty,
+		&challenge.Status,
+
+		&testCases,
+
 		&challenge.CreatedAt,
 		&challenge.UpdatedAt,
-		&challenge.Owner.Id,
-		&challenge.Owner.Name,
-		&challenge.Owner.Username,
-		&challenge.Owner.Avatar,
-		&testCases,
-		&hiddenTestCases,
-	)
-	if err != nil {
+	); err != nil {
 		return nil, err
 	}
 
-	err = json.Unmarshal([]byte(testCases), &challenge.TestCases)
-	if err != nil {
+	if err := json.Unmarshal([]byte(testCases), &challenge.TestCases); err != nil {
 		return nil, err
 	}
 
-	err = json.Unmarshal([]byte(hiddenTestCases), &challenge.HiddenTestCases)
-	if err != nil {
-		return nil, err
+	return challenge, nil
+}
+
+// challengeFilterConditions returns the WHERE conditions on the challenge table (aliased as c) matching the filter
+func challengeFilterConditions(filter *types.ChallengeFilter) ([]string, []any) {
+	conditions := []string{}
+	args := []any{}
+	if filter == nil {
+		return conditions, args
 	}
 
-	return challenge, nil
+	if filter.Difficulty != "" {
+		conditions = append(conditions, "COALESCE(c.computed_difficulty, c.difficulty) = ?")
+		args = append(args, filter.Difficulty)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "c.status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.OwnerId != 0 {
+		conditions = append(conditions, "c.owner_id = ?")
+		args = append(args, filter.OwnerId)
+	}
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM challenge_tag ct JOIN tag t ON t.id = ct.tag_id WHERE ct.challenge_id = c.id AND t.slug = ?)")
+		args = append(args, tag)
+	}
+
+	return conditions, args
+}
+
+func (m *MariaDB) GetRandomChallengeFull(filter *types.ChallengeFilter) (*types.ChallengeFull, error) {
+	conditions, args := challengeFilterConditions(filter)
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+	SELECT
+	c.id, c.title, c.description, c.content, c.created_at, c.updated_at,
+	c.owner_id, u.name, u.username, u.avatar,
+	c.difficulty, c.computed_difficulty, c.status,
+	COALESCE(c.tests, '[]'), COALESCE(c.tests_hidden, '[]'),
+	c.revision, COALESCE(cr.id, 0)
+	FROM challenge c
+	JOIN user u ON c.owner_id = u.id
+	LEFT JOIN challenge_revision cr ON cr.challenge_id = c.id AND cr.revision = c.revision
+	` + where + `
+	ORDER BY RAND()
+	LIMIT 1;`
+
+	return m.getChallengeFull("GetRandomChallengeFull", query, args...)
 }
 
 func (m *MariaDB) GetChallengeByIDFull(id int) (*types.ChallengeFull, error) {
@@ -196,17 +305,20 @@ func (m *MariaDB) GetChallengeByIDFull(id int) (*types.ChallengeFull, error) {
 	SELECT
 	c.id, c.title, c.description, c.content, c.created_at, c.updated_at,
 	c.owner_id, u.name, u.username, u.avatar,
-	c.tests, c.tests_hidden
+	c.difficulty, c.computed_difficulty, c.status,
+	COALESCE(c.tests, '[]'), COALESCE(c.tests_hidden, '[]'),
+	c.revision, COALESCE(cr.id, 0)
 	FROM challenge c
 	JOIN user u ON c.owner_id = u.id
+	LEFT JOIN challenge_revision cr ON cr.challenge_id = c.id AND cr.revision = c.revision
 	WHERE c.id = ?
 	LIMIT 1;`
 
-	row := m.db.QueryRow(query, id)
-	if row == nil {
-		return nil, fmt.Errorf("challenge not found")
-	}
+	return m.getChallengeFull("GetChallengeByIDFull", query, id)
+}
 
+func (m *MariaDB) getChallengeFull(funcName, query string, args ...any) (*types.ChallengeFull, error) {
+	row := m.db.QueryRow(query, args...)
 	if row.Err() != nil {
 		return nil, row.Err()
 	}
@@ -225,9 +337,17 @@ func (m *MariaDB) GetChallengeByIDFull(id int) (*types.ChallengeFull, error) {
 		&challenge.Owner.Name,
 		&challenge.Owner.Username,
 		&challenge.Owner.Avatar,
+		&challenge.Difficulty,
+		&challenge.ComputedDifficulty,
+		&challenge.Status,
 		&testCases,
 		&hiddenTestCases,
+		&challenge.Revision,
+		&challenge.RevisionId,
 	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("DB(%s): challenge not found", funcName)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -242,12 +362,49 @@ func (m *MariaDB) GetChallengeByIDFull(id int) (*types.ChallengeFull, error) {
 		return nil, err
 	}
 
+	tags, err := m.getChallengesTags([]int{challenge.Id})
+	if err != nil {
+		return nil, err
+	}
+	challenge.Tags = tags[challenge.Id]
+
 	return challenge, nil
 }
 
+// CreateChallenge stores the challenge and its first revision
 func (m *MariaDB) CreateChallenge(challenge *types.Challenge) error {
-	query := "INSERT INTO `challenge` (owner_id, title, description, content) VALUES (?, ?, ?, ?);"
-	res, err := m.db.Exec(query, challenge.OwnerId, challenge.Title, challenge.Description, challenge.Content)
+	snapshot := &challengeSnapshot{
+		title:       challenge.Title,
+		description: challenge.Description,
+		content:     challenge.Content,
+		tests:       challenge.TestCases,
+		hiddenTests: challenge.HiddenTestCases,
+		templates:   snapshotTemplates(challenge.Templates),
+	}
+	diff, err := diffSnapshots(&challengeSnapshot{}, snapshot)
+	if err != nil {
+		return err
+	}
+	tests, err := marshalTestCases(snapshot.tests)
+	if err != nil {
+		return err
+	}
+	hiddenTests, err := marshalTestCases(snapshot.hiddenTests)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	challenge.Status = types.ChallengeStatusDraft
+	query := "INSERT INTO `challenge` (owner_id, title, description, content, difficulty, status, tests, tests_hidden, revision) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1);"
+	res, err := tx.Exec(query, challenge.OwnerId, challenge.Title, challenge.Description, challenge.Content, challenge.Difficulty, challenge.Status, tests, hiddenTests)
 	if err != nil {
 		return err
 	}
@@ -255,13 +412,44 @@ func (m *MariaDB) CreateChallenge(challenge *types.Challenge) error {
 	if err != nil {
 		return err
 	}
+	if err := saveChallengeTemplates(tx, int(id), nil, snapshot.templates); err != nil {
+		return err
+	}
+	if err := insertChallengeRevision(tx, int(id), 1, challenge.OwnerId, nil, snapshot, diff); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	challenge.Id = int(id)
 	return nil
 }
 
-func (m *MariaDB) UpdateChallenge(challenge *types.Challenge) error {
-	query := "UPDATE `challenge` SET title = ?, description = ?, content = ? WHERE id = ?;"
-	_, err := m.db.Exec(query, challenge.Title, challenge.Description, challenge.Content, challenge.Id)
+// UpdateChallenge stores the new statement as a new revision authored by `authorId`,
+// the tests and the templates are only replaced when not nil and the difficulty when not empty
+func (m *MariaDB) UpdateChallenge(challenge *types.Challenge, authorId int) error {
+	_, err := m.reviseChallenge(challenge.Id, authorId, nil, func(tx *sql.Tx, snapshot *challengeSnapshot) error {
+		if challenge.Difficulty != "" {
+			query := "UPDATE `challenge` SET difficulty = ? WHERE id = ?;"
+			if _, err := tx.Exec(query, challenge.Difficulty, challenge.Id); err != nil {
+				return fmt.Errorf("DB(UpdateChallenge): %s", err.Error())
+			}
+		}
+		snapshot.title = challenge.Title
+		snapshot.description = challenge.Description
+		snapshot.content = challenge.Content
+		if challenge.TestCases != nil {
+			snapshot.tests = challenge.TestCases
+		}
+		if challenge.HiddenTestCases != nil {
+			snapshot.hiddenTests = challenge.HiddenTestCases
+		}
+		if challenge.Templates != nil {
+			snapshot.templates = challenge.Templates
+		}
+		return nil
+	})
 	return err
 }
 
@@ -271,10 +459,203 @@ func (m *MariaDB) DeleteChallenge(id int) error {
 	return err
 }
 
+// GetChallengeOwnerID returns the owner of the challenge without loading it
+func (m *MariaDB) GetChallengeOwnerID(challengeId int) (int, error) {
+	var ownerId int
+	err := m.db.QueryRow("SELECT owner_id FROM `challenge` WHERE id = ?;", challengeId).Scan(&ownerId)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("DB(GetChallengeOwnerID): challenge with id %d not found", challengeId)
+	}
+	return ownerId, err
+}
+
+// GetChallengeTestCases returns both test lists of the challenge, it's up to the caller to hide the hidden ones
+func (m *MariaDB) GetChallengeTestCases(challengeId int) (*types.ChallengeTestCases, error) {
+	query := "SELECT COALESCE(tests, '[]'), COALESCE(tests_hidden, '[]') FROM `challenge` WHERE id = ?;"
+	var tests, hiddenTests string
+	err := m.db.QueryRow(query, challengeId).Scan(&tests, &hiddenTests)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("DB(GetChallengeTestCases): challenge with id %d not found", challengeId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTestCases): %s", err.Error())
+	}
+
+	testCases := &types.ChallengeTestCases{}
+	if err := json.Unmarshal([]byte(tests), &testCases.TestCases); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTestCases): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(hiddenTests), &testCases.HiddenTestCases); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTestCases): %s", err.Error())
+	}
+
+	return testCases, nil
+}
+
+// SetChallengeTestCases replaces the public or hidden test list of the challenge as a new revision
+func (m *MariaDB) SetChallengeTestCases(challengeId, authorId int, visibility string, testCases []types.TestCase) error {
+	if !types.IsTestCaseVisibility(visibility) {
+		return fmt.Errorf("DB(SetChallengeTestCases): unknown test visibility %q", visibility)
+	}
+
+	_, err := m.reviseChallenge(challengeId, authorId, nil, func(_ *sql.Tx, snapshot *challengeSnapshot) error {
+		if visibility == types.TestCaseVisibilityHidden {
+			snapshot.hiddenTests = testCases
+		} else {
+			snapshot.tests = testCases
+		}
+		return nil
+	})
+	return err
+}
+
+// marshalTestCases stores a missing list as an empty array, NULL tests can't be decoded
+func marshalTestCases(testCases []types.TestCase) (string, error) {
+	if testCases == nil {
+		testCases = []types.TestCase{}
+	}
+	data, err := json.Marshal(testCases)
+	return string(data), err
+}
+
+// the computed difficulty needs enough submissions, then it's derived from the share of them solving the challenge
+const (
+	minDifficultySubmissions = 10
+	easySolveRate            = 0.6
+	mediumSolveRate          = 0.3
+)
+
+func computedDifficulty(submissions, solves int) *string {
+	if submissions < minDifficultySubmissions {
+		return nil
+	}
+
+	difficulty := types.DifficultyHard
+	switch rate := float64(solves) / float64(submissions); {
+	case rate >= easySolveRate:
+		difficulty = types.DifficultyEasy
+	case rate >= mediumSolveRate:
+		difficulty = types.DifficultyMedium
+	}
+	return &difficulty
+}
+
+// RefreshComputedDifficulty recomputes the difficulty of the challenge from the submissions of its ended lobbies,
+// every challenge is refreshed when `challengeId` is 0
+func (m *MariaDB) RefreshComputedDifficulty(challengeId int) error {
+	query := `SELECT ch.id, COUNT(lu.id), COALESCE(SUM(` + lobbySolvedCondition + `), 0)
+	FROM challenge ch
+	LEFT JOIN lobby l ON l.challenge_id = ch.id AND l.ended = TRUE
+	LEFT JOIN challenge_revision cr ON cr.id = l.challenge_revision_id
+	LEFT JOIN lobby_user lu ON lu.lobby_id = l.id AND lu.language IS NOT NULL
+	WHERE ? = 0 OR ch.id = ?
+	GROUP BY ch.id;`
+	rows, err := m.db.Query(query, challengeId, challengeId)
+	if err != nil {
+		return fmt.Errorf("DB(RefreshComputedDifficulty): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(RefreshComputedDifficulty): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	difficulties := map[int]*string{}
+	for rows.Next() {
+		var id, submissions, solves int
+		if err := rows.Scan(&id, &submissions, &solves); err != nil {
+			return fmt.Errorf("DB(RefreshComputedDifficulty): %s", err.Error())
+		}
+		difficulties[id] = computedDifficulty(submissions, solves)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("DB(RefreshComputedDifficulty): %s", err.Error())
+	}
+
+	for id, difficulty := range difficulties {
+		if _, err := m.db.Exec("UPDATE `challenge` SET computed_difficulty = ? WHERE id = ?;", difficulty, id); err != nil {
+			return fmt.Errorf("DB(RefreshComputedDifficulty): %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// RateChallenge stores the rating the user gives to the challenge, replacing the previous one.
+// Only players that finished a match on the challenge can rate it, `rated` is false for the others.
+func (m *MariaDB) RateChallenge(challengeId, userId, rating int) (rated bool, err error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id
+		WHERE lu.user_id = ? AND l.challenge_id = ? AND l.ended = TRUE
+	);`
+	var played bool
+	if err := m.db.QueryRow(query, userId, challengeId).Scan(&played); err != nil {
+		return false, fmt.Errorf("DB(RateChallenge): %s", err.Error())
+	}
+	if !played {
+		return false, nil
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query = `INSERT INTO challenge_rating (challenge_id, user_id, rating) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE rating = VALUES(rating), updated_at = CURRENT_TIMESTAMP;`
+	if _, err := tx.Exec(query, challengeId, userId, rating); err != nil {
+		return false, fmt.Errorf("DB(RateChallenge): %s", err.Error())
+	}
+	if err := refreshChallengeRating(tx, "c.id = ?", challengeId); err != nil {
+		return false, fmt.Errorf("DB(RateChallenge): %s", err.Error())
+	}
+
+	return true, tx.Commit()
+}
+
+// refreshChallengeRating recomputes the rating counters of the challenges matching the condition on `challenge c`
+func refreshChallengeRating(tx *sql.Tx, condition string, args ...any) error {
+	query := `UPDATE challenge c SET
+		rating = COALESCE((SELECT AVG(r.rating) FROM challenge_rating r WHERE r.challenge_id = c.id), 0),
+		rating_count = (SELECT COUNT(*) FROM challenge_rating r WHERE r.challenge_id = c.id)
+	WHERE ` + condition + `;`
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// GetChallengeRating returns the average rating of the challenge and the one given by the user, if any
+func (m *MariaDB) GetChallengeRating(challengeId, userId int) (*types.ChallengeRating, error) {
+	query := `SELECT AVG(rating), COUNT(*), COALESCE(MAX(CASE WHEN user_id = ? THEN rating END), 0)
+	FROM challenge_rating WHERE challenge_id = ?;`
+
+	rating := &types.ChallengeRating{}
+	if err := m.db.QueryRow(query, userId, challengeId).Scan(&rating.Rating, &rating.RatingCount, &rating.UserRating); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRating): %s", err.Error())
+	}
+	return rating, nil
+}
+
 // -- Init Tables --
 func (m *MariaDB) InitChallengeTables() []MigrationFunc {
 	return []MigrationFunc{
 		m.createTableChallenge,
+		m.migrateChallengeTestsNotNull,
+		m.createTableChallengeRevision,
+		m.migrateChallengeRevisions,
+		m.migrateChallengeDifficulty,
+		m.createChallengeSearchIndexes,
+		m.createTableTag,
+		m.createTableChallengeTag,
+		m.seedTags,
+		m.createTableChallengeRating,
+		m.migrateChallengeStatus,
+		m.createTableChallengeReview,
+		m.createTableChallengeTemplate,
+		m.migrateChallengeRevisionTemplates,
+		m.migrateChallengeCounters,
 	}
 }
 
@@ -286,16 +667,105 @@ func (m *MariaDB) createTableChallenge() error {
 		description VARCHAR(255) NOT NULL,
 		content LONGTEXT NOT NULL,
 
-		tests JSON NULL DEFAULT NULL,
-		tests_hidden JSON NULL DEFAULT NULL,
+		tests JSON NOT NULL DEFAULT '[]',
+		tests_hidden JSON NOT NULL DEFAULT '[]',
+		revision INT NOT NULL DEFAULT 1,
+
+		difficulty VARCHAR(10) NOT NULL DEFAULT 'medium',
+		computed_difficulty VARCHAR(10) NULL DEFAULT NULL,
+
+		status VARCHAR(10) NOT NULL DEFAULT 'draft',
+		status_updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		play_count INT NOT NULL DEFAULT 0, -- ended lobbies
+		rating DOUBLE NOT NULL DEFAULT 0, -- average of challenge_rating, 0 until rated
+		rating_count INT NOT NULL DEFAULT 0,
 
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		
 		PRIMARY KEY (id),
 		FOREIGN KEY (owner_id) REFERENCES user(id),
-		UNIQUE INDEX (id)
+		UNIQUE INDEX (id),
+		INDEX challenge_by_play_count (play_count, id),
+		INDEX challenge_by_rating (rating, id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// migrateChallengeTestsNotNull fills the tests of the challenges created through the API before
+// they were written, and forbids NULL tests from now on
+func (m *MariaDB) migrateChallengeTestsNotNull() error {
+	queries := []string{
+		"UPDATE `challenge` SET tests = '[]' WHERE tests IS NULL;",
+		"UPDATE `challenge` SET tests_hidden = '[]' WHERE tests_hidden IS NULL;",
+		"ALTER TABLE `challenge` MODIFY tests JSON NOT NULL DEFAULT '[]', MODIFY tests_hidden JSON NOT NULL DEFAULT '[]';",
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateChallengeDifficulty adds the difficulties to the databases created before them,
+// the computed ones are backfilled only then, afterwards the ended matches keep them up to date
+func (m *MariaDB) migrateChallengeDifficulty() error {
+	exists, err := m.hasColumn("challenge", "computed_difficulty")
+	if err != nil || exists {
+		return err
+	}
+
+	query := "ALTER TABLE `challenge` ADD COLUMN IF NOT EXISTS difficulty VARCHAR(10) NOT NULL DEFAULT 'medium' AFTER revision, ADD COLUMN IF NOT EXISTS computed_difficulty VARCHAR(10) NULL DEFAULT NULL AFTER difficulty;"
+	if _, err := m.db.Exec(query); err != nil {
+		return err
+	}
+	return m.RefreshComputedDifficulty(0)
+}
+
+func (m *MariaDB) createTableChallengeRating() error {
+	query := `CREATE TABLE IF NOT EXISTS challenge_rating (
+		challenge_id INT NOT NULL,
+		user_id INT NOT NULL,
+		rating TINYINT NOT NULL,
+
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (challenge_id, user_id),
+		FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+		INDEX (user_id)
 	);`
 	_, err := m.db.Exec(query)
 	return err
 }
+
+// migrateChallengeCounters adds the play and rating counters to the databases created before them and backfills them once
+func (m *MariaDB) migrateChallengeCounters() error {
+	exists, err := m.hasColumn("challenge", "play_count")
+	if err != nil || exists {
+		return err
+	}
+
+	queries := []string{
+		"ALTER TABLE `challenge`" + `
+		ADD COLUMN IF NOT EXISTS play_count INT NOT NULL DEFAULT 0 AFTER status_updated_at,
+		ADD COLUMN IF NOT EXISTS rating DOUBLE NOT NULL DEFAULT 0 AFTER play_count,
+		ADD COLUMN IF NOT EXISTS rating_count INT NOT NULL DEFAULT 0 AFTER rating,
+		ADD INDEX IF NOT EXISTS challenge_by_play_count (play_count, id),
+		ADD INDEX IF NOT EXISTS challenge_by_rating (rating, id);`,
+		"UPDATE `challenge` c SET play_count = (SELECT COUNT(*) FROM lobby l WHERE l.challenge_id = c.id AND l.ended = TRUE);",
+		"UPDATE `challenge` c" + ` SET
+			rating = COALESCE((SELECT AVG(r.rating) FROM challenge_rating r WHERE r.challenge_id = c.id), 0),
+			rating_count = (SELECT COUNT(*) FROM challenge_rating r WHERE r.challenge_id = c.id);`,
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
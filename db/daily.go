@@ -0,0 +1,261 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// GetDailyChallenge returns the daily challenge of `date`, picking it the first time the day is requested
+func (m *MariaDB) GetDailyChallenge(date string) (*types.DailyChallenge, error) {
+	daily, err := m.getDailyChallenge(date)
+	if err == nil || err != sql.ErrNoRows {
+		return daily, err
+	}
+
+	challengeIds, err := m.getDailyCandidateIDs()
+	if err != nil {
+		return nil, err
+	}
+	index := utils.PickDailyIndex(date, len(challengeIds))
+	if index < 0 {
+		return nil, fmt.Errorf("DB(GetDailyChallenge): there are no challenges to pick from")
+	}
+
+	// concurrent requests pick the same challenge, the first insert wins
+	query := `INSERT IGNORE INTO daily_challenge (date, challenge_id) VALUES (?, ?);`
+	if _, err := m.db.Exec(query, date, challengeIds[index]); err != nil {
+		return nil, fmt.Errorf("DB(GetDailyChallenge): %s", err.Error())
+	}
+
+	return m.getDailyChallenge(date)
+}
+
+// SetDailyChallenge overrides the automatic selection for `date`
+func (m *MariaDB) SetDailyChallenge(date string, challengeId int) error {
+	query := `INSERT INTO daily_challenge (date, challenge_id, overridden) VALUES (?, ?, TRUE)
+	ON DUPLICATE KEY UPDATE challenge_id = VALUES(challenge_id), overridden = TRUE;`
+	if _, err := m.db.Exec(query, date, challengeId); err != nil {
+		return fmt.Errorf("DB(SetDailyChallenge): %s", err.Error())
+	}
+	return nil
+}
+
+func (m *MariaDB) GetDailySolversCount(date string) (int, error) {
+	var count int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM daily_solve WHERE date = ?;`, date).Scan(&count); err != nil {
+		return 0, fmt.Errorf("DB(GetDailySolversCount): %s", err.Error())
+	}
+	return count, nil
+}
+
+func (m *MariaDB) HasSolvedDaily(userId int, date string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM daily_solve WHERE user_id = ? AND date = ?);`
+	var solved bool
+	if err := m.db.QueryRow(query, userId, date).Scan(&solved); err != nil {
+		return false, fmt.Errorf("DB(HasSolvedDaily): %s", err.Error())
+	}
+	return solved, nil
+}
+
+// RecordDailySolve stores that the user solved the daily challenge of `date` in the lobby and
+// extends their streak. Solving the same day twice is a no-op.
+func (m *MariaDB) RecordDailySolve(userId int, date string, lobbyUniqueId string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `INSERT IGNORE INTO daily_solve (user_id, date, lobby_id)
+	SELECT ?, ?, id FROM lobby WHERE uuid = ?;`
+	res, err := tx.Exec(query, userId, date, lobbyUniqueId)
+	if err != nil {
+		return fmt.Errorf("DB(RecordDailySolve): %s", err.Error())
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil
+	}
+
+	var current, longest int
+	var lastDate sql.NullString
+	query = `SELECT current, longest, DATE_FORMAT(last_date, '%Y-%m-%d') FROM user_streak WHERE user_id = ? FOR UPDATE;`
+	err = tx.QueryRow(query, userId).Scan(&current, &longest, &lastDate)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("DB(RecordDailySolve): %s", err.Error())
+	}
+
+	current, longest, err = utils.ExtendStreak(current, longest, lastDate.String, date)
+	if err != nil {
+		return fmt.Errorf("DB(RecordDailySolve): %s", err.Error())
+	}
+	if lastDate.String > date {
+		date = lastDate.String
+	}
+
+	query = `INSERT INTO user_streak (user_id, current, longest, last_date) VALUES (?, ?, ?, ?)
+	ON DUPLICATE KEY UPDATE current = VALUES(current), longest = VALUES(longest), last_date = VALUES(last_date);`
+	if _, err := tx.Exec(query, userId, current, longest, date); err != nil {
+		return fmt.Errorf("DB(RecordDailySolve): %s", err.Error())
+	}
+
+	return tx.Commit()
+}
+
+// GetUserStreak returns the streak of the user as of `today`, a streak whose last solve
+// is older than yesterday is broken
+func (m *MariaDB) GetUserStreak(userId int, today string) (*types.UserStreak, error) {
+	query := `SELECT current, longest, DATE_FORMAT(last_date, '%Y-%m-%d') FROM user_streak WHERE user_id = ?;`
+	streak := &types.UserStreak{}
+	err := m.db.QueryRow(query, userId).Scan(&streak.Current, &streak.Longest, &streak.LastSolvedDate)
+	if err == sql.ErrNoRows {
+		return streak, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserStreak): %s", err.Error())
+	}
+
+	lastDate := ""
+	if streak.LastSolvedDate != nil {
+		lastDate = *streak.LastSolvedDate
+	}
+	if streak.Current, err = utils.CurrentStreak(streak.Current, lastDate, today); err != nil {
+		return nil, fmt.Errorf("DB(GetUserStreak): %s", err.Error())
+	}
+	return streak, nil
+}
+
+// GetLobbySolverIDs returns the players of the ended lobby that passed every test
+func (m *MariaDB) GetLobbySolverIDs(lobbyUniqueId string) ([]int, error) {
+	query := `SELECT lu.user_id FROM lobby_user lu
+	JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+	` + lobbyChallengeJoin + `
+	WHERE l.uuid = ? AND ` + lobbySolvedCondition + `;`
+
+	return m.queryIDs("GetLobbySolverIDs", query, lobbyUniqueId)
+}
+
+func (m *MariaDB) getDailyChallenge(date string) (*types.DailyChallenge, error) {
+	query := `SELECT DATE_FORMAT(date, '%Y-%m-%d'), challenge_id, overridden FROM daily_challenge WHERE date = ?;`
+	daily := &types.DailyChallenge{}
+	err := m.db.QueryRow(query, date).Scan(&daily.Date, &daily.ChallengeId, &daily.Overridden)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB(getDailyChallenge): %s", err.Error())
+	}
+	return daily, nil
+}
+
+// getDailyCandidateIDs returns the challenges the daily challenge is picked from, in a stable order
+func (m *MariaDB) getDailyCandidateIDs() ([]int, error) {
+	return m.queryIDs("getDailyCandidateIDs", "SELECT id FROM `challenge` WHERE status = ? ORDER BY id;", types.ChallengeStatusPublished)
+}
+
+func (m *MariaDB) queryIDs(funcName string, query string, args ...any) ([]int, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(%s): %s", utils.GetLogTag("DB"), funcName, err)
+		}
+	}()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
+
+	return ids, nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitDailyTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableDailyChallenge,
+		m.migrateDailyChallengeCascade,
+		m.createTableDailySolve,
+		m.createTableUserStreak,
+	}
+}
+
+func (m *MariaDB) createTableDailyChallenge() error {
+	query := `CREATE TABLE IF NOT EXISTS daily_challenge (
+		date DATE NOT NULL,
+		challenge_id INT NOT NULL,
+		overridden BOOLEAN NOT NULL DEFAULT FALSE,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (date),
+		CONSTRAINT daily_challenge_challenge_fk FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// migrateDailyChallengeCascade replaces the foreign key of the tables created before it cascaded,
+// which kept the challenges ever picked as the daily one from being deleted
+func (m *MariaDB) migrateDailyChallengeCascade() error {
+	queries := []string{
+		`ALTER TABLE daily_challenge DROP FOREIGN KEY IF EXISTS daily_challenge_ibfk_1;`,
+		`ALTER TABLE daily_challenge ADD CONSTRAINT daily_challenge_challenge_fk FOREIGN KEY IF NOT EXISTS (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE;`,
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MariaDB) createTableDailySolve() error {
+	query := `CREATE TABLE IF NOT EXISTS daily_solve (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		date DATE NOT NULL,
+		lobby_id INT NOT NULL,
+
+		solved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		FOREIGN KEY (lobby_id) REFERENCES lobby(id),
+		UNIQUE INDEX (user_id, date),
+		INDEX (date)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableUserStreak() error {
+	query := `CREATE TABLE IF NOT EXISTS user_streak (
+		user_id INT NOT NULL,
+		current INT NOT NULL DEFAULT 0,
+		longest INT NOT NULL DEFAULT 0,
+		last_date DATE,
+
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (user_id),
+		FOREIGN KEY (user_id) REFERENCES user(id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -3,9 +3,11 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,32 +16,131 @@ import (
 )
 
 type DB interface {
-	GetUsers() ([]*types.UserResponse, error)
+	GetUsers(*types.UserListQuery) (*types.UserListResponse, error)
+	GetUsersByIDs([]int) ([]*types.UserResponse, error)
 	GetUserByID(int) (*types.User, error)
 	GetUserByUsername(string) (*types.User, error)
 	GetUserStats(int) ([]*types.UserStatsParsed, error)
 	CreateUser(*types.User) error
 	UpdateUser(*types.User) error
 	DeleteUser(int) error
-	DeleteUserByUsername(string) error
 
-	GetChallenges() (*[]types.Challenge, error)
+	CreateEmailVerification(int, string, string, time.Time) error
+	CreateEmailChange(int, string, string, string, string, time.Time) error
+	ConfirmEmailToken(string) (*types.ConfirmEmailResponse, error)
+
+	FollowUser(int, int) error
+	UnfollowUser(int, int) error
+	IsFollowing(int, int) (bool, error)
+	GetFollowCounts(int) (*types.FollowCounts, error)
+	GetFollowers(int, string, int) (*types.UserListResponse, error)
+	GetFollowing(int, string, int) (*types.UserListResponse, error)
+	GetFriendIDs(int) ([]int, error)
+	AreFriends(int, int) (bool, error)
+	GetFriendsPlaying(int) ([]*types.FriendPlaying, error)
+
+	BlockUser(int, int) error
+	UnblockUser(int, int) error
+	GetBlockedUsers(int) ([]*types.UserResponse, error)
+	GetBlockedUserIDs(int) ([]int, error)
+	HasBlockBetween([]int) (bool, error)
+
+	CreateUserExport(*types.UserExport, time.Time) error
+	GetUserExport(int) (*types.UserExport, error)
+	GetUserExportArchive(string) ([]byte, error)
+	CompleteUserExport(int, []byte) error
+	FailUserExport(int, string) error
+	GetUserExportData(int) (*types.UserExportData, error)
+
+	GetChallenges(*types.ChallengeFilter) (*[]types.Challenge, error)
+	GetChallengeSummaries(*types.ChallengeListQuery) (*types.ChallengeListResponse, error)
 	GetChallengeByID(int) (*types.Challenge, error)
 	GetChallengeByIDFull(int) (*types.ChallengeFull, error)
-	GetRandomChallengeFull() (*types.ChallengeFull, error)
+	GetRandomChallengeFull(*types.ChallengeFilter) (*types.ChallengeFull, error)
 	CreateChallenge(*types.Challenge) error
-	UpdateChallenge(*types.Challenge) error
+	UpdateChallenge(*types.Challenge, int) error
 	DeleteChallenge(int) error
 	GetChallengesByOwnerID(int) (*[]types.Challenge, error)
+	GetChallengeOwnerID(int) (int, error)
+	GetChallengeTestCases(int) (*types.ChallengeTestCases, error)
+	SetChallengeTestCases(int, int, string, []types.TestCase) error
+	GetChallengeRevisions(int) ([]*types.ChallengeRevisionSummary, error)
+	GetChallengeRevision(int, int) (*types.ChallengeRevision, error)
+	RollbackChallenge(int, int, int) (*types.ChallengeRevision, error)
+	RateChallenge(int, int, int) (bool, error)
+	GetChallengeStatus(int) (string, error)
+	SetChallengeStatus(int, int, string, string, string) (*types.ChallengeReview, error)
+	GetChallengeReviews(int) ([]*types.ChallengeReview, error)
+	GetChallengeReviewQueue(string, int) (*types.ChallengeListResponse, error)
+	GetChallengeRating(int, int) (*types.ChallengeRating, error)
+	RefreshComputedDifficulty(int) error
+	ChallengeSearcher
+
+	GetTags() ([]*types.Tag, error)
+	CreateTag(*types.Tag) error
+	UpdateTag(string, *types.Tag) error
+	DeleteTag(string) error
+	SetChallengeTags(int, []string) error
+
+	GetLanguages() ([]*types.Language, error)
+	GetChallengeTemplates(int) ([]*types.ChallengeTemplate, error)
+	GetChallengeTemplate(int, string) (*types.ChallengeTemplate, error)
+	SetChallengeTemplate(int, int, *types.ChallengeTemplate) error
+	DeleteChallengeTemplate(int, int, string) error
 
 	CreateLobby(*types.Lobby) error
 	CreateLobbyUser(int, int) error
 	UpdateLobbyUserSubmission(*types.LobbyUser) error
 	GetLobbyByUniqueId(string) (*types.Lobby, error)
-	GetLobbyResults(string) (*types.LobbyResults, error)
-	EndLobby(string) error
+	GetLobbyResults(string, int) (*types.LobbyResults, error)
+	EndLobby(string) (bool, error)
 	UpdateShareLobbyCode(int, int, bool) error
-	GetMatchByUsername(string) ([]*types.SingleMatchResult, error)
+	GetMatchByUsername(string, int) ([]*types.SingleMatchResult, error)
+	RebuildUserStats() error
+
+	GetDailyChallenge(string) (*types.DailyChallenge, error)
+	SetDailyChallenge(string, int) error
+	GetDailySolversCount(string) (int, error)
+	HasSolvedDaily(int, string) (bool, error)
+	RecordDailySolve(int, string, string) error
+	GetUserStreak(int, string) (*types.UserStreak, error)
+	GetLobbySolverIDs(string) ([]int, error)
+
+	GetUserActivity(int, string) ([]*types.ActivityDay, error)
+	GetUserLanguageStats(int) ([]*types.LanguageStats, error)
+
+	GetVersusMatches(int, int) ([]*types.VersusMatch, error)
+	GetUserRivals(int, int) ([]*types.Rival, error)
+
+	GetUserRatings(int) ([]*types.UserRating, error)
+	GetUserRatingHistory(int, string) ([]*types.RatingHistoryEntry, error)
+
+	GetAchievements() ([]*types.Achievement, error)
+	GetLockedAchievements(int) ([]*types.Achievement, error)
+	GetAchievementProgress(int, *types.AchievementRule) (int, error)
+	CreateAchievement(*types.Achievement) error
+	UpdateAchievement(*types.Achievement) error
+	DeleteAchievement(int) error
+	UnlockAchievement(int, int) (bool, error)
+	GetUserAchievements(int) ([]*types.UserAchievement, error)
+
+	GetUserPreferences(int) (*types.UserPreferences, error)
+	UpdateUserPreferences(int, *types.UserPreferences) error
+	GetUserPrivacy(int) (*types.PrivacySettings, error)
+	CanView(int, int, string) (bool, error)
+
+	CreateNotifications([]int, string, map[string]any) error
+	GetNotifications(int, bool, string, int) (*types.NotificationListResponse, error)
+	GetUnreadNotificationCount(int) (int, error)
+	MarkNotificationRead(int, int) error
+	MarkAllNotificationsRead(int) error
+	GetNotificationPreferences(int) (types.NotificationPreferences, error)
+	UpdateNotificationPreferences(int, types.NotificationPreferences) error
+
+	RefreshLeaderboards(time.Time) error
+	GetLeaderboard(*types.LeaderboardQuery) (*types.LeaderboardResponse, error)
+	GetSeasons() ([]*types.Season, error)
+	CreateSeason(*types.Season) error
 
 	GetAuthByProviderAndID(string, string) (*types.AuthEntry, error)
 	CreateAuth(*types.AuthEntry) error
@@ -155,6 +256,16 @@ func (m *MariaDB) MigrationBulk(migrationFuncs ...[]MigrationFunc) error {
 	return nil
 }
 
+// hasColumn tells if the column is already in the table, for the migrations that backfill it once when they add it
+func (m *MariaDB) hasColumn(table, column string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?);`
+	var exists bool
+	if err := m.db.QueryRow(query, table, column).Scan(&exists); err != nil {
+		return false, fmt.Errorf("DB(hasColumn): %s", err.Error())
+	}
+	return exists, nil
+}
+
 // func Query(ctx context.Context, id int64) {
 // 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 // 	defer cancel()
@@ -167,6 +278,11 @@ func (m *MariaDB) MigrationBulk(migrationFuncs ...[]MigrationFunc) error {
 // 	log.Println("name=", name)
 // }
 
+// escapeLike escapes the LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (m *MariaDB) Close() error {
 	return m.db.Close()
 }
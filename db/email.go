@@ -0,0 +1,177 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// CreateEmailVerification stores the token sent to the current address of the user
+func (m *MariaDB) CreateEmailVerification(userId int, email, token string, expiresAt time.Time) error {
+	query := `INSERT INTO email_token (token, user_id, email, purpose, expires_at) VALUES (?, ?, ?, ?, ?);`
+	if _, err := m.db.Exec(query, token, userId, email, types.EmailTokenVerify, expiresAt); err != nil {
+		return fmt.Errorf("DB(CreateEmailVerification): %s", err.Error())
+	}
+	return nil
+}
+
+// CreateEmailChange stores a change of address and the tokens sent to both addresses,
+// `oldToken` is empty when the user has no address yet. Previous pending changes are cancelled.
+func (m *MariaDB) CreateEmailChange(userId int, oldEmail, newEmail, oldToken, newToken string, expiresAt time.Time) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE email_change SET expires_at = NOW() WHERE user_id = ? AND completed_at IS NULL AND expires_at > NOW();`
+	if _, err := tx.Exec(query, userId); err != nil {
+		return fmt.Errorf("DB(CreateEmailChange): %s", err.Error())
+	}
+
+	query = `INSERT INTO email_change (user_id, old_email, new_email, expires_at) VALUES (?, ?, ?, ?);`
+	res, err := tx.Exec(query, userId, oldEmail, newEmail, expiresAt)
+	if err != nil {
+		return fmt.Errorf("DB(CreateEmailChange): %s", err.Error())
+	}
+	changeId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	tokens := []struct{ token, email, purpose string }{
+		{oldToken, oldEmail, types.EmailTokenChangeOld},
+		{newToken, newEmail, types.EmailTokenChangeNew},
+	}
+	for _, token := range tokens {
+		if token.token == "" {
+			continue
+		}
+		query := `INSERT INTO email_token (token, user_id, email, purpose, change_id, expires_at) VALUES (?, ?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, token.token, userId, token.email, token.purpose, changeId, expiresAt); err != nil {
+			return fmt.Errorf("DB(CreateEmailChange): %s", err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ConfirmEmailToken consumes the token: a verification marks the address as verified,
+// a change is applied once both addresses confirmed it
+func (m *MariaDB) ConfirmEmailToken(token string) (*types.ConfirmEmailResponse, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var id, userId int
+	var email, purpose string
+	var changeId sql.NullInt64
+	query := `SELECT id, user_id, email, purpose, change_id FROM email_token
+	WHERE token = ? AND confirmed_at IS NULL AND expires_at > NOW()
+	FOR UPDATE;`
+	if err := tx.QueryRow(query, token).Scan(&id, &userId, &email, &purpose, &changeId); err != nil {
+		return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+	}
+
+	if _, err := tx.Exec(`UPDATE email_token SET confirmed_at = NOW() WHERE id = ?;`, id); err != nil {
+		return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+	}
+
+	response := &types.ConfirmEmailResponse{Email: email}
+	if purpose == types.EmailTokenVerify {
+		// the address could have changed since the token was sent
+		query := `UPDATE user SET email_verified = TRUE WHERE id = ? AND email = ?;`
+		res, err := tx.Exec(query, userId, email)
+		if err != nil {
+			return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return nil, fmt.Errorf("DB(ConfirmEmailToken): %s is not the address of the user anymore", email)
+		}
+		response.Status = types.EmailStatusVerified
+		return response, tx.Commit()
+	}
+
+	var newEmail string
+	var pending int
+	query = `SELECT c.new_email, (
+		SELECT COUNT(*) FROM email_token t WHERE t.change_id = c.id AND t.confirmed_at IS NULL
+	) FROM email_change c
+	WHERE c.id = ? AND c.completed_at IS NULL AND c.expires_at > NOW()
+	FOR UPDATE;`
+	if err := tx.QueryRow(query, changeId.Int64).Scan(&newEmail, &pending); err != nil {
+		return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+	}
+
+	if pending > 0 {
+		response.Status = types.EmailStatusPending
+		return response, tx.Commit()
+	}
+
+	if _, err := tx.Exec(`UPDATE user SET email = ?, email_verified = TRUE WHERE id = ?;`, newEmail, userId); err != nil {
+		return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+	}
+	if _, err := tx.Exec(`UPDATE email_change SET completed_at = NOW() WHERE id = ?;`, changeId.Int64); err != nil {
+		return nil, fmt.Errorf("DB(ConfirmEmailToken): %s", err.Error())
+	}
+
+	response.Status = types.EmailStatusChanged
+	response.Email = newEmail
+	return response, tx.Commit()
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitEmailTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableEmailChange,
+		m.createTableEmailToken,
+	}
+}
+
+func (m *MariaDB) createTableEmailChange() error {
+	query := `CREATE TABLE IF NOT EXISTS email_change (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		old_email VARCHAR(255) NOT NULL,
+		new_email VARCHAR(255) NOT NULL,
+		expires_at DATETIME NOT NULL,
+		completed_at DATETIME,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableEmailToken() error {
+	query := `CREATE TABLE IF NOT EXISTS email_token (
+		id INT AUTO_INCREMENT,
+		token VARCHAR(64) NOT NULL,
+		user_id INT NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		purpose VARCHAR(20) NOT NULL,
+		change_id INT,
+		expires_at DATETIME NOT NULL,
+		confirmed_at DATETIME,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		FOREIGN KEY (change_id) REFERENCES email_change(id),
+		UNIQUE INDEX (token)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+func (m *MariaDB) CreateUserExport(export *types.UserExport, expiresAt time.Time) error {
+	// expired archives are not downloadable anymore, no need to keep them around
+	if _, err := m.db.Exec(`DELETE FROM user_export WHERE expires_at < NOW();`); err != nil {
+		return fmt.Errorf("DB(CreateUserExport): %s", err.Error())
+	}
+
+	query := `INSERT INTO user_export (user_id, token, status, expires_at) VALUES (?, ?, ?, ?);`
+	res, err := m.db.Exec(query, export.UserId, export.Token, types.UserExportPending, expiresAt)
+	if err != nil {
+		return fmt.Errorf("DB(CreateUserExport): %s", err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	created, err := m.GetUserExport(int(id))
+	if err != nil {
+		return err
+	}
+	*export = *created
+	return nil
+}
+
+func (m *MariaDB) GetUserExport(id int) (*types.UserExport, error) {
+	query := `SELECT id, user_id, token, status, COALESCE(error, ''), expires_at, created_at, updated_at
+		FROM user_export WHERE id = ?;`
+
+	export := &types.UserExport{}
+	if err := m.db.QueryRow(query, id).Scan(
+		&export.Id,
+		&export.UserId,
+		&export.Token,
+		&export.Status,
+		&export.Error,
+		&export.ExpiresAt,
+		&export.CreatedAt,
+		&export.UpdatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("DB(GetUserExport): %s", err.Error())
+	}
+
+	return export, nil
+}
+
+// GetUserExportArchive returns the archive of a ready, not expired, export
+func (m *MariaDB) GetUserExportArchive(token string) ([]byte, error) {
+	query := `SELECT archive FROM user_export WHERE token = ? AND status = ? AND expires_at >= NOW();`
+
+	var archive []byte
+	if err := m.db.QueryRow(query, token, types.UserExportReady).Scan(&archive); err != nil {
+		return nil, fmt.Errorf("DB(GetUserExportArchive): %s", err.Error())
+	}
+
+	return archive, nil
+}
+
+func (m *MariaDB) CompleteUserExport(id int, archive []byte) error {
+	query := `UPDATE user_export SET status = ?, archive = ? WHERE id = ?;`
+	_, err := m.db.Exec(query, types.UserExportReady, archive, id)
+	return err
+}
+
+func (m *MariaDB) FailUserExport(id int, reason string) error {
+	query := `UPDATE user_export SET status = ?, error = ? WHERE id = ?;`
+	_, err := m.db.Exec(query, types.UserExportFailed, reason, id)
+	return err
+}
+
+// GetUserExportData collects everything we hold about the user
+func (m *MariaDB) GetUserExportData(userId int) (*types.UserExportData, error) {
+	user, err := m.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &types.UserExportData{User: user}
+
+	if data.Auth, err = m.getAuthByUserID(userId); err != nil {
+		return nil, err
+	}
+	if data.Stats, err = m.GetUserStats(userId); err != nil {
+		return nil, err
+	}
+	if data.Challenges, err = m.getExportChallenges(userId); err != nil {
+		return nil, err
+	}
+	if data.Submissions, err = m.getExportSubmissions(userId); err != nil {
+		return nil, err
+	}
+	if data.Sessions, err = m.getSessions(userId); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (m *MariaDB) getAuthByUserID(userId int) ([]*types.AuthEntry, error) {
+	query := `SELECT id, user_id, provider, provider_id, created_at, updated_at FROM auth WHERE user_id = ?;`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getAuthByUserID): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(getAuthByUserID): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	entries := []*types.AuthEntry{}
+	for rows.Next() {
+		auth := &types.AuthEntry{}
+		if err := rows.Scan(&auth.Id, &auth.UserId, &auth.Provider, &auth.ProviderId, &auth.CreatedAt, &auth.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("DB(getAuthByUserID): %s", err.Error())
+		}
+		entries = append(entries, auth)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(getAuthByUserID): %s", err.Error())
+	}
+
+	return entries, nil
+}
+
+func (m *MariaDB) getExportChallenges(userId int) ([]*types.UserExportChallenge, error) {
+	query := "SELECT id, title, description, content, COALESCE(tests, '[]'), COALESCE(tests_hidden, '[]'), created_at, updated_at FROM `challenge` WHERE owner_id = ?;"
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getExportChallenges): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(getExportChallenges): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	challenges := []*types.UserExportChallenge{}
+	for rows.Next() {
+		challenge := &types.UserExportChallenge{}
+		var tests, hiddenTests string
+		if err := rows.Scan(
+			&challenge.Id,
+			&challenge.Title,
+			&challenge.Description,
+			&challenge.Content,
+			&tests,
+			&hiddenTests,
+			&challenge.CreatedAt,
+			&challenge.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(getExportChallenges): %s", err.Error())
+		}
+		challenge.TestCases = []byte(tests)
+		challenge.HiddenTests = []byte(hiddenTests)
+		challenges = append(challenges, challenge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(getExportChallenges): %s", err.Error())
+	}
+
+	return challenges, nil
+}
+
+func (m *MariaDB) getExportSubmissions(userId int) ([]*types.UserExportSubmission, error) {
+	query := `SELECT l.uuid, l.challenge_id, l.mode,
+		u.code, u.language, u.tests_passed, u.match_rank, u.show_code, u.submitted_at, u.created_at
+	FROM lobby_user u
+	JOIN lobby l ON l.id = u.lobby_id
+	WHERE u.user_id = ?
+	ORDER BY u.created_at;`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getExportSubmissions): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(getExportSubmissions): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	submissions := []*types.UserExportSubmission{}
+	for rows.Next() {
+		submission := &types.UserExportSubmission{}
+		var rank sql.NullInt32
+		if err := rows.Scan(
+			&submission.LobbyUniqueId,
+			&submission.ChallengeId,
+			&submission.Mode,
+			&submission.Code,
+			&submission.Language,
+			&submission.TestsPassed,
+			&rank,
+			&submission.ShowCode,
+			&submission.SubmittedAt,
+			&submission.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(getExportSubmissions): %s", err.Error())
+		}
+		if rank.Valid {
+			r := int(rank.Int32)
+			submission.Rank = &r
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(getExportSubmissions): %s", err.Error())
+	}
+
+	return submissions, nil
+}
+
+func (m *MariaDB) getSessions(userId int) ([]*types.Session, error) {
+	query := `SELECT id, expires_at, created_at FROM refresh_token WHERE user_id = ? AND expires_at >= NOW();`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getSessions): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(getSessions): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	sessions := []*types.Session{}
+	for rows.Next() {
+		session := &types.Session{}
+		if err := rows.Scan(&session.Id, &session.ExpiresAt, &session.CreatedAt); err != nil {
+			return nil, fmt.Errorf("DB(getSessions): %s", err.Error())
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(getSessions): %s", err.Error())
+	}
+
+	return sessions, nil
+}
+
+func (m *MariaDB) createTableUserExport() error {
+	query := `CREATE TABLE IF NOT EXISTS user_export (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		token VARCHAR(64) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		error VARCHAR(255),
+		archive LONGBLOB,
+
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		UNIQUE INDEX (token)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// lobbies that never reported their end are not considered "playing now" after this window
+const friendsPlayingWindowMinutes = 120
+
+func (m *MariaDB) FollowUser(followerId, followeeId int) error {
+	if followerId == followeeId {
+		return fmt.Errorf("DB(FollowUser): a user cannot follow themselves")
+	}
+
+	query := `INSERT IGNORE INTO user_follow (follower_id, followee_id) VALUES (?, ?);`
+	_, err := m.db.Exec(query, followerId, followeeId)
+	return err
+}
+
+func (m *MariaDB) UnfollowUser(followerId, followeeId int) error {
+	query := `DELETE FROM user_follow WHERE follower_id = ? AND followee_id = ?;`
+	_, err := m.db.Exec(query, followerId, followeeId)
+	return err
+}
+
+func (m *MariaDB) IsFollowing(followerId, followeeId int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM user_follow WHERE follower_id = ? AND followee_id = ?);`
+	var following bool
+	if err := m.db.QueryRow(query, followerId, followeeId).Scan(&following); err != nil {
+		return false, fmt.Errorf("DB(IsFollowing): %s", err.Error())
+	}
+	return following, nil
+}
+
+func (m *MariaDB) GetFollowCounts(userId int) (*types.FollowCounts, error) {
+	query := `SELECT
+		(SELECT COUNT(*) FROM user_follow WHERE followee_id = ?),
+		(SELECT COUNT(*) FROM user_follow WHERE follower_id = ?);`
+
+	counts := &types.FollowCounts{}
+	if err := m.db.QueryRow(query, userId, userId).Scan(&counts.Followers, &counts.Following); err != nil {
+		return nil, fmt.Errorf("DB(GetFollowCounts): %s", err.Error())
+	}
+	return counts, nil
+}
+
+func (m *MariaDB) GetFollowers(userId int, cursor string, limit int) (*types.UserListResponse, error) {
+	return m.getFollowList(`JOIN user_follow f ON f.follower_id = u.id WHERE f.followee_id = ?`, userId, cursor, limit)
+}
+
+func (m *MariaDB) GetFollowing(userId int, cursor string, limit int) (*types.UserListResponse, error) {
+	return m.getFollowList(`JOIN user_follow f ON f.followee_id = u.id WHERE f.follower_id = ?`, userId, cursor, limit)
+}
+
+// GetFriendIDs returns the ids of the users that mutually follow `userId`
+func (m *MariaDB) GetFriendIDs(userId int) ([]int, error) {
+	query := `SELECT a.followee_id
+	FROM user_follow a
+	JOIN user_follow b ON b.follower_id = a.followee_id AND b.followee_id = a.follower_id
+	WHERE a.follower_id = ?;`
+
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetFriendIDs): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetFriendIDs): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("DB(GetFriendIDs): %s", err.Error())
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetFriendIDs): %s", err.Error())
+	}
+
+	return ids, nil
+}
+
+func (m *MariaDB) AreFriends(userId, otherId int) (bool, error) {
+	query := `SELECT COUNT(*) = 2 FROM user_follow
+	WHERE (follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?);`
+
+	var friends bool
+	if err := m.db.QueryRow(query, userId, otherId, otherId, userId).Scan(&friends); err != nil {
+		return false, fmt.Errorf("DB(AreFriends): %s", err.Error())
+	}
+	return friends, nil
+}
+
+// GetFriendsPlaying returns the friends of `userId` that are in a lobby that has not ended yet
+func (m *MariaDB) GetFriendsPlaying(userId int) ([]*types.FriendPlaying, error) {
+	query := `SELECT
+		u.id, u.name, u.username, COALESCE(u.avatar, ''), u.background_img, u.bio, u.role, u.created_at,
+		l.uuid, l.mode, l.created_at
+	FROM user_follow a
+	JOIN user_follow b ON b.follower_id = a.followee_id AND b.followee_id = a.follower_id
+	JOIN user u ON u.id = a.followee_id
+	JOIN lobby_user lu ON lu.user_id = u.id
+	JOIN lobby l ON l.id = lu.lobby_id AND l.ended = FALSE
+	WHERE a.follower_id = ? AND l.created_at >= NOW() - INTERVAL ? MINUTE
+	ORDER BY l.created_at DESC;`
+
+	rows, err := m.db.Query(query, userId, friendsPlayingWindowMinutes)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetFriendsPlaying): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetFriendsPlaying): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	friends := []*types.FriendPlaying{}
+	for rows.Next() {
+		friend := &types.FriendPlaying{}
+		if err := rows.Scan(
+			&friend.User.Id,
+			&friend.User.Name,
+			&friend.User.Username,
+			&friend.User.Avatar,
+			&friend.User.BackgroundImg,
+			&friend.User.Bio,
+			&friend.User.Role,
+			&friend.User.CreatedAt,
+			&friend.LobbyUniqueId,
+			&friend.Mode,
+			&friend.StartedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(GetFriendsPlaying): %s", err.Error())
+		}
+		friends = append(friends, friend)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetFriendsPlaying): %s", err.Error())
+	}
+
+	return friends, nil
+}
+
+func (m *MariaDB) getFollowList(joinAndWhere string, userId int, cursor string, limit int) (*types.UserListResponse, error) {
+	decodedCursor, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{userId}
+	cursorCondition := ""
+	if decodedCursor != nil {
+		cursorCondition = " AND u.id < ?"
+		args = append(args, decodedCursor.Id)
+	}
+	args = append(args, limit+1)
+
+	query := `SELECT ` + userResponseColumns + ` FROM user u ` + joinAndWhere + cursorCondition + `
+	ORDER BY u.id DESC
+	LIMIT ?;`
+
+	users, err := m.queryUserResponses(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getFollowList): %s", err.Error())
+	}
+
+	response := &types.UserListResponse{Users: users}
+	if len(users) > limit {
+		response.Users = users[:limit]
+		last := response.Users[len(response.Users)-1]
+		response.NextCursor = utils.EncodeCursor(&utils.Cursor{Value: float64(last.Id), Id: last.Id})
+	}
+
+	return response, nil
+}
+
+func (m *MariaDB) createTableUserFollow() error {
+	query := `CREATE TABLE IF NOT EXISTS user_follow (
+		id INT AUTO_INCREMENT,
+		follower_id INT NOT NULL,
+		followee_id INT NOT NULL,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (follower_id) REFERENCES user(id),
+		FOREIGN KEY (followee_id) REFERENCES user(id),
+		UNIQUE INDEX (follower_id, followee_id),
+		INDEX (followee_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
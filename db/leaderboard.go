@@ -0,0 +1,351 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// leaderboardPeriod is a time window of the leaderboards, from/to are nil for all-time
+type leaderboardPeriod struct {
+	key  string
+	from *time.Time
+	to   *time.Time
+}
+
+// leaderboardGroupings are the (mode, language) boards computed for each metric, ” stands for "all"
+var leaderboardGroupings = [][2]string{
+	{"''", "''"},
+	{"l.mode", "''"},
+	{"''", "lu.language"},
+	{"l.mode", "lu.language"},
+}
+
+var leaderboardCountConditions = map[string]string{
+	types.LeaderboardMetricWins:   lobbyWinCondition,
+	types.LeaderboardMetricSolves: lobbySolvedCondition,
+}
+
+func monthPeriod(now time.Time) leaderboardPeriod {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	return leaderboardPeriod{key: from.Format("2006-01"), from: &from, to: &to}
+}
+
+func weekPeriod(now time.Time) leaderboardPeriod {
+	year, week := now.ISOWeek()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// ISO weeks start on monday
+	from := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	to := from.AddDate(0, 0, 7)
+	return leaderboardPeriod{key: fmt.Sprintf("%d-W%02d", year, week), from: &from, to: &to}
+}
+
+func seasonPeriodKey(seasonId int) string {
+	return fmt.Sprintf("season-%d", seasonId)
+}
+
+// leaderboardPeriodKey returns the key of the board of `window` containing `now`
+func leaderboardPeriodKey(window string, seasonId int, now time.Time) (string, error) {
+	switch window {
+	case "", types.LeaderboardWindowAll:
+		return types.LeaderboardWindowAll, nil
+	case types.LeaderboardWindowMonthly:
+		return monthPeriod(now).key, nil
+	case types.LeaderboardWindowWeekly:
+		return weekPeriod(now).key, nil
+	case types.LeaderboardWindowSeason:
+		return seasonPeriodKey(seasonId), nil
+	}
+	return "", fmt.Errorf("unsupported window %q", window)
+}
+
+// RefreshLeaderboards recomputes the leaderboards that can still change at `now`:
+// the rating ones, the all-time, current month and week ones, and the ones of the seasons
+// in progress (or just ended, so that their final standings are computed).
+func (m *MariaDB) RefreshLeaderboards(now time.Time) error {
+	now = now.UTC()
+	periods := []leaderboardPeriod{{key: types.LeaderboardWindowAll}, monthPeriod(now), weekPeriod(now)}
+
+	seasons, err := m.GetSeasons()
+	if err != nil {
+		return err
+	}
+	for _, season := range seasons {
+		from, errFrom := time.Parse(time.DateTime, season.StartsAt)
+		to, errTo := time.Parse(time.DateTime, season.EndsAt)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		if from.After(now) || to.Before(now.AddDate(0, 0, -1)) {
+			continue
+		}
+		periods = append(periods, leaderboardPeriod{key: seasonPeriodKey(season.Id), from: &from, to: &to})
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM leaderboard_entry WHERE metric = ?;`, types.LeaderboardMetricRating); err != nil {
+		return fmt.Errorf("DB(RefreshLeaderboards): %s", err.Error())
+	}
+	query := `INSERT INTO leaderboard_entry (metric, mode, language, period, user_id, score, position)
+	SELECT ?, mo.name, '', ?, r.user_id, r.rating, RANK() OVER (PARTITION BY r.mode_id ORDER BY r.rating DESC)
+	FROM user_rating r
+	JOIN mode mo ON mo.id = r.mode_id
+	JOIN user u ON u.id = r.user_id AND u.role != ?;`
+	if _, err := tx.Exec(query, types.LeaderboardMetricRating, types.LeaderboardWindowAll, types.UserRoleDeleted); err != nil {
+		return fmt.Errorf("DB(RefreshLeaderboards): %s", err.Error())
+	}
+
+	for _, period := range periods {
+		query := `DELETE FROM leaderboard_entry WHERE metric != ? AND period = ?;`
+		if _, err := tx.Exec(query, types.LeaderboardMetricRating, period.key); err != nil {
+			return fmt.Errorf("DB(RefreshLeaderboards): %s", err.Error())
+		}
+
+		for metric, condition := range leaderboardCountConditions {
+			for _, grouping := range leaderboardGroupings {
+				modeExpr, languageExpr := grouping[0], grouping[1]
+
+				conditions := []string{condition, "lu.language IS NOT NULL"}
+				args := []any{metric, period.key, types.UserRoleDeleted}
+				if period.from != nil {
+					conditions = append(conditions, "l.created_at >= ? AND l.created_at < ?")
+					args = append(args, *period.from, *period.to)
+				}
+
+				query := `INSERT INTO leaderboard_entry (metric, mode, language, period, user_id, score, position)
+				SELECT ?, ` + modeExpr + `, ` + languageExpr + `, ?, lu.user_id, COUNT(*),
+					RANK() OVER (PARTITION BY ` + modeExpr + `, ` + languageExpr + ` ORDER BY COUNT(*) DESC)
+				FROM lobby_user lu
+				JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+				` + lobbyChallengeJoin + `
+				JOIN user u ON u.id = lu.user_id AND u.role != ?
+				WHERE ` + strings.Join(conditions, " AND ") + `
+				GROUP BY ` + modeExpr + `, ` + languageExpr + `, lu.user_id;`
+
+				if _, err := tx.Exec(query, args...); err != nil {
+					return fmt.Errorf("DB(RefreshLeaderboards): %s", err.Error())
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *MariaDB) GetLeaderboard(leaderboardQuery *types.LeaderboardQuery) (*types.LeaderboardResponse, error) {
+	cursor, err := utils.DecodeCursor(leaderboardQuery.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := leaderboardPeriodKey(leaderboardQuery.Window, leaderboardQuery.SeasonId, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	// the positions are precomputed for the whole board, with the friends filter they are
+	// computed on the (small) subset of the viewer and their friends
+	boardCondition := "metric = ? AND mode = ? AND language = ? AND period = ?"
+	boardArgs := []any{leaderboardQuery.Metric, leaderboardQuery.Mode, leaderboardQuery.Language, period}
+	board := `SELECT user_id, score, position, computed_at FROM leaderboard_entry WHERE ` + boardCondition
+	if leaderboardQuery.Friends {
+		friendIds, err := m.GetFriendIDs(leaderboardQuery.ViewerId)
+		if err != nil {
+			return nil, err
+		}
+		userIds := append(friendIds, leaderboardQuery.ViewerId)
+
+		board = `SELECT user_id, score, RANK() OVER (ORDER BY score DESC) AS position, computed_at
+		FROM leaderboard_entry
+		WHERE ` + boardCondition + ` AND user_id IN (` + strings.TrimSuffix(strings.Repeat("?,", len(userIds)), ",") + `)`
+		for _, id := range userIds {
+			boardArgs = append(boardArgs, id)
+		}
+	}
+
+	pageCondition := "TRUE"
+	pageArgs := append([]any{}, boardArgs...)
+	if cursor != nil {
+		pageCondition = "(position > ? OR (position = ? AND user_id > ?))"
+		pageArgs = append(pageArgs, cursor.Value, cursor.Value, cursor.Id)
+	}
+	pageArgs = append(pageArgs, leaderboardQuery.Limit+1)
+
+	query := `SELECT user_id, score, position, computed_at FROM (` + board + `) board
+	WHERE ` + pageCondition + `
+	ORDER BY position, user_id
+	LIMIT ?;`
+	entries, updatedAt, err := m.queryLeaderboardEntries(query, pageArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetLeaderboard): %s", err.Error())
+	}
+
+	response := &types.LeaderboardResponse{Entries: entries, UpdatedAt: updatedAt}
+	if len(entries) > leaderboardQuery.Limit {
+		response.Entries = entries[:leaderboardQuery.Limit]
+		last := response.Entries[len(response.Entries)-1]
+		response.NextCursor = utils.EncodeCursor(&utils.Cursor{Value: float64(last.Position), Id: last.User.Id})
+	}
+
+	// the viewer position, even if it is not in the page
+	if leaderboardQuery.ViewerId != 0 {
+		query := `SELECT user_id, score, position, computed_at FROM (` + board + `) board WHERE user_id = ?;`
+		me, _, err := m.queryLeaderboardEntries(query, append(append([]any{}, boardArgs...), leaderboardQuery.ViewerId)...)
+		if err != nil {
+			return nil, fmt.Errorf("DB(GetLeaderboard): %s", err.Error())
+		}
+		if len(me) > 0 {
+			response.Me = me[0]
+		}
+	}
+
+	// fill in the users
+	entries = append([]*types.LeaderboardEntry{}, response.Entries...)
+	if response.Me != nil {
+		entries = append(entries, response.Me)
+	}
+	userIds := make([]int, len(entries))
+	for i, entry := range entries {
+		userIds[i] = entry.User.Id
+	}
+	users, err := m.GetUsersByIDs(userIds)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[int]*types.UserResponse, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+	for _, entry := range entries {
+		if user, ok := byId[entry.User.Id]; ok {
+			entry.User = user
+		}
+	}
+
+	return response, nil
+}
+
+func (m *MariaDB) queryLeaderboardEntries(query string, args ...any) ([]*types.LeaderboardEntry, string, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(queryLeaderboardEntries): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	entries := []*types.LeaderboardEntry{}
+	updatedAt := ""
+	for rows.Next() {
+		entry := &types.LeaderboardEntry{User: &types.UserResponse{}}
+		if err := rows.Scan(&entry.User.Id, &entry.Score, &entry.Position, &updatedAt); err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return entries, updatedAt, nil
+}
+
+func (m *MariaDB) GetSeasons() ([]*types.Season, error) {
+	query := `SELECT id, name, starts_at, ends_at FROM season ORDER BY starts_at DESC;`
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetSeasons): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetSeasons): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	seasons := []*types.Season{}
+	for rows.Next() {
+		season := &types.Season{}
+		if err := rows.Scan(&season.Id, &season.Name, &season.StartsAt, &season.EndsAt); err != nil {
+			return nil, fmt.Errorf("DB(GetSeasons): %s", err.Error())
+		}
+		seasons = append(seasons, season)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetSeasons): %s", err.Error())
+	}
+
+	return seasons, nil
+}
+
+func (m *MariaDB) CreateSeason(season *types.Season) error {
+	query := `INSERT INTO season (name, starts_at, ends_at) VALUES (?, ?, ?);`
+	res, err := m.db.Exec(query, season.Name, season.StartsAt, season.EndsAt)
+	if err != nil {
+		return fmt.Errorf("DB(CreateSeason): %s", err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	season.Id = int(id)
+	return nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitLeaderboardTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableSeason,
+		m.createTableLeaderboardEntry,
+	}
+}
+
+func (m *MariaDB) createTableSeason() error {
+	query := `CREATE TABLE IF NOT EXISTS season (
+		id INT AUTO_INCREMENT,
+		name VARCHAR(50) NOT NULL,
+		starts_at DATETIME NOT NULL,
+		ends_at DATETIME NOT NULL,
+
+		PRIMARY KEY (id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableLeaderboardEntry() error {
+	query := `CREATE TABLE IF NOT EXISTS leaderboard_entry (
+		id INT AUTO_INCREMENT,
+		metric VARCHAR(20) NOT NULL,
+		mode VARCHAR(50) NOT NULL,
+		language VARCHAR(50) NOT NULL,
+		period VARCHAR(20) NOT NULL,
+		user_id INT NOT NULL,
+
+		score DOUBLE NOT NULL,
+		position INT NOT NULL,
+
+		computed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		UNIQUE INDEX (metric, mode, language, period, user_id),
+		INDEX board (metric, mode, language, period, position)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
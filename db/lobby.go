@@ -1,6 +1,7 @@
 package db
 
 import (
+	"fmt"
 	"log"
 	"strings"
 
@@ -9,8 +10,19 @@ import (
 )
 
 func (m *MariaDB) CreateLobby(lobby *types.Lobby) error {
-	query := `INSERT INTO lobby (uuid, challenge_id, owner_id, mode, max_players, game_duration, allowed_languages)
-		VALUES (?, ?, ?, ?, ?, ?, ?);
+	// the lobby is pinned to a revision so later edits of the challenge don't change its results
+	revisionQuery := `SELECT id FROM challenge_revision WHERE challenge_id = ? ORDER BY revision DESC LIMIT 1;`
+	revisionArgs := []any{lobby.ChallengeId}
+	if lobby.ChallengeRevisionId != 0 {
+		revisionQuery = `SELECT id FROM challenge_revision WHERE challenge_id = ? AND id = ?;`
+		revisionArgs = append(revisionArgs, lobby.ChallengeRevisionId)
+	}
+	if err := m.db.QueryRow(revisionQuery, revisionArgs...).Scan(&lobby.ChallengeRevisionId); err != nil {
+		return fmt.Errorf("DB(CreateLobby): revision %d of challenge %d not found: %s", lobby.ChallengeRevisionId, lobby.ChallengeId, err.Error())
+	}
+
+	query := `INSERT INTO lobby (uuid, challenge_id, challenge_revision_id, owner_id, mode, max_players, game_duration, allowed_languages, ranked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 	;`
 
 	allowedLanguages := ""
@@ -25,11 +37,13 @@ func (m *MariaDB) CreateLobby(lobby *types.Lobby) error {
 		query,
 		lobby.UniqueId,
 		lobby.ChallengeId,
+		lobby.ChallengeRevisionId,
 		lobby.OwnerId,
 		lobby.Mode,
 		lobby.MaxPlayers,
 		lobby.GameDuration,
 		allowedLanguages,
+		lobby.Ranked,
 	)
 	if err != nil {
 		return err
@@ -52,8 +66,12 @@ func (m *MariaDB) CreateLobby(lobby *types.Lobby) error {
 }
 
 func (m *MariaDB) CreateLobbyUser(userId int, lobbyId int) error {
-	query := `INSERT INTO lobby_user (lobby_id, user_id) VALUES (?, ?);`
-	_, err := m.db.Exec(query, lobbyId, userId)
+	// the code is shared after the match if the user chose so in their preferences
+	query := `INSERT INTO lobby_user (lobby_id, user_id, show_code) VALUES (?, ?, COALESCE(
+		(SELECT JSON_VALUE(data, '$.share_code_by_default') = 'true' FROM user_preferences WHERE user_id = ?),
+		FALSE
+	));`
+	_, err := m.db.Exec(query, lobbyId, userId, userId)
 
 	return err
 }
@@ -79,7 +97,7 @@ func (m *MariaDB) UpdateLobbyUserSubmission(userSubmission *types.LobbyUser) err
 }
 
 func (m *MariaDB) GetLobbyByUniqueId(uniqueId string) (*types.Lobby, error) {
-	query := `SELECT id, uuid, challenge_id, owner_id, ended, max_players, game_duration, allowed_languages, created_at, updated_at
+	query := `SELECT id, uuid, challenge_id, COALESCE(challenge_revision_id, 0), owner_id, ended, max_players, game_duration, allowed_languages, created_at, updated_at
 		FROM lobby WHERE uuid = ?;`
 
 	row := m.db.QueryRow(query, uniqueId)
@@ -89,6 +107,7 @@ func (m *MariaDB) GetLobbyByUniqueId(uniqueId string) (*types.Lobby, error) {
 		&lobby.Id,
 		&lobby.UniqueId,
 		&lobby.ChallengeId,
+		&lobby.ChallengeRevisionId,
 		&lobby.OwnerId,
 		&lobby.Ended,
 		&lobby.MaxPlayers,
@@ -113,12 +132,14 @@ func (m *MariaDB) GetLobbyByUniqueId(uniqueId string) (*types.Lobby, error) {
 	return lobby, nil
 }
 
-func (m *MariaDB) GetLobbyResults(lobbyUniqueId string) (*types.LobbyResults, error) {
+// GetLobbyResults hides the code of the players that don't share it with the viewer (0 when anonymous)
+func (m *MariaDB) GetLobbyResults(lobbyUniqueId string, viewerId int) (*types.LobbyResults, error) {
 	query := `SELECT
-		l.id, l.uuid, l.challenge_id, l.owner_id, l.ended, l.mode, l.max_players, l.game_duration, l.allowed_languages, l.created_at, l.updated_at,
-		u.id, u.lobby_id, u.user_id, u.code, u.language, u.tests_passed, u.show_code, u.submitted_at, u.created_at, u.updated_at
+		l.id, l.uuid, l.challenge_id, COALESCE(l.challenge_revision_id, 0), l.owner_id, l.ended, l.ranked, l.mode, l.max_players, l.game_duration, l.allowed_languages, l.created_at, l.updated_at,
+		u.id, u.lobby_id, u.user_id, us.username, u.code, u.language, u.tests_passed, u.show_code, u.match_rank, u.submitted_at, u.created_at, u.updated_at
 		FROM lobby l
 		JOIN lobby_user u ON l.id = u.lobby_id
+		JOIN user us ON us.id = u.user_id
 		WHERE l.uuid = ?;`
 
 	rows, err := m.db.Query(query, lobbyUniqueId)
@@ -140,8 +161,10 @@ func (m *MariaDB) GetLobbyResults(lobbyUniqueId string) (*types.LobbyResults, er
 			&lobby.Id,
 			&lobby.UniqueId,
 			&lobby.ChallengeId,
+			&lobby.ChallengeRevisionId,
 			&lobby.OwnerId,
 			&lobby.Ended,
+			&lobby.Ranked,
 			&lobby.Mode,
 			&lobby.MaxPlayers,
 			&lobby.GameDuration,
@@ -152,10 +175,12 @@ func (m *MariaDB) GetLobbyResults(lobbyUniqueId string) (*types.LobbyResults, er
 			&user.Id,
 			&user.LobbyId,
 			&user.UserId,
+			&user.Username,
 			&user.Code,
 			&user.Language,
 			&user.TestsPassed,
 			&user.ShowCode,
+			&user.Rank,
 			&user.SubmittedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
@@ -176,17 +201,76 @@ func (m *MariaDB) GetLobbyResults(lobbyUniqueId string) (*types.LobbyResults, er
 		return nil, err
 	}
 
+	canViewCode := m.codeVisibility(viewerId)
+	for i := range results {
+		canView, err := canViewCode(results[i].UserId, results[i].ShowCode)
+		if err != nil {
+			return nil, err
+		}
+		if !canView {
+			results[i].Code = nil
+		}
+	}
+
 	return &types.LobbyResults{
 		Lobby:   *lobby,
 		Results: results,
 	}, nil
 }
 
-func (m *MariaDB) EndLobby(lobbyUniqueId string) error {
-	query := `UPDATE lobby SET ended = TRUE WHERE uuid = ?;`
-	_, err := m.db.Exec(query, lobbyUniqueId)
+// EndLobby marks the lobby as ended, ranks its players and updates their stats.
+// `ended` is false when the lobby had already ended: the call is a no-op, so stats are never counted twice.
+func (m *MariaDB) EndLobby(lobbyUniqueId string) (ended bool, err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	return err
+	var lobbyId int
+	var ranked bool
+	query := `SELECT id, ranked FROM lobby WHERE uuid = ? FOR UPDATE;`
+	if err := tx.QueryRow(query, lobbyUniqueId).Scan(&lobbyId, &ranked); err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+
+	res, err := tx.Exec(`UPDATE lobby SET ended = TRUE WHERE id = ? AND ended = FALSE;`, lobbyId)
+	if err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	query = "UPDATE `challenge` c JOIN lobby l ON l.challenge_id = c.id SET c.play_count = c.play_count + 1 WHERE l.id = ?;"
+	if _, err := tx.Exec(query, lobbyId); err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+
+	if err := rankLobbyUsers(tx, "l.id = ?", lobbyId); err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+
+	if err := incrementLobbyStats(tx, lobbyId); err != nil {
+		return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+	}
+
+	if ranked {
+		if err := updateLobbyRatings(tx, lobbyId); err != nil {
+			return false, fmt.Errorf("DB(EndLobby): %s", err.Error())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func (m *MariaDB) UpdateShareLobbyCode(lobbyId int, userId int, showCode bool) error {
@@ -196,19 +280,22 @@ func (m *MariaDB) UpdateShareLobbyCode(lobbyId int, userId int, showCode bool) e
 	return err
 }
 
-func (m *MariaDB) GetMatchByUsername(username string) ([]*types.SingleMatchResult, error) {
+// GetMatchByUsername returns the ended matches of the user, hiding the code not shared with the viewer.
+// The visibility of the match history itself is checked by the caller.
+func (m *MariaDB) GetMatchByUsername(username string, viewerId int) ([]*types.SingleMatchResult, error) {
 	query := `
 	SELECT 
 		l.id AS lobby_id, l.uuid AS lobby_uuid, l.created_at AS lobby_created_at,
 		l.mode AS lobby_mode, l.max_players AS lobby_max_players, l.game_duration AS lobby_game_duration, l.allowed_languages AS lobby_allowed_languages,
-		l.challenge_id, ch.title AS challenge_title, ch.description AS challenge_description,
+		l.challenge_id, COALESCE(cr.title, ch.title) AS challenge_title, COALESCE(cr.description, ch.description) AS challenge_description,
 		own.id AS challenge_owner_id, own.username AS challenge_owner_username, own.name AS challenge_owner_name, own.avatar AS challenge_owner_avatar,
 		us.id AS player_id, us.username AS player_username, us.name AS player_name, us.avatar AS player_avatar,
-		u.code AS player_code, u.language AS player_language, u.tests_passed AS player_tests_passed, u.show_code AS player_show_code, u.submitted_at AS player_submitted_at
+		u.code AS player_code, u.language AS player_language, u.tests_passed AS player_tests_passed, u.show_code AS player_show_code, u.match_rank AS player_rank, u.submitted_at AS player_submitted_at
 	FROM lobby l
 	JOIN lobby_user u ON l.id = u.lobby_id AND l.ended = 1
 	JOIN user us ON u.user_id = us.id
 	JOIN challenge ch ON l.challenge_id = ch.id
+	LEFT JOIN challenge_revision cr ON cr.id = l.challenge_revision_id
 	JOIN user own ON ch.owner_id = own.id
 	WHERE us.username = ?;`
 
@@ -252,6 +339,7 @@ func (m *MariaDB) GetMatchByUsername(username string) ([]*types.SingleMatchResul
 			&match.Player.Language,
 			&match.Player.TestsPassed,
 			&match.Player.ShowCode,
+			&match.Player.Rank,
 			&match.Player.SubmittedAt,
 		)
 		if err != nil {
@@ -271,6 +359,17 @@ func (m *MariaDB) GetMatchByUsername(username string) ([]*types.SingleMatchResul
 		return nil, err
 	}
 
+	canViewCode := m.codeVisibility(viewerId)
+	for _, match := range matches {
+		canView, err := canViewCode(match.Player.Id, match.Player.ShowCode)
+		if err != nil {
+			return nil, err
+		}
+		if !canView {
+			match.Player.Code = nil
+		}
+	}
+
 	return matches, nil
 }
 
@@ -281,9 +380,16 @@ func (m *MariaDB) InitLobbyTables() []MigrationFunc {
 		m.createTableLanguage,
 		m.createTableLobby,
 		m.createTableLobbyUser,
+		m.migrateLobbyRanked,
 	}
 }
 
+func (m *MariaDB) migrateLobbyRanked() error {
+	query := `ALTER TABLE lobby ADD COLUMN IF NOT EXISTS ranked BOOLEAN NOT NULL DEFAULT FALSE AFTER ended;`
+	_, err := m.db.Exec(query)
+	return err
+}
+
 func (m *MariaDB) createTableLobby() error {
 	query := `CREATE TABLE IF NOT EXISTS lobby (
 		id INT AUTO_INCREMENT,
@@ -291,6 +397,7 @@ func (m *MariaDB) createTableLobby() error {
 		challenge_id INT NOT NULL,
 		owner_id INT NOT NULL,
 		ended BOOLEAN NOT NULL DEFAULT FALSE,
+		ranked BOOLEAN NOT NULL DEFAULT FALSE,
 		
 		mode VARCHAR(50) NOT NULL,
 		max_players INT NOT NULL,
@@ -0,0 +1,242 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// CreateNotifications notifies every user in `userIds` that didn't opt out of the notification type
+func (m *MariaDB) CreateNotifications(userIds []int, notificationType string, data map[string]any) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(userIds)), ",")
+	query := `INSERT INTO notification (user_id, type, data)
+	SELECT u.id, ?, ? FROM user u
+	WHERE u.id IN (` + placeholders + `)
+	AND NOT EXISTS (
+		SELECT 1 FROM notification_preference np
+		WHERE np.user_id = u.id AND np.type = ? AND np.enabled = FALSE
+	);`
+
+	args := []any{notificationType, string(encodedData)}
+	for _, userId := range userIds {
+		args = append(args, userId)
+	}
+	args = append(args, notificationType)
+
+	if _, err := m.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("DB(CreateNotifications): %s", err.Error())
+	}
+	return nil
+}
+
+// GetNotifications returns a page of the notifications of the user, newest first
+func (m *MariaDB) GetNotifications(userId int, unreadOnly bool, cursor string, limit int) (*types.NotificationListResponse, error) {
+	decodedCursor, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{userId}
+	conditions := ""
+	if unreadOnly {
+		conditions += " AND read_at IS NULL"
+	}
+	if decodedCursor != nil {
+		conditions += " AND id < ?"
+		args = append(args, decodedCursor.Id)
+	}
+	args = append(args, limit+1)
+
+	query := `SELECT id, user_id, type, data, read_at, created_at FROM notification
+	WHERE user_id = ?` + conditions + `
+	ORDER BY id DESC
+	LIMIT ?;`
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetNotifications): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetNotifications): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	notifications := []*types.Notification{}
+	for rows.Next() {
+		notification := &types.Notification{}
+		var data string
+		if err := rows.Scan(
+			&notification.Id,
+			&notification.UserId,
+			&notification.Type,
+			&data,
+			&notification.ReadAt,
+			&notification.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(GetNotifications): %s", err.Error())
+		}
+		if err := json.Unmarshal([]byte(data), &notification.Data); err != nil {
+			return nil, fmt.Errorf("DB(GetNotifications): %s", err.Error())
+		}
+		notifications = append(notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetNotifications): %s", err.Error())
+	}
+
+	unreadCount, err := m.GetUnreadNotificationCount(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.NotificationListResponse{Notifications: notifications, UnreadCount: unreadCount}
+	if len(notifications) > limit {
+		response.Notifications = notifications[:limit]
+		last := response.Notifications[len(response.Notifications)-1]
+		response.NextCursor = utils.EncodeCursor(&utils.Cursor{Value: float64(last.Id), Id: last.Id})
+	}
+
+	return response, nil
+}
+
+func (m *MariaDB) GetUnreadNotificationCount(userId int) (int, error) {
+	query := `SELECT COUNT(*) FROM notification WHERE user_id = ? AND read_at IS NULL;`
+	var count int
+	if err := m.db.QueryRow(query, userId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("DB(GetUnreadNotificationCount): %s", err.Error())
+	}
+	return count, nil
+}
+
+func (m *MariaDB) MarkNotificationRead(userId, notificationId int) error {
+	query := `UPDATE notification SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP) WHERE id = ? AND user_id = ?;`
+	res, err := m.db.Exec(query, notificationId, userId)
+	if err != nil {
+		return fmt.Errorf("DB(MarkNotificationRead): %s", err.Error())
+	}
+
+	// notifications already read are not reported as affected, tell them apart from missing ones
+	var exists bool
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		query := `SELECT EXISTS(SELECT 1 FROM notification WHERE id = ? AND user_id = ?);`
+		if err := m.db.QueryRow(query, notificationId, userId).Scan(&exists); err != nil {
+			return fmt.Errorf("DB(MarkNotificationRead): %s", err.Error())
+		}
+		if !exists {
+			return fmt.Errorf("DB(MarkNotificationRead): notification with id %d not found", notificationId)
+		}
+	}
+	return nil
+}
+
+func (m *MariaDB) MarkAllNotificationsRead(userId int) error {
+	query := `UPDATE notification SET read_at = CURRENT_TIMESTAMP WHERE user_id = ? AND read_at IS NULL;`
+	if _, err := m.db.Exec(query, userId); err != nil {
+		return fmt.Errorf("DB(MarkAllNotificationsRead): %s", err.Error())
+	}
+	return nil
+}
+
+// GetNotificationPreferences returns every notification type, enabled unless the user opted out
+func (m *MariaDB) GetNotificationPreferences(userId int) (types.NotificationPreferences, error) {
+	preferences := types.NotificationPreferences{}
+	for _, notificationType := range types.NotificationTypes {
+		preferences[notificationType] = true
+	}
+
+	query := `SELECT type, enabled FROM notification_preference WHERE user_id = ?;`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetNotificationPreferences): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetNotificationPreferences): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	for rows.Next() {
+		var notificationType string
+		var enabled bool
+		if err := rows.Scan(&notificationType, &enabled); err != nil {
+			return nil, fmt.Errorf("DB(GetNotificationPreferences): %s", err.Error())
+		}
+		if _, ok := preferences[notificationType]; ok {
+			preferences[notificationType] = enabled
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetNotificationPreferences): %s", err.Error())
+	}
+
+	return preferences, nil
+}
+
+// UpdateNotificationPreferences only changes the types present in `preferences`
+func (m *MariaDB) UpdateNotificationPreferences(userId int, preferences types.NotificationPreferences) error {
+	for notificationType, enabled := range preferences {
+		query := `INSERT INTO notification_preference (user_id, type, enabled) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE enabled = VALUES(enabled);`
+		if _, err := m.db.Exec(query, userId, notificationType, enabled); err != nil {
+			return fmt.Errorf("DB(UpdateNotificationPreferences): %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitNotificationTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableNotification,
+		m.createTableNotificationPreference,
+	}
+}
+
+func (m *MariaDB) createTableNotification() error {
+	query := `CREATE TABLE IF NOT EXISTS notification (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		type VARCHAR(50) NOT NULL,
+		data JSON NOT NULL,
+		read_at DATETIME,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		INDEX (user_id, read_at)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableNotificationPreference() error {
+	query := `CREATE TABLE IF NOT EXISTS notification_preference (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		type VARCHAR(50) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		UNIQUE INDEX (user_id, type)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// GetUserPreferences returns the preferences of the user, the defaults if never saved
+func (m *MariaDB) GetUserPreferences(userId int) (*types.UserPreferences, error) {
+	query := `SELECT version, data FROM user_preferences WHERE user_id = ?;`
+
+	var version int
+	var data string
+	err := m.db.QueryRow(query, userId).Scan(&version, &data)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("DB(GetUserPreferences): %s", err.Error())
+	}
+
+	preferences := types.DefaultUserPreferences()
+	if err == nil {
+		preferences, err = upgradeUserPreferences(version, []byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("DB(GetUserPreferences): %s", err.Error())
+		}
+	}
+
+	// notification opt-outs live in notification_preference, where they are enforced
+	preferences.Notifications, err = m.GetNotificationPreferences(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (m *MariaDB) UpdateUserPreferences(userId int, preferences *types.UserPreferences) error {
+	notifications := preferences.Notifications
+
+	stored := *preferences
+	stored.Version = types.UserPreferencesVersion
+	stored.Notifications = nil
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_preferences (user_id, version, data) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE version = VALUES(version), data = VALUES(data);`
+	if _, err := m.db.Exec(query, userId, stored.Version, string(data)); err != nil {
+		return fmt.Errorf("DB(UpdateUserPreferences): %s", err.Error())
+	}
+
+	return m.UpdateNotificationPreferences(userId, notifications)
+}
+
+// upgradeUserPreferences decodes preferences stored with an older version into the current one,
+// the fields missing from the stored data keep their default
+func upgradeUserPreferences(version int, data []byte) (*types.UserPreferences, error) {
+	if version > types.UserPreferencesVersion {
+		return nil, fmt.Errorf("unknown preferences version %d", version)
+	}
+
+	preferences := types.DefaultUserPreferences()
+	if err := json.Unmarshal(data, preferences); err != nil {
+		return nil, err
+	}
+
+	// v2 replaced profile_visibility with the privacy settings
+	if version < 2 {
+		legacy := struct {
+			ProfileVisibility string `json:"profile_visibility"`
+		}{}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, err
+		}
+		if legacy.ProfileVisibility != "" {
+			preferences.Privacy.Profile = legacy.ProfileVisibility
+		}
+	}
+
+	preferences.Version = types.UserPreferencesVersion
+
+	return preferences, nil
+}
+
+func (m *MariaDB) createTableUserPreferences() error {
+	query := `CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id INT NOT NULL,
+		version INT NOT NULL,
+		data JSON NOT NULL,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (user_id),
+		FOREIGN KEY (user_id) REFERENCES user(id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// GetUserPrivacy returns the privacy settings of the user, without the rest of the preferences
+func (m *MariaDB) GetUserPrivacy(userId int) (*types.PrivacySettings, error) {
+	query := `SELECT version, data FROM user_preferences WHERE user_id = ?;`
+
+	var version int
+	var data string
+	err := m.db.QueryRow(query, userId).Scan(&version, &data)
+	if err == sql.ErrNoRows {
+		return &types.DefaultUserPreferences().Privacy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserPrivacy): %s", err.Error())
+	}
+
+	preferences, err := upgradeUserPreferences(version, []byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserPrivacy): %s", err.Error())
+	}
+	return &preferences.Privacy, nil
+}
+
+// CanView tells if `viewerId` (0 when anonymous) can see what `ownerId` shares with `visibility`.
+// Owners and admins always can.
+func (m *MariaDB) CanView(viewerId, ownerId int, visibility string) (bool, error) {
+	if visibility == types.VisibilityPublic || (viewerId != 0 && viewerId == ownerId) {
+		return true, nil
+	}
+	if viewerId == 0 {
+		return false, nil
+	}
+
+	var role string
+	if err := m.db.QueryRow(`SELECT role FROM user WHERE id = ?;`, viewerId).Scan(&role); err != nil {
+		return false, fmt.Errorf("DB(CanView): %s", err.Error())
+	}
+	if role == types.UserRoleAdmin {
+		return true, nil
+	}
+
+	if visibility == types.VisibilityFollowers {
+		return m.IsFollowing(viewerId, ownerId)
+	}
+	return false, nil
+}
+
+// codeVisibility returns a func telling if the viewer can see the code a user submitted in a match,
+// the privacy of each user is loaded once
+func (m *MariaDB) codeVisibility(viewerId int) func(ownerId int, showCode bool) (bool, error) {
+	canViewByOwner := map[int]bool{}
+
+	return func(ownerId int, showCode bool) (bool, error) {
+		if viewerId != 0 && viewerId == ownerId {
+			return true, nil
+		}
+		if !showCode {
+			return false, nil
+		}
+
+		if canView, ok := canViewByOwner[ownerId]; ok {
+			return canView, nil
+		}
+
+		privacy, err := m.GetUserPrivacy(ownerId)
+		if err != nil {
+			return false, err
+		}
+		canView, err := m.CanView(viewerId, ownerId, privacy.Code)
+		if err != nil {
+			return false, err
+		}
+		canViewByOwner[ownerId] = canView
+		return canView, nil
+	}
+}
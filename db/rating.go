@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+type lobbyRatingPlayer struct {
+	userId int
+	rank   int
+	rating utils.Glicko2Rating
+}
+
+// updateLobbyRatings updates the rating of the players of a ranked (and ranked by
+// rankLobbyUsers) lobby. The match is a rating period in which every player played
+// against every other one: a better rank is a win, the same rank a draw.
+func updateLobbyRatings(tx *sql.Tx, lobbyId int) error {
+	var modeId int
+	query := `SELECT mo.id FROM lobby l JOIN mode mo ON mo.name = l.mode WHERE l.id = ?;`
+	if err := tx.QueryRow(query, lobbyId).Scan(&modeId); err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("%s%s lobby %d has an unknown mode, ratings not updated", utils.GetLogTag("DB"), utils.GetLogTag("warn"), lobbyId)
+			return nil
+		}
+		return err
+	}
+
+	query = `SELECT lu.user_id, COALESCE(lu.match_rank, 0),
+		COALESCE(r.rating, ?), COALESCE(r.deviation, ?), COALESCE(r.volatility, ?)
+	FROM lobby_user lu
+	LEFT JOIN user_rating r ON r.user_id = lu.user_id AND r.mode_id = ?
+	WHERE lu.lobby_id = ?
+	FOR UPDATE;`
+	rows, err := tx.Query(query, utils.Glicko2DefaultRating, utils.Glicko2DefaultDeviation, utils.Glicko2DefaultVolatility, modeId, lobbyId)
+	if err != nil {
+		return err
+	}
+
+	players := []*lobbyRatingPlayer{}
+	for rows.Next() {
+		player := &lobbyRatingPlayer{}
+		if err := rows.Scan(&player.userId, &player.rank, &player.rating.Rating, &player.rating.Deviation, &player.rating.Volatility); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		players = append(players, player)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(players) < 2 {
+		return nil
+	}
+
+	for _, player := range players {
+		results := make([]utils.Glicko2Result, 0, len(players)-1)
+		for _, opponent := range players {
+			if opponent == player {
+				continue
+			}
+			score := 0.5
+			if player.rank < opponent.rank {
+				score = 1
+			} else if player.rank > opponent.rank {
+				score = 0
+			}
+			results = append(results, utils.Glicko2Result{Opponent: opponent.rating, Score: score})
+		}
+
+		updated := utils.UpdateGlicko2(player.rating, results)
+
+		query := `INSERT INTO user_rating (user_id, mode_id, rating, deviation, volatility, games)
+			VALUES (?, ?, ?, ?, ?, 1)
+		ON DUPLICATE KEY UPDATE
+			rating = VALUES(rating), deviation = VALUES(deviation), volatility = VALUES(volatility), games = games + 1;`
+		if _, err := tx.Exec(query, player.userId, modeId, updated.Rating, updated.Deviation, updated.Volatility); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO user_rating_history (user_id, mode_id, lobby_id, rating, deviation) VALUES (?, ?, ?, ?, ?);`
+		if _, err := tx.Exec(query, player.userId, modeId, lobbyId, updated.Rating, updated.Deviation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MariaDB) GetUserRatings(userId int) ([]*types.UserRating, error) {
+	query := `SELECT mo.name, r.rating, r.deviation, r.volatility, r.games, r.updated_at
+	FROM user_rating r
+	JOIN mode mo ON mo.id = r.mode_id
+	WHERE r.user_id = ?
+	ORDER BY mo.id;`
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserRatings): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserRatings): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	ratings := []*types.UserRating{}
+	for rows.Next() {
+		rating := &types.UserRating{}
+		if err := rows.Scan(&rating.Mode, &rating.Rating, &rating.Deviation, &rating.Volatility, &rating.Games, &rating.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("DB(GetUserRatings): %s", err.Error())
+		}
+		ratings = append(ratings, rating)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserRatings): %s", err.Error())
+	}
+
+	return ratings, nil
+}
+
+func (m *MariaDB) GetUserRatingHistory(userId int, mode string) ([]*types.RatingHistoryEntry, error) {
+	query := `SELECT l.uuid, h.rating, h.deviation, h.created_at
+	FROM user_rating_history h
+	JOIN mode mo ON mo.id = h.mode_id
+	JOIN lobby l ON l.id = h.lobby_id
+	WHERE h.user_id = ? AND mo.name = ?
+	ORDER BY h.id;`
+	rows, err := m.db.Query(query, userId, mode)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserRatingHistory): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserRatingHistory): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	history := []*types.RatingHistoryEntry{}
+	for rows.Next() {
+		entry := &types.RatingHistoryEntry{}
+		if err := rows.Scan(&entry.LobbyUniqueId, &entry.Rating, &entry.Deviation, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("DB(GetUserRatingHistory): %s", err.Error())
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserRatingHistory): %s", err.Error())
+	}
+
+	return history, nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) InitRatingTables() []MigrationFunc {
+	return []MigrationFunc{
+		m.createTableUserRating,
+		m.createTableUserRatingHistory,
+	}
+}
+
+func (m *MariaDB) createTableUserRating() error {
+	query := `CREATE TABLE IF NOT EXISTS user_rating (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		mode_id INT NOT NULL,
+
+		rating DOUBLE NOT NULL,
+		deviation DOUBLE NOT NULL,
+		volatility DOUBLE NOT NULL,
+		games INT NOT NULL DEFAULT 0,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		FOREIGN KEY (mode_id) REFERENCES mode(id),
+		UNIQUE INDEX (user_id, mode_id),
+		INDEX (mode_id, rating)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableUserRatingHistory() error {
+	query := `CREATE TABLE IF NOT EXISTS user_rating_history (
+		id INT AUTO_INCREMENT,
+		user_id INT NOT NULL,
+		mode_id INT NOT NULL,
+		lobby_id INT NOT NULL,
+
+		rating DOUBLE NOT NULL,
+		deviation DOUBLE NOT NULL,
+
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (user_id) REFERENCES user(id),
+		FOREIGN KEY (mode_id) REFERENCES mode(id),
+		FOREIGN KEY (lobby_id) REFERENCES lobby(id),
+		INDEX (user_id, mode_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
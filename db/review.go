@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// GetChallengeStatus returns the review status of the challenge, empty when the challenge doesn't exist
+func (m *MariaDB) GetChallengeStatus(challengeId int) (string, error) {
+	var status string
+	err := m.db.QueryRow("SELECT status FROM `challenge` WHERE id = ?;", challengeId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("DB(GetChallengeStatus): %s", err.Error())
+	}
+	return status, nil
+}
+
+// SetChallengeStatus moves the challenge from `from` to `to` and logs the change with the comment of the reviewer.
+// Fails when the challenge is no longer in `from`, the caller checks the transition is allowed.
+func (m *MariaDB) SetChallengeStatus(challengeId, reviewerId int, from, to, comment string) (*types.ChallengeReview, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := "UPDATE `challenge` SET status = ?, status_updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?;"
+	res, err := tx.Exec(query, to, challengeId, from)
+	if err != nil {
+		return nil, fmt.Errorf("DB(SetChallengeStatus): %s", err.Error())
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("DB(SetChallengeStatus): challenge %d is not %s", challengeId, from)
+	}
+
+	query = `INSERT INTO challenge_review (challenge_id, reviewer_id, from_status, to_status, comment) VALUES (?, ?, ?, ?, ?);`
+	res, err = tx.Exec(query, challengeId, reviewerId, from, to, comment)
+	if err != nil {
+		return nil, fmt.Errorf("DB(SetChallengeStatus): %s", err.Error())
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	reviews, err := m.queryChallengeReviews("SetChallengeStatus", "r.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(reviews) == 0 {
+		return nil, fmt.Errorf("DB(SetChallengeStatus): review %d not found", id)
+	}
+	return reviews[0], nil
+}
+
+// reopenChallengeReview sends the challenge back to draft when it's edited by its author, or by anyone who isn't a moderator,
+// while submitted, approved or published, so every content reaching the lobbies was reviewed. The change is logged as
+// a review of the author and runs in the transaction of the edit.
+func reopenChallengeReview(tx *sql.Tx, challengeId, authorId int) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM `challenge` WHERE id = ? FOR UPDATE;", challengeId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("DB(reopenChallengeReview): challenge with id %d not found", challengeId)
+	}
+	if err != nil {
+		return fmt.Errorf("DB(reopenChallengeReview): %s", err.Error())
+	}
+	if status == types.ChallengeStatusDraft || status == types.ChallengeStatusRejected {
+		return nil
+	}
+
+	var role string
+	if err := tx.QueryRow(`SELECT role FROM user WHERE id = ?;`, authorId).Scan(&role); err != nil {
+		return fmt.Errorf("DB(reopenChallengeReview): %s", err.Error())
+	}
+	if types.IsModeratorRole(role) {
+		return nil
+	}
+
+	query := "UPDATE `challenge` SET status = ?, status_updated_at = CURRENT_TIMESTAMP WHERE id = ?;"
+	if _, err := tx.Exec(query, types.ChallengeStatusDraft, challengeId); err != nil {
+		return fmt.Errorf("DB(reopenChallengeReview): %s", err.Error())
+	}
+	query = `INSERT INTO challenge_review (challenge_id, reviewer_id, from_status, to_status, comment) VALUES (?, ?, ?, ?, ?);`
+	if _, err := tx.Exec(query, challengeId, authorId, status, types.ChallengeStatusDraft, challengeEditedComment); err != nil {
+		return fmt.Errorf("DB(reopenChallengeReview): %s", err.Error())
+	}
+	return nil
+}
+
+// challengeEditedComment is the comment of the reviews logged by reopenChallengeReview
+const challengeEditedComment = "edited after the review, it has to be submitted again"
+
+// GetChallengeReviews returns the status changes of the challenge, newest first
+func (m *MariaDB) GetChallengeReviews(challengeId int) ([]*types.ChallengeReview, error) {
+	return m.queryChallengeReviews("GetChallengeReviews", "r.challenge_id = ?", challengeId)
+}
+
+func (m *MariaDB) queryChallengeReviews(funcName, condition string, args ...any) ([]*types.ChallengeReview, error) {
+	query := `SELECT r.id, r.challenge_id, r.reviewer_id, u.username, r.from_status, r.to_status, r.comment, r.created_at
+	FROM challenge_review r
+	JOIN user u ON u.id = r.reviewer_id
+	WHERE ` + condition + `
+	ORDER BY r.id DESC;`
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(%s): %s", utils.GetLogTag("DB"), funcName, err)
+		}
+	}()
+
+	reviews := []*types.ChallengeReview{}
+	for rows.Next() {
+		review := &types.ChallengeReview{}
+		if err := rows.Scan(
+			&review.Id,
+			&review.ChallengeId,
+			&review.ReviewerId,
+			&review.ReviewerUsername,
+			&review.FromStatus,
+			&review.ToStatus,
+			&review.Comment,
+			&review.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(%s): %s", funcName, err.Error())
+	}
+
+	return reviews, nil
+}
+
+// GetChallengeReviewQueue returns a page of the submitted challenges, the ones waiting for longer first
+func (m *MariaDB) GetChallengeReviewQueue(cursor string, limit int) (*types.ChallengeListResponse, error) {
+	conditions := []string{"c.status = ?"}
+	args := []any{types.ChallengeStatusSubmitted}
+	return m.queryChallengeSummaries("GetChallengeReviewQueue", conditions, args, "UNIX_TIMESTAMP(t.status_updated_at)", true, cursor, limit)
+}
+
+// -- Init Tables --
+func (m *MariaDB) createTableChallengeReview() error {
+	query := `CREATE TABLE IF NOT EXISTS challenge_review (
+		id INT AUTO_INCREMENT,
+		challenge_id INT NOT NULL,
+		reviewer_id INT NOT NULL,
+		from_status VARCHAR(10) NOT NULL,
+		to_status VARCHAR(10) NOT NULL,
+		comment TEXT NOT NULL,
+
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE,
+		FOREIGN KEY (reviewer_id) REFERENCES user(id),
+		INDEX (challenge_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// migrateChallengeStatus publishes the challenges created before the review workflow, they were already playable
+func (m *MariaDB) migrateChallengeStatus() error {
+	queries := []string{
+		"ALTER TABLE `challenge` ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'published' AFTER computed_difficulty, ADD COLUMN IF NOT EXISTS status_updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER status;",
+		"ALTER TABLE `challenge` ALTER COLUMN status SET DEFAULT 'draft';",
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// challengeSnapshot is the part of a challenge tracked by its revisions
+type challengeSnapshot struct {
+	title       string
+	description string
+	content     string
+	tests       []types.TestCase
+	hiddenTests []types.TestCase
+	templates   []types.ChallengeTemplate // sorted by language, without updated_at
+}
+
+// diffSnapshots returns the changes of every field that differs, tests are compared as indented JSON
+func diffSnapshots(before, after *challengeSnapshot) ([]types.RevisionChange, error) {
+	fields := []struct {
+		name          string
+		before, after any
+	}{
+		{types.RevisionFieldTitle, before.title, after.title},
+		{types.RevisionFieldDescription, before.description, after.description},
+		{types.RevisionFieldContent, before.content, after.content},
+		{types.RevisionFieldTests, before.tests, after.tests},
+		{types.RevisionFieldHiddenTests, before.hiddenTests, after.hiddenTests},
+		{types.RevisionFieldTemplates, before.templates, after.templates},
+	}
+
+	changes := []types.RevisionChange{}
+	for _, field := range fields {
+		beforeText, err := revisionText(field.before)
+		if err != nil {
+			return nil, err
+		}
+		afterText, err := revisionText(field.after)
+		if err != nil {
+			return nil, err
+		}
+
+		if lines := utils.DiffLines(beforeText, afterText); len(lines) > 0 {
+			changes = append(changes, types.RevisionChange{Field: field.name, Lines: lines})
+		}
+	}
+	return changes, nil
+}
+
+func revisionText(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case []types.TestCase:
+		if value == nil {
+			value = []types.TestCase{}
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		return string(data), err
+	case []types.ChallengeTemplate:
+		if value == nil {
+			value = []types.ChallengeTemplate{}
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported revision field %T", value)
+	}
+}
+
+// reviseChallenge applies `change` to the current content of the challenge and stores the result
+// as a new revision. `change` runs in the transaction of the revision, with the challenge row locked, so it can
+// update other columns along with it. Returns the current revision number, unchanged when `change` didn't modify anything.
+func (m *MariaDB) reviseChallenge(challengeId, authorId int, rollbackOf *int, change func(*sql.Tx, *challengeSnapshot) error) (int, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	current := &challengeSnapshot{}
+	var tests, hiddenTests string
+	var revision int
+	query := "SELECT title, description, content, COALESCE(tests, '[]'), COALESCE(tests_hidden, '[]'), revision FROM `challenge` WHERE id = ? FOR UPDATE;"
+	err = tx.QueryRow(query, challengeId).Scan(&current.title, &current.description, &current.content, &tests, &hiddenTests, &revision)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("DB(reviseChallenge): challenge with id %d not found", challengeId)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("DB(reviseChallenge): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(tests), &current.tests); err != nil {
+		return 0, fmt.Errorf("DB(reviseChallenge): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(hiddenTests), &current.hiddenTests); err != nil {
+		return 0, fmt.Errorf("DB(reviseChallenge): %s", err.Error())
+	}
+	templates, err := selectSnapshotTemplates(tx, challengeId)
+	if err != nil {
+		return 0, err
+	}
+	current.templates = snapshotTemplates(templates)
+
+	next := *current
+	next.tests = append([]types.TestCase{}, current.tests...)
+	next.hiddenTests = append([]types.TestCase{}, current.hiddenTests...)
+	next.templates = append([]types.ChallengeTemplate{}, current.templates...)
+	if err := change(tx, &next); err != nil {
+		return 0, err
+	}
+	next.templates = snapshotTemplates(next.templates)
+
+	diff, err := diffSnapshots(current, &next)
+	if err != nil {
+		return 0, fmt.Errorf("DB(reviseChallenge): %s", err.Error())
+	}
+	if len(diff) == 0 {
+		return revision, tx.Commit()
+	}
+
+	if tests, err = marshalTestCases(next.tests); err != nil {
+		return 0, err
+	}
+	if hiddenTests, err = marshalTestCases(next.hiddenTests); err != nil {
+		return 0, err
+	}
+
+	if err := reopenChallengeReview(tx, challengeId, authorId); err != nil {
+		return 0, err
+	}
+
+	revision++
+	query = "UPDATE `challenge` SET title = ?, description = ?, content = ?, tests = ?, tests_hidden = ?, revision = ? WHERE id = ?;"
+	if _, err := tx.Exec(query, next.title, next.description, next.content, tests, hiddenTests, revision, challengeId); err != nil {
+		return 0, fmt.Errorf("DB(reviseChallenge): %s", err.Error())
+	}
+	if err := saveChallengeTemplates(tx, challengeId, current.templates, next.templates); err != nil {
+		return 0, err
+	}
+	if err := insertChallengeRevision(tx, challengeId, revision, authorId, rollbackOf, &next, diff); err != nil {
+		return 0, err
+	}
+
+	return revision, tx.Commit()
+}
+
+func insertChallengeRevision(tx *sql.Tx, challengeId, revision, authorId int, rollbackOf *int, snapshot *challengeSnapshot, diff []types.RevisionChange) error {
+	tests, err := marshalTestCases(snapshot.tests)
+	if err != nil {
+		return err
+	}
+	hiddenTests, err := marshalTestCases(snapshot.hiddenTests)
+	if err != nil {
+		return err
+	}
+	templates := snapshot.templates
+	if templates == nil {
+		templates = []types.ChallengeTemplate{}
+	}
+	templatesJSON, err := json.Marshal(templates)
+	if err != nil {
+		return err
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO challenge_revision (challenge_id, revision, author_id, rollback_of, title, description, content, tests, tests_hidden, templates, diff)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.Exec(query, challengeId, revision, authorId, rollbackOf,
+		snapshot.title, snapshot.description, snapshot.content, tests, hiddenTests, string(templatesJSON), string(diffJSON),
+	); err != nil {
+		return fmt.Errorf("DB(insertChallengeRevision): %s", err.Error())
+	}
+	return nil
+}
+
+// GetChallengeRevisions returns the history of the challenge, newest first
+func (m *MariaDB) GetChallengeRevisions(challengeId int) ([]*types.ChallengeRevisionSummary, error) {
+	query := `SELECT r.id, r.revision, r.author_id, u.username, r.rollback_of, COALESCE(JSON_EXTRACT(r.diff, '$[*].field'), '[]'), r.created_at
+	FROM challenge_revision r
+	JOIN user u ON u.id = r.author_id
+	WHERE r.challenge_id = ?
+	ORDER BY r.revision DESC;`
+	rows, err := m.db.Query(query, challengeId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevisions): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetChallengeRevisions): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	revisions := []*types.ChallengeRevisionSummary{}
+	for rows.Next() {
+		revision := &types.ChallengeRevisionSummary{}
+		var fields string
+		if err := rows.Scan(
+			&revision.Id,
+			&revision.Revision,
+			&revision.AuthorId,
+			&revision.AuthorUsername,
+			&revision.RollbackOf,
+			&fields,
+			&revision.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("DB(GetChallengeRevisions): %s", err.Error())
+		}
+		if err := json.Unmarshal([]byte(fields), &revision.ChangedFields); err != nil {
+			return nil, fmt.Errorf("DB(GetChallengeRevisions): %s", err.Error())
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevisions): %s", err.Error())
+	}
+
+	return revisions, nil
+}
+
+// GetChallengeRevision returns a revision of the challenge by its number, hidden tests and templates included
+func (m *MariaDB) GetChallengeRevision(challengeId, revision int) (*types.ChallengeRevision, error) {
+	query := `SELECT id, challenge_id, revision, author_id, rollback_of, title, description, content, tests, tests_hidden, templates, diff, created_at
+	FROM challenge_revision WHERE challenge_id = ? AND revision = ?;`
+
+	rev := &types.ChallengeRevision{}
+	var tests, hiddenTests, templates, diff string
+	if err := m.db.QueryRow(query, challengeId, revision).Scan(
+		&rev.Id,
+		&rev.ChallengeId,
+		&rev.Revision,
+		&rev.AuthorId,
+		&rev.RollbackOf,
+		&rev.Title,
+		&rev.Description,
+		&rev.Content,
+		&tests,
+		&hiddenTests,
+		&templates,
+		&diff,
+		&rev.CreatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevision): %s", err.Error())
+	}
+
+	if err := json.Unmarshal([]byte(tests), &rev.TestCases); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevision): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(hiddenTests), &rev.HiddenTestCases); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevision): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(templates), &rev.Templates); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevision): %s", err.Error())
+	}
+	if err := json.Unmarshal([]byte(diff), &rev.Diff); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeRevision): %s", err.Error())
+	}
+
+	return rev, nil
+}
+
+// RollbackChallenge restores the content of an older revision as a new revision, the history is never rewritten
+func (m *MariaDB) RollbackChallenge(challengeId, revision, authorId int) (*types.ChallengeRevision, error) {
+	target, err := m.GetChallengeRevision(challengeId, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := m.reviseChallenge(challengeId, authorId, &target.Revision, func(_ *sql.Tx, snapshot *challengeSnapshot) error {
+		snapshot.title = target.Title
+		snapshot.description = target.Description
+		snapshot.content = target.Content
+		snapshot.tests = target.TestCases
+		snapshot.hiddenTests = target.HiddenTestCases
+		snapshot.templates = target.Templates
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetChallengeRevision(challengeId, current)
+}
+
+// -- Init Tables --
+func (m *MariaDB) createTableChallengeRevision() error {
+	query := `CREATE TABLE IF NOT EXISTS challenge_revision (
+		id INT AUTO_INCREMENT,
+		challenge_id INT NOT NULL,
+		revision INT NOT NULL,
+		author_id INT NOT NULL,
+		rollback_of INT NULL DEFAULT NULL,
+
+		title VARCHAR(50) NOT NULL,
+		description VARCHAR(255) NOT NULL,
+		content LONGTEXT NOT NULL,
+		tests JSON NOT NULL DEFAULT '[]',
+		tests_hidden JSON NOT NULL DEFAULT '[]',
+		templates JSON NOT NULL DEFAULT '[]',
+		diff JSON NOT NULL DEFAULT '[]',
+
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE,
+		FOREIGN KEY (author_id) REFERENCES user(id),
+		UNIQUE INDEX (challenge_id, revision)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// migrateChallengeRevisions saves the current content of the challenges created before the revisions
+// as their first revision, and pins the lobbies already played to it
+func (m *MariaDB) migrateChallengeRevisions() error {
+	queries := []string{
+		"ALTER TABLE `challenge` ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1 AFTER tests_hidden;",
+		`INSERT INTO challenge_revision (challenge_id, revision, author_id, title, description, content, tests, tests_hidden)
+		SELECT c.id, c.revision, c.owner_id, c.title, c.description, c.content, c.tests, c.tests_hidden
+		FROM challenge c
+		WHERE NOT EXISTS (SELECT 1 FROM challenge_revision r WHERE r.challenge_id = c.id);`,
+		`ALTER TABLE lobby
+		ADD COLUMN IF NOT EXISTS challenge_revision_id INT NULL DEFAULT NULL AFTER challenge_id,
+		ADD CONSTRAINT lobby_challenge_revision_fk FOREIGN KEY IF NOT EXISTS (challenge_revision_id) REFERENCES challenge_revision(id);`,
+		`UPDATE lobby l SET challenge_revision_id = (
+			SELECT MIN(r.id) FROM challenge_revision r WHERE r.challenge_id = l.challenge_id
+		) WHERE challenge_revision_id IS NULL;`,
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/xedom/codeduel/types"
+)
+
+func TestDiffSnapshotsTemplates(t *testing.T) {
+	goTemplate := types.ChallengeTemplate{Language: "go", Signature: "func sum(a, b int) int", StarterCode: "package main\n"}
+	pythonTemplate := types.ChallengeTemplate{Language: "python", Signature: "def sum(a, b):"}
+	updatedGo := goTemplate
+	updatedGo.UpdatedAt = "2024-05-01 12:00:00"
+	changedGo := goTemplate
+	changedGo.StarterCode = "package main\n\nimport \"fmt\"\n"
+
+	tests := []struct {
+		name          string
+		before, after []types.ChallengeTemplate
+		wantChange    bool
+	}{
+		{"same templates", []types.ChallengeTemplate{goTemplate, pythonTemplate}, []types.ChallengeTemplate{goTemplate, pythonTemplate}, false},
+		{"other order", []types.ChallengeTemplate{goTemplate, pythonTemplate}, []types.ChallengeTemplate{pythonTemplate, goTemplate}, false},
+		{"updated_at only", []types.ChallengeTemplate{goTemplate}, []types.ChallengeTemplate{updatedGo}, false},
+		{"no templates", nil, []types.ChallengeTemplate{}, false},
+		{"changed starter code", []types.ChallengeTemplate{goTemplate}, []types.ChallengeTemplate{changedGo}, true},
+		{"added", []types.ChallengeTemplate{goTemplate}, []types.ChallengeTemplate{goTemplate, pythonTemplate}, true},
+		{"removed", []types.ChallengeTemplate{goTemplate, pythonTemplate}, []types.ChallengeTemplate{pythonTemplate}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := &challengeSnapshot{templates: snapshotTemplates(test.before)}
+			after := &challengeSnapshot{templates: snapshotTemplates(test.after)}
+			diff, err := diffSnapshots(before, after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := len(diff) == 1 && diff[0].Field == types.RevisionFieldTemplates; changed != test.wantChange || len(diff) > 1 {
+				t.Errorf("diff = %+v, want a change of the templates %v", diff, test.wantChange)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// searchSnippetWidth is the number of characters of the snippets around the first match
+const searchSnippetWidth = 160
+
+// ChallengeSearcher finds the challenges matching a full-text query, best matches first.
+// MariaDB implements it with a FULLTEXT index, MemoryChallengeIndex in process for tests
+// and deployments without MariaDB.
+type ChallengeSearcher interface {
+	SearchChallenges(*types.ChallengeSearchQuery) ([]*types.ChallengeSearchResult, error)
+}
+
+func (m *MariaDB) SearchChallenges(query *types.ChallengeSearchQuery) ([]*types.ChallengeSearchResult, error) {
+	conditions, filterArgs := challengeFilterConditions(&query.Filter)
+	conditions = append([]string{"MATCH(c.title, c.description, c.content) AGAINST (? IN NATURAL LANGUAGE MODE)"}, conditions...)
+
+	args := []any{query.Query, query.Query, query.Query}
+	args = append(args, filterArgs...)
+	args = append(args, query.Limit)
+
+	// matches in the title weigh more, like in the in-process index
+	sqlQuery := `SELECT c.id, c.owner_id, c.title, c.description, c.content, c.difficulty, c.computed_difficulty,
+		MATCH(c.title) AGAINST (? IN NATURAL LANGUAGE MODE) * 2 + MATCH(c.title, c.description, c.content) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+	FROM challenge c
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY score DESC, c.id DESC
+	LIMIT ?;`
+	rows, err := m.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(SearchChallenges): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(SearchChallenges): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	terms := utils.SearchTerms(query.Query)
+	results := []*types.ChallengeSearchResult{}
+	ids := []int{}
+	for rows.Next() {
+		result := &types.ChallengeSearchResult{}
+		var content string
+		if err := rows.Scan(
+			&result.Id,
+			&result.OwnerId,
+			&result.Title,
+			&result.Description,
+			&content,
+			&result.Difficulty,
+			&result.ComputedDifficulty,
+			&result.Score,
+		); err != nil {
+			return nil, fmt.Errorf("DB(SearchChallenges): %s", err.Error())
+		}
+		result.Snippets = searchSnippets(terms, result.Title, result.Description, content)
+		results = append(results, result)
+		ids = append(ids, result.Id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(SearchChallenges): %s", err.Error())
+	}
+
+	tags, err := m.getChallengesTags(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		result.Tags = tags[result.Id]
+	}
+
+	return results, nil
+}
+
+// searchSnippets highlights the terms in the searchable fields of a challenge
+func searchSnippets(terms []string, title, description, content string) []types.SearchSnippet {
+	fields := []struct{ name, text string }{
+		{types.SearchFieldTitle, title},
+		{types.SearchFieldDescription, description},
+		{types.SearchFieldContent, content},
+	}
+
+	snippets := []types.SearchSnippet{}
+	for _, field := range fields {
+		text, highlights, ok := utils.Snippet(field.text, terms, searchSnippetWidth)
+		if !ok {
+			continue
+		}
+
+		snippet := types.SearchSnippet{Field: field.name, Text: text, Highlights: []types.TextRange{}}
+		for _, highlight := range highlights {
+			snippet.Highlights = append(snippet.Highlights, types.TextRange{Start: highlight[0], End: highlight[1]})
+		}
+		snippets = append(snippets, snippet)
+	}
+	return snippets
+}
+
+// -- Init Tables --
+func (m *MariaDB) createChallengeSearchIndexes() error {
+	query := "ALTER TABLE `challenge` ADD FULLTEXT INDEX IF NOT EXISTS challenge_search (title, description, content), ADD FULLTEXT INDEX IF NOT EXISTS challenge_title_search (title);"
+	_, err := m.db.Exec(query)
+	return err
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// MemoryChallengeIndex is an in-process inverted index of the challenges, it has to be fed
+// with every change through Index and Remove
+type MemoryChallengeIndex struct {
+	mu         sync.RWMutex
+	challenges map[int]*indexedChallenge
+	postings   map[string]map[int]*termFrequency // term -> challenge id -> occurrences
+}
+
+type indexedChallenge struct {
+	challenge types.Challenge
+	terms     map[string]*termFrequency
+}
+
+type termFrequency struct {
+	title int
+	all   int // title, description and content
+}
+
+func NewMemoryChallengeIndex() *MemoryChallengeIndex {
+	return &MemoryChallengeIndex{
+		challenges: map[int]*indexedChallenge{},
+		postings:   map[string]map[int]*termFrequency{},
+	}
+}
+
+// Index adds the challenge to the index, replacing its previous version
+func (i *MemoryChallengeIndex) Index(challenge *types.Challenge) {
+	terms := map[string]*termFrequency{}
+	count := func(text string, isTitle bool) {
+		for _, term := range utils.SearchTerms(text) {
+			if terms[term] == nil {
+				terms[term] = &termFrequency{}
+			}
+			terms[term].all++
+			if isTitle {
+				terms[term].title++
+			}
+		}
+	}
+	count(challenge.Title, true)
+	count(challenge.Description, false)
+	count(challenge.Content, false)
+
+	indexed := &indexedChallenge{challenge: *challenge, terms: terms}
+	indexed.challenge.TestCases = nil
+	indexed.challenge.HiddenTestCases = nil
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(challenge.Id)
+	i.challenges[challenge.Id] = indexed
+	for term, frequency := range terms {
+		if i.postings[term] == nil {
+			i.postings[term] = map[int]*termFrequency{}
+		}
+		i.postings[term][challenge.Id] = frequency
+	}
+}
+
+func (i *MemoryChallengeIndex) Remove(challengeId int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(challengeId)
+}
+
+func (i *MemoryChallengeIndex) remove(challengeId int) {
+	indexed, ok := i.challenges[challengeId]
+	if !ok {
+		return
+	}
+	for term := range indexed.terms {
+		delete(i.postings[term], challengeId)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.challenges, challengeId)
+}
+
+// SearchChallenges ranks the challenges by TF-IDF, the matches in the title weigh more
+func (i *MemoryChallengeIndex) SearchChallenges(query *types.ChallengeSearchQuery) ([]*types.ChallengeSearchResult, error) {
+	terms := utils.SearchTerms(query.Query)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	scores := map[int]float64{}
+	seen := map[string]bool{}
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := i.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(i.challenges))/float64(len(postings)))
+		for challengeId, frequency := range postings {
+			scores[challengeId] += idf * float64(2*frequency.title+frequency.all)
+		}
+	}
+
+	results := []*types.ChallengeSearchResult{}
+	for challengeId, score := range scores {
+		challenge := &i.challenges[challengeId].challenge
+		if !matchesChallengeFilter(challenge, &query.Filter) {
+			continue
+		}
+
+		results = append(results, &types.ChallengeSearchResult{
+			Id:                 challenge.Id,
+			OwnerId:            challenge.OwnerId,
+			Title:              challenge.Title,
+			Description:        challenge.Description,
+			Difficulty:         challenge.Difficulty,
+			ComputedDifficulty: challenge.ComputedDifficulty,
+			Tags:               append([]string{}, challenge.Tags...),
+			Score:              score,
+			Snippets:           searchSnippets(terms, challenge.Title, challenge.Description, challenge.Content),
+		})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Id > results[b].Id
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
+
+// matchesChallengeFilter is the in-process counterpart of challengeFilterConditions
+func matchesChallengeFilter(challenge *types.Challenge, filter *types.ChallengeFilter) bool {
+	if filter.Status != "" && challenge.Status != filter.Status {
+		return false
+	}
+	if filter.OwnerId != 0 && challenge.OwnerId != filter.OwnerId {
+		return false
+	}
+	if filter.Difficulty != "" {
+		difficulty := challenge.Difficulty
+		if challenge.ComputedDifficulty != nil {
+			difficulty = *challenge.ComputedDifficulty
+		}
+		if difficulty != filter.Difficulty {
+			return false
+		}
+	}
+	for _, tag := range filter.Tags {
+		if !slices.Contains(challenge.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/xedom/codeduel/types"
+)
+
+func newTestChallengeIndex(challenges ...types.Challenge) *MemoryChallengeIndex {
+	index := NewMemoryChallengeIndex()
+	for i := range challenges {
+		index.Index(&challenges[i])
+	}
+	return index
+}
+
+func searchIds(t *testing.T, index *MemoryChallengeIndex, query *types.ChallengeSearchQuery) []int {
+	t.Helper()
+	results, err := index.SearchChallenges(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, result := range results {
+		ids = append(ids, result.Id)
+	}
+	return ids
+}
+
+func TestMemoryChallengeIndexRanking(t *testing.T) {
+	index := newTestChallengeIndex(
+		types.Challenge{Id: 1, Title: "Graph paths", Content: "find the shortest path"},
+		types.Challenge{Id: 2, Title: "Shortest path", Content: "on a grid"},
+		types.Challenge{Id: 3, Title: "Sorting", Content: "sort the numbers, then find the path"},
+		types.Challenge{Id: 4, Title: "Unrelated", Content: "nothing to see"},
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []int
+	}{
+		{"title weighs more than content", "shortest", 0, []int{2, 1}},
+		{"title and content", "path", 0, []int{2, 3, 1}},
+		{"every term adds up", "shortest path", 0, []int{2, 1, 3}},
+		{"case insensitive", "SORTING", 0, []int{3}},
+		{"limit", "path", 1, []int{2}},
+		{"no match", "tree", 0, []int{}},
+		{"too short terms are ignored", "a", 0, []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := searchIds(t, index, &types.ChallengeSearchQuery{Query: test.query, Limit: test.limit})
+			if !slices.Equal(got, test.want) {
+				t.Errorf("search %q = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMemoryChallengeIndexTitleWeight(t *testing.T) {
+	index := newTestChallengeIndex(
+		types.Challenge{Id: 1, Title: "Knapsack"},
+		types.Challenge{Id: 2, Title: "Bag", Content: "knapsack knapsack"},
+		types.Challenge{Id: 3, Title: "Bag", Content: "knapsack knapsack knapsack knapsack"},
+	)
+	results, err := index.SearchChallenges(&types.ChallengeSearchQuery{Query: "knapsack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	scores := map[int]float64{}
+	for _, result := range results {
+		scores[result.Id] = result.Score
+	}
+	// a match in the title counts three times, twice for the title and once as any other word
+	if scores[1] <= scores[2] || scores[1] >= scores[3] {
+		t.Errorf("scores %v, want the title match between two and four content matches", scores)
+	}
+	if ratio := scores[1] / scores[2]; ratio < 1.49 || ratio > 1.51 {
+		t.Errorf("title match scores %f times two content matches, want 1.5", ratio)
+	}
+}
+
+func TestMemoryChallengeIndexUpdates(t *testing.T) {
+	index := newTestChallengeIndex(types.Challenge{Id: 1, Title: "Old title"})
+
+	index.Index(&types.Challenge{Id: 1, Title: "New title"})
+	if got := searchIds(t, index, &types.ChallengeSearchQuery{Query: "old"}); len(got) != 0 {
+		t.Errorf("the previous version is still found: %v", got)
+	}
+	if got := searchIds(t, index, &types.ChallengeSearchQuery{Query: "new"}); !slices.Equal(got, []int{1}) {
+		t.Errorf("search new = %v, want [1]", got)
+	}
+
+	index.Remove(1)
+	if got := searchIds(t, index, &types.ChallengeSearchQuery{Query: "title"}); len(got) != 0 {
+		t.Errorf("the removed challenge is still found: %v", got)
+	}
+	if len(index.postings) != 0 {
+		t.Errorf("%d terms are left in the index", len(index.postings))
+	}
+}
+
+func TestMemoryChallengeIndexSnippets(t *testing.T) {
+	index := newTestChallengeIndex(types.Challenge{Id: 1, Title: "Two sum", Description: "Add numbers", Content: "Return the sum of the two numbers"})
+	results, err := index.SearchChallenges(&types.ChallengeSearchQuery{Query: "sum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	fields := []string{}
+	for _, snippet := range results[0].Snippets {
+		fields = append(fields, snippet.Field)
+		runes := []rune(snippet.Text)
+		for _, highlight := range snippet.Highlights {
+			if word := string(runes[highlight.Start:highlight.End]); word != "sum" {
+				t.Errorf("%s highlights %q, want sum", snippet.Field, word)
+			}
+		}
+	}
+	if len(fields) != 2 || fields[0] != types.SearchFieldTitle || fields[1] != types.SearchFieldContent {
+		t.Errorf("snippets of %v, want the title and the content", fields)
+	}
+}
+
+func TestMatchesChallengeFilter(t *testing.T) {
+	hard := types.DifficultyHard
+	challenge := &types.Challenge{
+		Id:                 1,
+		OwnerId:            7,
+		Difficulty:         types.DifficultyEasy,
+		ComputedDifficulty: &hard,
+		Status:             types.ChallengeStatusPublished,
+		Tags:               []string{"graphs", "dp"},
+	}
+
+	tests := []struct {
+		name   string
+		filter types.ChallengeFilter
+		want   bool
+	}{
+		{"no filter", types.ChallengeFilter{}, true},
+		{"status", types.ChallengeFilter{Status: types.ChallengeStatusPublished}, true},
+		{"other status", types.ChallengeFilter{Status: types.ChallengeStatusDraft}, false},
+		{"owner", types.ChallengeFilter{OwnerId: 7}, true},
+		{"other owner", types.ChallengeFilter{OwnerId: 8}, false},
+		{"computed difficulty", types.ChallengeFilter{Difficulty: types.DifficultyHard}, true},
+		{"author's difficulty once computed", types.ChallengeFilter{Difficulty: types.DifficultyEasy}, false},
+		{"tag", types.ChallengeFilter{Tags: []string{"dp"}}, true},
+		{"every tag", types.ChallengeFilter{Tags: []string{"dp", "graphs"}}, true},
+		{"missing tag", types.ChallengeFilter{Tags: []string{"dp", "math"}}, false},
+		{"everything", types.ChallengeFilter{Status: types.ChallengeStatusPublished, OwnerId: 7, Difficulty: types.DifficultyHard, Tags: []string{"graphs"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := matchesChallengeFilter(challenge, &test.filter); got != test.want {
+				t.Errorf("matchesChallengeFilter = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	uncomputed := &types.Challenge{Difficulty: types.DifficultyEasy}
+	if !matchesChallengeFilter(uncomputed, &types.ChallengeFilter{Difficulty: types.DifficultyEasy}) {
+		t.Errorf("the author's difficulty is used until the computed one is known")
+	}
+}
+
+func TestMemoryChallengeIndexFilter(t *testing.T) {
+	index := newTestChallengeIndex(
+		types.Challenge{Id: 1, Title: "Sum", Status: types.ChallengeStatusPublished},
+		types.Challenge{Id: 2, Title: "Sum", Status: types.ChallengeStatusSubmitted},
+	)
+	query := &types.ChallengeSearchQuery{Query: "sum", Filter: types.ChallengeFilter{Status: types.ChallengeStatusPublished}}
+	if got := searchIds(t, index, query); !slices.Equal(got, []int{1}) {
+		t.Errorf("search of the published challenges = %v, want [1]", got)
+	}
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/xedom/codeduel/types"
+)
+
+// rankLobbyUsers stores the final rank of the players of the lobbies matching `condition`.
+// Players passing more tests rank higher; ties are broken by the shortest code in "size"
+// lobbies, then by the earliest submission. Players that never submitted rank last.
+func rankLobbyUsers(tx *sql.Tx, condition string, args ...any) error {
+	query := `UPDATE lobby_user lu
+	JOIN (
+		SELECT u.id, RANK() OVER (
+			PARTITION BY u.lobby_id
+			ORDER BY
+				u.tests_passed DESC,
+				CASE WHEN l.mode = 'size' THEN CHAR_LENGTH(u.code) END ASC,
+				u.submitted_at IS NULL,
+				u.submitted_at ASC
+		) AS match_rank
+		FROM lobby_user u
+		JOIN lobby l ON l.id = u.lobby_id
+		WHERE ` + condition + `
+	) ranked ON ranked.id = lu.id
+	SET lu.match_rank = ranked.match_rank;`
+
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// lobbyWinCondition is true for the winners (lu) of a ranked lobby,
+// a player needs to pass at least one test to be counted as a winner
+const lobbyWinCondition = "lu.match_rank = 1 AND lu.tests_passed > 0"
+
+// lobbyChallengeJoin joins the challenge (ch) of the lobby (l) and the revision (cr) the lobby was played on
+const lobbyChallengeJoin = "JOIN challenge ch ON ch.id = l.challenge_id LEFT JOIN challenge_revision cr ON cr.id = l.challenge_revision_id"
+
+// lobbySolvedCondition is true for the players (lu) that passed every test of the revision played in their lobby,
+// the lobbies without a pinned revision (cr) fall back to the current tests of the challenge (ch)
+const lobbySolvedCondition = "lu.tests_passed > 0 AND lu.tests_passed >= COALESCE(JSON_LENGTH(COALESCE(cr.tests, ch.tests)), 0) + COALESCE(JSON_LENGTH(COALESCE(cr.tests_hidden, ch.tests_hidden)), 0)"
+
+// statsConditions tells which players of a lobby, once ranked by rankLobbyUsers, score each stat
+var statsConditions = map[string]string{
+	types.StatGames: "TRUE",
+	types.StatWins:  lobbyWinCondition,
+	types.StatTop3:  "lu.match_rank <= 3 AND lu.tests_passed > 0",
+}
+
+// incrementLobbyStats adds the result of an ended lobby, ranked or not, to the stats of its players
+func incrementLobbyStats(tx *sql.Tx, lobbyId int) error {
+	for stat, condition := range statsConditions {
+		query := `INSERT INTO user_stats (user_id, stats_id, stat)
+		SELECT lu.user_id, s.id, 1
+		FROM lobby_user lu
+		JOIN stats s ON s.name = ?
+		WHERE lu.lobby_id = ? AND ` + condition + `
+		ON DUPLICATE KEY UPDATE stat = stat + 1;`
+
+		if _, err := tx.Exec(query, stat, lobbyId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RebuildUserStats recomputes ranks and stats of every user from the history of the ended lobbies
+func (m *MariaDB) RebuildUserStats() error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := rankLobbyUsers(tx, "l.ended = TRUE"); err != nil {
+		return fmt.Errorf("DB(RebuildUserStats): %s", err.Error())
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_stats;`); err != nil {
+		return fmt.Errorf("DB(RebuildUserStats): %s", err.Error())
+	}
+
+	for stat, condition := range statsConditions {
+		query := `INSERT INTO user_stats (user_id, stats_id, stat)
+		SELECT lu.user_id, s.id, COUNT(*)
+		FROM lobby_user lu
+		JOIN lobby l ON l.id = lu.lobby_id AND l.ended = TRUE
+		JOIN stats s ON s.name = ?
+		WHERE ` + condition + `
+		GROUP BY lu.user_id, s.id;`
+
+		if _, err := tx.Exec(query, stat); err != nil {
+			return fmt.Errorf("DB(RebuildUserStats): %s", err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// defaultTags seeds the catalogue, moderators can add, rename or remove them afterwards
+var defaultTags = []types.TagRequest{
+	{Slug: "arrays", Name: "Arrays"},
+	{Slug: "strings", Name: "Strings"},
+	{Slug: "math", Name: "Math"},
+	{Slug: "sorting", Name: "Sorting"},
+	{Slug: "greedy", Name: "Greedy"},
+	{Slug: "dp", Name: "Dynamic programming"},
+	{Slug: "graphs", Name: "Graphs"},
+	{Slug: "trees", Name: "Trees"},
+}
+
+// GetTags returns the whole catalogue with the number of challenges of every tag
+func (m *MariaDB) GetTags() ([]*types.Tag, error) {
+	query := `SELECT t.id, t.slug, t.name, COUNT(ct.challenge_id)
+	FROM tag t
+	LEFT JOIN challenge_tag ct ON ct.tag_id = t.id
+	GROUP BY t.id
+	ORDER BY t.name;`
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetTags): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetTags): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	tags := []*types.Tag{}
+	for rows.Next() {
+		tag := &types.Tag{}
+		if err := rows.Scan(&tag.Id, &tag.Slug, &tag.Name, &tag.ChallengeCount); err != nil {
+			return nil, fmt.Errorf("DB(GetTags): %s", err.Error())
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetTags): %s", err.Error())
+	}
+
+	return tags, nil
+}
+
+func (m *MariaDB) CreateTag(tag *types.Tag) error {
+	res, err := m.db.Exec(`INSERT INTO tag (slug, name) VALUES (?, ?);`, tag.Slug, tag.Name)
+	if err != nil {
+		return fmt.Errorf("DB(CreateTag): %s", err.Error())
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	tag.Id = int(id)
+	return nil
+}
+
+// UpdateTag renames the tag identified by `slug`, the challenges keep it
+func (m *MariaDB) UpdateTag(slug string, tag *types.Tag) error {
+	res, err := m.db.Exec(`UPDATE tag SET slug = ?, name = ? WHERE slug = ?;`, tag.Slug, tag.Name, slug)
+	if err != nil {
+		return fmt.Errorf("DB(UpdateTag): %s", err.Error())
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("DB(UpdateTag): tag %q not found", slug)
+	}
+	return nil
+}
+
+// DeleteTag removes the tag from the catalogue and from every challenge
+func (m *MariaDB) DeleteTag(slug string) error {
+	if _, err := m.db.Exec(`DELETE FROM tag WHERE slug = ?;`, slug); err != nil {
+		return fmt.Errorf("DB(DeleteTag): %s", err.Error())
+	}
+	return nil
+}
+
+// SetChallengeTags replaces the tags of the challenge, every slug must exist in the catalogue
+func (m *MariaDB) SetChallengeTags(challengeId int, slugs []string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM challenge_tag WHERE challenge_id = ?;`, challengeId); err != nil {
+		return fmt.Errorf("DB(SetChallengeTags): %s", err.Error())
+	}
+	for _, slug := range slugs {
+		var tagId int
+		err := tx.QueryRow(`SELECT id FROM tag WHERE slug = ?;`, slug).Scan(&tagId)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("DB(SetChallengeTags): tag %q not found", slug)
+		}
+		if err != nil {
+			return fmt.Errorf("DB(SetChallengeTags): %s", err.Error())
+		}
+
+		query := `INSERT IGNORE INTO challenge_tag (challenge_id, tag_id) VALUES (?, ?);`
+		if _, err := tx.Exec(query, challengeId, tagId); err != nil {
+			return fmt.Errorf("DB(SetChallengeTags): %s", err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getChallengesTags returns the tag slugs of the challenges by challenge id
+func (m *MariaDB) getChallengesTags(challengeIds []int) (map[int][]string, error) {
+	tags := map[int][]string{}
+	if len(challengeIds) == 0 {
+		return tags, nil
+	}
+
+	args := make([]any, len(challengeIds))
+	for i, id := range challengeIds {
+		args[i] = id
+		tags[id] = []string{}
+	}
+
+	query := `SELECT ct.challenge_id, t.slug FROM challenge_tag ct
+	JOIN tag t ON t.id = ct.tag_id
+	WHERE ct.challenge_id IN (?` + strings.Repeat(", ?", len(challengeIds)-1) + `)
+	ORDER BY t.slug;`
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(getChallengesTags): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(getChallengesTags): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	for rows.Next() {
+		var challengeId int
+		var slug string
+		if err := rows.Scan(&challengeId, &slug); err != nil {
+			return nil, fmt.Errorf("DB(getChallengesTags): %s", err.Error())
+		}
+		tags[challengeId] = append(tags[challengeId], slug)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(getChallengesTags): %s", err.Error())
+	}
+
+	return tags, nil
+}
+
+func (m *MariaDB) attachChallengeTags(challenges []types.Challenge) error {
+	ids := make([]int, len(challenges))
+	for i, challenge := range challenges {
+		ids[i] = challenge.Id
+	}
+
+	tags, err := m.getChallengesTags(ids)
+	if err != nil {
+		return err
+	}
+	for i := range challenges {
+		challenges[i].Tags = tags[challenges[i].Id]
+	}
+	return nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) createTableTag() error {
+	query := `CREATE TABLE IF NOT EXISTS tag (
+		id INT AUTO_INCREMENT,
+		slug VARCHAR(50) NOT NULL,
+		name VARCHAR(50) NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (id),
+		UNIQUE INDEX (slug)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+func (m *MariaDB) createTableChallengeTag() error {
+	query := `CREATE TABLE IF NOT EXISTS challenge_tag (
+		challenge_id INT NOT NULL,
+		tag_id INT NOT NULL,
+
+		PRIMARY KEY (challenge_id, tag_id),
+		FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tag(id) ON DELETE CASCADE,
+		INDEX (tag_id)
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// seedTags fills the catalogue the first time, tags removed by the moderators are not recreated
+func (m *MariaDB) seedTags() error {
+	var count int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM tag;`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, tag := range defaultTags {
+		if _, err := m.db.Exec(`INSERT IGNORE INTO tag (slug, name) VALUES (?, ?);`, tag.Slug, tag.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
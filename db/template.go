@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// GetLanguages returns the languages the matches can be played in
+func (m *MariaDB) GetLanguages() ([]*types.Language, error) {
+	rows, err := m.db.Query(`SELECT id, name FROM language ORDER BY name;`)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetLanguages): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetLanguages): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	languages := []*types.Language{}
+	for rows.Next() {
+		language := &types.Language{}
+		if err := rows.Scan(&language.Id, &language.Name); err != nil {
+			return nil, fmt.Errorf("DB(GetLanguages): %s", err.Error())
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetLanguages): %s", err.Error())
+	}
+
+	return languages, nil
+}
+
+// GetChallengeTemplates returns the templates of the challenge by language name
+func (m *MariaDB) GetChallengeTemplates(challengeId int) ([]*types.ChallengeTemplate, error) {
+	query := `SELECT l.name, ct.signature, ct.starter_code, ct.updated_at
+	FROM challenge_template ct
+	JOIN language l ON l.id = ct.language_id
+	WHERE ct.challenge_id = ?
+	ORDER BY l.name;`
+	rows, err := m.db.Query(query, challengeId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTemplates): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetChallengeTemplates): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	templates := []*types.ChallengeTemplate{}
+	for rows.Next() {
+		template := &types.ChallengeTemplate{}
+		if err := rows.Scan(&template.Language, &template.Signature, &template.StarterCode, &template.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("DB(GetChallengeTemplates): %s", err.Error())
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTemplates): %s", err.Error())
+	}
+
+	return templates, nil
+}
+
+// GetChallengeTemplate returns the template of the challenge in the language, nil when the author didn't write one
+func (m *MariaDB) GetChallengeTemplate(challengeId int, language string) (*types.ChallengeTemplate, error) {
+	query := `SELECT l.name, ct.signature, ct.starter_code, ct.updated_at
+	FROM challenge_template ct
+	JOIN language l ON l.id = ct.language_id
+	WHERE ct.challenge_id = ? AND l.name = ?;`
+
+	template := &types.ChallengeTemplate{}
+	err := m.db.QueryRow(query, challengeId, language).Scan(&template.Language, &template.Signature, &template.StarterCode, &template.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetChallengeTemplate): %s", err.Error())
+	}
+	return template, nil
+}
+
+// SetChallengeTemplate creates or replaces the template of the challenge in the language, which must be in the language table.
+// Like the other edits made by `authorId`, a changed template is saved as a new revision and sends a reviewed challenge back to draft,
+// an unchanged one is left as it is.
+func (m *MariaDB) SetChallengeTemplate(challengeId, authorId int, template *types.ChallengeTemplate) error {
+	_, err := m.reviseChallenge(challengeId, authorId, nil, func(_ *sql.Tx, snapshot *challengeSnapshot) error {
+		snapshot.templates = slices.DeleteFunc(snapshot.templates, func(t types.ChallengeTemplate) bool { return t.Language == template.Language })
+		snapshot.templates = append(snapshot.templates, *template)
+		return nil
+	})
+	return err
+}
+
+// DeleteChallengeTemplate removes the template of the language as a new revision, a reviewed challenge goes back to draft if there was one
+func (m *MariaDB) DeleteChallengeTemplate(challengeId, authorId int, language string) error {
+	_, err := m.reviseChallenge(challengeId, authorId, nil, func(_ *sql.Tx, snapshot *challengeSnapshot) error {
+		snapshot.templates = slices.DeleteFunc(snapshot.templates, func(t types.ChallengeTemplate) bool { return t.Language == language })
+		return nil
+	})
+	return err
+}
+
+// selectSnapshotTemplates returns the current templates of the challenge as a revision keeps them
+func selectSnapshotTemplates(tx *sql.Tx, challengeId int) ([]types.ChallengeTemplate, error) {
+	query := `SELECT l.name, ct.signature, ct.starter_code
+	FROM challenge_template ct
+	JOIN language l ON l.id = ct.language_id
+	WHERE ct.challenge_id = ?
+	ORDER BY l.name;`
+	rows, err := tx.Query(query, challengeId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(selectSnapshotTemplates): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(selectSnapshotTemplates): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	templates := []types.ChallengeTemplate{}
+	for rows.Next() {
+		template := types.ChallengeTemplate{}
+		if err := rows.Scan(&template.Language, &template.Signature, &template.StarterCode); err != nil {
+			return nil, fmt.Errorf("DB(selectSnapshotTemplates): %s", err.Error())
+		}
+		templates = append(templates, template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(selectSnapshotTemplates): %s", err.Error())
+	}
+
+	return templates, nil
+}
+
+// snapshotTemplates sorts the templates by language and drops their updated_at, so equal templates compare equal
+func snapshotTemplates(templates []types.ChallengeTemplate) []types.ChallengeTemplate {
+	snapshot := []types.ChallengeTemplate{}
+	for _, template := range templates {
+		template.UpdatedAt = ""
+		snapshot = append(snapshot, template)
+	}
+	slices.SortFunc(snapshot, func(a, b types.ChallengeTemplate) int { return strings.Compare(a.Language, b.Language) })
+	return snapshot
+}
+
+// saveChallengeTemplates writes the templates that changed from `before` to `after` and deletes the ones missing from `after`
+func saveChallengeTemplates(tx *sql.Tx, challengeId int, before, after []types.ChallengeTemplate) error {
+	for _, template := range after {
+		if slices.Contains(before, template) {
+			continue
+		}
+		query := `INSERT INTO challenge_template (challenge_id, language_id, signature, starter_code)
+		SELECT ?, id, ?, ? FROM language WHERE name = ?
+		ON DUPLICATE KEY UPDATE signature = VALUES(signature), starter_code = VALUES(starter_code), updated_at = CURRENT_TIMESTAMP;`
+		res, err := tx.Exec(query, challengeId, template.Signature, template.StarterCode, template.Language)
+		if err != nil {
+			return fmt.Errorf("DB(saveChallengeTemplates): %s", err.Error())
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("DB(saveChallengeTemplates): %s", err.Error())
+		}
+		if affected == 0 {
+			return fmt.Errorf("DB(saveChallengeTemplates): language %q not found", template.Language)
+		}
+	}
+
+	for _, template := range before {
+		if slices.ContainsFunc(after, func(t types.ChallengeTemplate) bool { return t.Language == template.Language }) {
+			continue
+		}
+		query := `DELETE ct FROM challenge_template ct
+		JOIN language l ON l.id = ct.language_id
+		WHERE ct.challenge_id = ? AND l.name = ?;`
+		if _, err := tx.Exec(query, challengeId, template.Language); err != nil {
+			return fmt.Errorf("DB(saveChallengeTemplates): %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// -- Init Tables --
+func (m *MariaDB) createTableChallengeTemplate() error {
+	query := `CREATE TABLE IF NOT EXISTS challenge_template (
+		challenge_id INT NOT NULL,
+		language_id INT NOT NULL,
+		signature VARCHAR(255) NOT NULL DEFAULT '',
+		starter_code TEXT NOT NULL,
+
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (challenge_id, language_id),
+		FOREIGN KEY (challenge_id) REFERENCES challenge(id) ON DELETE CASCADE,
+		FOREIGN KEY (language_id) REFERENCES language(id) ON DELETE CASCADE
+	);`
+	_, err := m.db.Exec(query)
+	return err
+}
+
+// migrateChallengeRevisionTemplates adds the templates to the revisions of the databases created before they were versioned.
+// Until then every lobby was played with the current templates, so every revision gets them.
+func (m *MariaDB) migrateChallengeRevisionTemplates() error {
+	exists, err := m.hasColumn("challenge_revision", "templates")
+	if err != nil || exists {
+		return err
+	}
+
+	queries := []string{
+		`ALTER TABLE challenge_revision ADD COLUMN IF NOT EXISTS templates JSON NOT NULL DEFAULT '[]' AFTER tests_hidden;`,
+		`UPDATE challenge_revision r SET templates = COALESCE((
+			SELECT JSON_ARRAYAGG(JSON_OBJECT('language', l.name, 'signature', ct.signature, 'starter_code', ct.starter_code) ORDER BY l.name)
+			FROM challenge_template ct
+			JOIN language l ON l.id = ct.language_id
+			WHERE ct.challenge_id = r.challenge_id
+		), '[]');`,
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
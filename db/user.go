@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/xedom/codeduel/types"
@@ -85,39 +86,116 @@ func (m *MariaDB) GetAuthByProviderAndID(provider, providerID string) (*types.Au
 	return auth, nil
 }
 
-func (m *MariaDB) GetUsers() ([]*types.UserResponse, error) {
-	query := `SELECT user.name, username, avatar, background_img, bio, role, created_at FROM user;`
-	rows, err := m.db.Query(query)
+func (m *MariaDB) GetUsers(listQuery *types.UserListQuery) (*types.UserListResponse, error) {
+	cursor, err := utils.DecodeCursor(listQuery.Cursor)
 	if err != nil {
 		return nil, err
 	}
+
+	args := []any{}
+	sortValue := "u.id"
+	switch listQuery.Sort {
+	case "", types.UserSortNewest:
+	case types.UserSortGames:
+		sortValue = "(SELECT COUNT(*) FROM lobby_user lu JOIN lobby l ON l.id = lu.lobby_id WHERE lu.user_id = u.id AND l.ended = TRUE)"
+	case types.UserSortRating:
+		// unrated players come last
+		sortValue = "COALESCE((SELECT r.rating FROM user_rating r JOIN mode mo ON mo.id = r.mode_id WHERE r.user_id = u.id AND mo.name = ?), 0)"
+		args = append(args, listQuery.Mode)
+	default:
+		return nil, fmt.Errorf("DB(GetUsers): unsupported sort %q", listQuery.Sort)
+	}
+
+	conditions := []string{"role != ?"}
+	args = append(args, types.UserRoleDeleted)
+	if listQuery.Search != "" {
+		// prefix/substring match on username and name, plus a phonetic match on the username
+		pattern := "%" + escapeLike(listQuery.Search) + "%"
+		conditions = append(conditions, "(username LIKE ? OR name LIKE ? OR SOUNDEX(username) = SOUNDEX(?))")
+		args = append(args, pattern, pattern, listQuery.Search)
+	}
+	if listQuery.ViewerId != 0 {
+		// users that blocked the viewer are hidden from their search
+		conditions = append(conditions, "id NOT IN (SELECT blocker_id FROM user_block WHERE blocked_id = ?)")
+		args = append(args, listQuery.ViewerId)
+	}
+	if cursor != nil {
+		conditions = append(conditions, "(sort_value < ? OR (sort_value = ? AND id < ?))")
+		args = append(args, cursor.Value, cursor.Value, cursor.Id)
+	}
+	args = append(args, listQuery.Limit+1)
+
+	query := `SELECT id, sort_value FROM (
+		SELECT u.id, u.name, u.username, u.role, ` + sortValue + ` AS sort_value FROM user u
+	) t
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY sort_value DESC, id DESC
+	LIMIT ?;`
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUsers): %s", err.Error())
+	}
 	defer func() {
 		if err := rows.Close(); err != nil {
 			log.Printf("%s DB(GetUsers): %s", utils.GetLogTag("DB"), err)
 		}
 	}()
 
-	var users []*types.UserResponse
+	page := []*utils.Cursor{}
 	for rows.Next() {
-		user := &types.UserResponse{}
-		user_avatar := sql.NullString{}
-		if err := rows.Scan(&user.Name, &user.Username, &user.Avatar, &user.BackgroundImg, &user.Bio, &user.Role, &user.CreatedAt); err != nil {
-			return nil, err
-		}
-		if user_avatar.Valid {
-			user.Avatar = user_avatar.String
+		position := &utils.Cursor{}
+		if err := rows.Scan(&position.Id, &position.Value); err != nil {
+			return nil, fmt.Errorf("DB(GetUsers): %s", err.Error())
 		}
-		users = append(users, user)
+		page = append(page, position)
 	}
 	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUsers): %s", err.Error())
+	}
+
+	response := &types.UserListResponse{}
+	if len(page) > listQuery.Limit {
+		page = page[:listQuery.Limit]
+		response.NextCursor = utils.EncodeCursor(page[len(page)-1])
+	}
+
+	ids := make([]int, len(page))
+	for i, position := range page {
+		ids[i] = position.Id
+	}
+	if response.Users, err = m.getUsersByIDsOrdered(ids); err != nil {
 		return nil, err
 	}
 
+	return response, nil
+}
+
+func (m *MariaDB) GetUsersByIDs(ids []int) ([]*types.UserResponse, error) {
+	if len(ids) == 0 {
+		return []*types.UserResponse{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]any, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, types.UserRoleDeleted)
+
+	// the deleted accounts and the tombstone aren't listed, as in GetUsers
+	query := `SELECT ` + userResponseColumns + ` FROM user u WHERE u.id IN (` + placeholders + `) AND u.role != ?;`
+
+	users, err := m.queryUserResponses(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUsersByIDs): %s", err.Error())
+	}
+
 	return users, nil
 }
 
 func (m *MariaDB) GetUserByID(id int) (*types.User, error) {
-	query := `SELECT * FROM user WHERE id = ?;`
+	query := `SELECT ` + userColumns + ` FROM user WHERE id = ?;`
 	rows, err := m.db.Query(query, id)
 	if err != nil {
 		return nil, fmt.Errorf("DB(GetUserByID): %s", err.Error())
@@ -140,7 +218,7 @@ func (m *MariaDB) GetUserByID(id int) (*types.User, error) {
 }
 
 func (m *MariaDB) GetUserByUsername(username string) (*types.User, error) {
-	query := `SELECT * FROM user WHERE username = ?;`
+	query := `SELECT ` + userColumns + ` FROM user WHERE username = ?;`
 	rows, err := m.db.Query(query, username)
 	if err != nil {
 		return nil, fmt.Errorf("DB(GetUserByUsername): %s", err.Error())
@@ -225,8 +303,9 @@ func (m *MariaDB) CreateUser(user *types.User) error {
 }
 
 func (m *MariaDB) UpdateUser(user *types.User) error {
-	query := `UPDATE user SET username = ?, email = ?, avatar = ? WHERE id = ?;`
-	res, err := m.db.Exec(query, user.Username, user.Email, user.Avatar, user.Id)
+	// a new address has to be verified again
+	query := `UPDATE user SET email_verified = (email_verified AND email = ?), username = ?, email = ?, avatar = ? WHERE id = ?;`
+	res, err := m.db.Exec(query, user.Email, user.Username, user.Email, user.Avatar, user.Id)
 	if err != nil {
 		return err
 	}
@@ -237,42 +316,86 @@ func (m *MariaDB) UpdateUser(user *types.User) error {
 	return err
 }
 
+// DeleteUser deletes the account of the user in a single transaction: sessions and linked
+// data are removed, authored challenges and owned lobbies move to the tombstone user and the
+// personal fields are wiped. The user row itself is kept, anonymized, because the match
+// history (lobby_user) is unique per lobby and can't be merged into a single user.
 func (m *MariaDB) DeleteUser(id int) error {
-	query := `DELETE FROM user WHERE id = ?;`
-	res, err := m.db.Exec(query, id)
-
+	tx, err := m.db.Begin()
 	if err != nil {
 		return err
 	}
-	if rows, _ := res.RowsAffected(); rows == 0 {
-		return fmt.Errorf("user with id %d not found", id)
-	}
-
-	return err
-}
-
-func (m *MariaDB) DeleteUserByUsername(username string) error {
-	query := `DELETE FROM user WHERE username = ?;`
-	res, err := m.db.Exec(query, username)
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	if err != nil {
-		return err
-	}
-	if rows, _ := res.RowsAffected(); rows == 0 {
-		return fmt.Errorf("DB(DeleteUserByUsername): user with id %s not found", username)
+	var tombstoneId int
+	if err := tx.QueryRow(`SELECT id FROM user WHERE username = ?;`, types.TombstoneUsername).Scan(&tombstoneId); err != nil {
+		return fmt.Errorf("DB(DeleteUser): tombstone user not found: %s", err.Error())
+	}
+	if id == tombstoneId {
+		return fmt.Errorf("DB(DeleteUser): the tombstone user can't be deleted")
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`DELETE FROM refresh_token WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM auth WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_stats WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_follow WHERE follower_id = ? OR followee_id = ?;`, []any{id, id}},
+		{`DELETE FROM user_block WHERE blocker_id = ? OR blocked_id = ?;`, []any{id, id}},
+		{`DELETE FROM user_export WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_rating WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_rating_history WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM leaderboard_entry WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_achievement WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM notification WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM notification_preference WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_preferences WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM daily_solve WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM user_streak WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM email_token WHERE user_id = ?;`, []any{id}},
+		{`DELETE FROM email_change WHERE user_id = ?;`, []any{id}},
+		{"UPDATE `challenge` c" + ` SET
+			rating = COALESCE((SELECT AVG(r.rating) FROM challenge_rating r WHERE r.challenge_id = c.id AND r.user_id <> ?), 0),
+			rating_count = (SELECT COUNT(*) FROM challenge_rating r WHERE r.challenge_id = c.id AND r.user_id <> ?)
+		WHERE c.id IN (SELECT challenge_id FROM challenge_rating WHERE user_id = ?);`, []any{id, id, id}},
+		{`DELETE FROM challenge_rating WHERE user_id = ?;`, []any{id}},
+		{"UPDATE `challenge` SET owner_id = ? WHERE owner_id = ?;", []any{tombstoneId, id}},
+		{`UPDATE lobby SET owner_id = ? WHERE owner_id = ?;`, []any{tombstoneId, id}},
+		{`UPDATE lobby_user SET code = NULL, show_code = FALSE WHERE user_id = ?;`, []any{id}},
+		{`UPDATE user SET
+			username = CONCAT('deleted-', id), name = '', email = '', email_verified = FALSE, avatar = NULL,
+			background_img = '', bio = '', role = ?
+		WHERE id = ?;`, []any{types.UserRoleDeleted, id}},
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return fmt.Errorf("DB(DeleteUser): %s", err.Error())
+		}
 	}
 
-	return err
+	return tx.Commit()
 }
 
 // -- Init Tables --
 func (m *MariaDB) InitUserTables() []MigrationFunc {
 	return []MigrationFunc{
 		m.createTableUser,
+		m.migrateUserEmailVerified,
 		m.createTableAuth,
 		m.createTableStats,
 		m.createTableUserStats,
+		m.migrateUserStatsTyped,
 		m.createTableRefreshToken,
+		m.createTableUserFollow,
+		m.createTableUserBlock,
+		m.createTableUserExport,
+		m.createTableUserPreferences,
+		m.createTombstoneUser,
 	}
 }
 
@@ -282,6 +405,7 @@ func (m *MariaDB) createTableUser() error {
 		username VARCHAR(50) NOT NULL,
 		name VARCHAR(50) DEFAULT '',
 		email VARCHAR(50) NOT NULL,
+		email_verified BOOLEAN NOT NULL DEFAULT FALSE,
 		avatar VARCHAR(255),
 		background_img VARCHAR(255) DEFAULT '',
 		bio TEXT DEFAULT (''),
@@ -295,6 +419,14 @@ func (m *MariaDB) createTableUser() error {
 	return err
 }
 
+// migrateUserEmailVerified adds the verified flag to the databases created before it,
+// emails copied from GitHub are not considered verified
+func (m *MariaDB) migrateUserEmailVerified() error {
+	query := `ALTER TABLE user ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE AFTER email;`
+	_, err := m.db.Exec(query)
+	return err
+}
+
 func (m *MariaDB) createTableAuth() error {
 	query := `CREATE TABLE IF NOT EXISTS auth (
 		id INT AUTO_INCREMENT,
@@ -316,17 +448,32 @@ func (m *MariaDB) createTableUserStats() error {
 		id INT AUTO_INCREMENT,
 		user_id INT NOT NULL,
 		stats_id INT NOT NULL,
-		stat VARCHAR(50) NOT NULL,
+		stat INT NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		PRIMARY KEY (id),
 		FOREIGN KEY (user_id) REFERENCES user(id),
-		FOREIGN KEY (stats_id) REFERENCES stats(id)
+		FOREIGN KEY (stats_id) REFERENCES stats(id),
+		UNIQUE INDEX user_stat (user_id, stats_id)
 	);`
 	_, err := m.db.Exec(query)
 	return err
 }
 
+// migrateUserStatsTyped turns the stat of the databases created before it was typed into a number
+func (m *MariaDB) migrateUserStatsTyped() error {
+	queries := []string{
+		`ALTER TABLE user_stats MODIFY stat INT NOT NULL DEFAULT 0;`,
+		`ALTER TABLE user_stats ADD UNIQUE INDEX IF NOT EXISTS user_stat (user_id, stats_id);`,
+	}
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MariaDB) createTableStats() error {
 	query := `CREATE TABLE IF NOT EXISTS stats (
 		id INT AUTO_INCREMENT,
@@ -341,7 +488,7 @@ func (m *MariaDB) createTableStats() error {
 		return err
 	}
 
-	defaultStats := []string{"Games", "Wins", "Top 3"}
+	defaultStats := []string{types.StatGames, types.StatWins, types.StatTop3}
 
 	for _, stat := range defaultStats {
 		query := `INSERT IGNORE INTO stats (name) VALUES (?);`
@@ -372,7 +519,80 @@ func (m *MariaDB) createTableRefreshToken() error {
 	return err
 }
 
+// createTombstoneUser creates the user that inherits the challenges and lobbies of deleted accounts
+func (m *MariaDB) createTombstoneUser() error {
+	query := `INSERT IGNORE INTO user (username, name, email, role) VALUES (?, 'Deleted user', '', ?);`
+	_, err := m.db.Exec(query, types.TombstoneUsername, types.UserRoleDeleted)
+	return err
+}
+
 // -- Utils --
+
+// userResponseColumns is the projection of `user u` scanned by queryUserResponses
+const userResponseColumns = `u.id, u.name, u.username, COALESCE(u.avatar, ''), u.background_img, u.bio, u.role,
+	(SELECT COUNT(*) FROM lobby_user lu JOIN lobby l ON l.id = lu.lobby_id WHERE lu.user_id = u.id AND l.ended = TRUE),
+	u.created_at`
+
+func (m *MariaDB) queryUserResponses(query string, args ...any) ([]*types.UserResponse, error) {
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(queryUserResponses): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	users := []*types.UserResponse{}
+	for rows.Next() {
+		user := &types.UserResponse{}
+		if err := rows.Scan(
+			&user.Id,
+			&user.Name,
+			&user.Username,
+			&user.Avatar,
+			&user.BackgroundImg,
+			&user.Bio,
+			&user.Role,
+			&user.GamesPlayed,
+			&user.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// getUsersByIDsOrdered is GetUsersByIDs keeping the order of `ids`
+func (m *MariaDB) getUsersByIDsOrdered(ids []int) ([]*types.UserResponse, error) {
+	users, err := m.GetUsersByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[int]*types.UserResponse, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+
+	ordered := make([]*types.UserResponse, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := byId[id]; ok {
+			ordered = append(ordered, user)
+		}
+	}
+	return ordered, nil
+}
+
+// userColumns are the columns scanned by parseUser
+const userColumns = `id, username, name, email, email_verified, avatar, background_img, bio, role, created_at, updated_at`
+
 func (m *MariaDB) parseUser(row *sql.Rows) (*types.User, error) {
 	user := &types.User{}
 	user_avatar := sql.NullString{}
@@ -381,6 +601,7 @@ func (m *MariaDB) parseUser(row *sql.Rows) (*types.User, error) {
 		&user.Username,
 		&user.Name,
 		&user.Email,
+		&user.EmailVerified,
 		&user.Avatar,
 		&user.BackgroundImg,
 		&user.Bio,
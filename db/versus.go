@@ -0,0 +1,128 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/xedom/codeduel/types"
+	"github.com/xedom/codeduel/utils"
+)
+
+// GetVersusMatches returns the ended lobbies both users played in, newest first,
+// from the point of view of `userId`
+func (m *MariaDB) GetVersusMatches(userId, opponentId int) ([]*types.VersusMatch, error) {
+	query := `SELECT
+		l.uuid, l.mode, l.challenge_id, l.created_at,
+		a.match_rank, b.match_rank, a.tests_passed, b.tests_passed
+	FROM lobby l
+	JOIN lobby_user a ON a.lobby_id = l.id AND a.user_id = ?
+	JOIN lobby_user b ON b.lobby_id = l.id AND b.user_id = ?
+	WHERE l.ended = TRUE
+	ORDER BY l.created_at DESC, l.id DESC;`
+
+	rows, err := m.db.Query(query, userId, opponentId)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetVersusMatches): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetVersusMatches): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	matches := []*types.VersusMatch{}
+	for rows.Next() {
+		match := &types.VersusMatch{}
+		if err := rows.Scan(
+			&match.LobbyUniqueId,
+			&match.Mode,
+			&match.ChallengeId,
+			&match.PlayedAt,
+			&match.Rank,
+			&match.OpponentRank,
+			&match.TestsPassed,
+			&match.OpponentTestsPassed,
+		); err != nil {
+			return nil, fmt.Errorf("DB(GetVersusMatches): %s", err.Error())
+		}
+		match.Result = versusResult(match.Rank, match.OpponentRank)
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetVersusMatches): %s", err.Error())
+	}
+
+	return matches, nil
+}
+
+// GetUserRivals returns the opponents the user played the most ended lobbies with
+func (m *MariaDB) GetUserRivals(userId int, limit int) ([]*types.Rival, error) {
+	query := `SELECT
+		b.user_id,
+		COUNT(*) AS matches,
+		COALESCE(SUM(a.match_rank < b.match_rank), 0),
+		COALESCE(SUM(a.match_rank > b.match_rank), 0)
+	FROM lobby_user a
+	JOIN lobby l ON l.id = a.lobby_id AND l.ended = TRUE
+	JOIN lobby_user b ON b.lobby_id = a.lobby_id AND b.user_id <> a.user_id
+	JOIN user u ON u.id = b.user_id AND u.role <> ?
+	WHERE a.user_id = ?
+	GROUP BY b.user_id
+	ORDER BY matches DESC, b.user_id
+	LIMIT ?;`
+
+	rows, err := m.db.Query(query, types.UserRoleDeleted, userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DB(GetUserRivals): %s", err.Error())
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("%s DB(GetUserRivals): %s", utils.GetLogTag("DB"), err)
+		}
+	}()
+
+	rivals := []*types.Rival{}
+	rivalsById := map[int]*types.Rival{}
+	ids := []int{}
+	for rows.Next() {
+		var rivalId int
+		rival := &types.Rival{}
+		if err := rows.Scan(&rivalId, &rival.Matches, &rival.Wins, &rival.Losses); err != nil {
+			return nil, fmt.Errorf("DB(GetUserRivals): %s", err.Error())
+		}
+		rivals = append(rivals, rival)
+		rivalsById[rivalId] = rival
+		ids = append(ids, rivalId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB(GetUserRivals): %s", err.Error())
+	}
+
+	users, err := m.GetUsersByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		rivalsById[user.Id].User = user
+	}
+
+	return rivals, nil
+}
+
+// versusResult compares the ranks of a match, a player without a rank never submitted and ranks last
+func versusResult(rank, opponentRank *int) string {
+	switch {
+	case rank == nil && opponentRank == nil:
+		return types.VersusTie
+	case opponentRank == nil:
+		return types.VersusWin
+	case rank == nil:
+		return types.VersusLoss
+	case *rank < *opponentRank:
+		return types.VersusWin
+	case *rank > *opponentRank:
+		return types.VersusLoss
+	default:
+		return types.VersusTie
+	}
+}
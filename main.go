@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/xedom/codeduel/api"
 	"github.com/xedom/codeduel/db"
@@ -28,10 +29,27 @@ func main() {
 		mariaDB.InitUserTables(),
 		mariaDB.InitLobbyTables(),
 		mariaDB.InitChallengeTables(),
+		mariaDB.InitRatingTables(),
+		mariaDB.InitLeaderboardTables(),
+		mariaDB.InitAchievementTables(),
+		mariaDB.InitNotificationTables(),
+		mariaDB.InitDailyTables(),
+		mariaDB.InitEmailTables(),
 	); err != nil {
 		log.Printf("%s%s Error migrating DB user tables: %v", utils.GetLogTag("DB"), utils.GetLogTag("error"), err.Error())
 	}
 
+	if len(os.Args) > 1 {
+		err := runCommand(mariaDB, os.Args[1:])
+		_ = mariaDB.Close()
+		if err != nil {
+			// scripts and CI rely on the exit status to notice the failure
+			log.Printf("%s%s %v", utils.GetLogTag("cmd"), utils.GetLogTag("error"), err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	server := api.NewAPIServer(loadConfig, mariaDB)
 	err = server.Run()
 	if err != nil {
@@ -0,0 +1,50 @@
+package types
+
+const (
+	AchievementMetricGames              = "games"
+	AchievementMetricWins               = "wins"
+	AchievementMetricSolves             = "solves"
+	AchievementMetricFastSolves         = "fast_solves"
+	AchievementMetricLanguagesWon       = "languages_won"
+	AchievementMetricAuthoredChallenges = "authored_challenges"
+	AchievementMetricAuthoredPlays      = "authored_plays"
+)
+
+// AchievementRule unlocks the achievement once the metric of the user reaches the threshold
+type AchievementRule struct {
+	Metric    string `json:"metric"`
+	Threshold int    `json:"threshold"`
+
+	// optional filters, not every metric supports them
+	Mode       string `json:"mode,omitempty"`
+	Language   string `json:"language,omitempty"`
+	MaxSeconds int    `json:"max_seconds,omitempty"` // fast_solves only
+}
+
+type Achievement struct {
+	Id          int             `json:"id"`
+	Slug        string          `json:"slug"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Icon        string          `json:"icon"`
+	Rule        AchievementRule `json:"rule"`
+
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type UserAchievement struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	UnlockedAt  string `json:"unlocked_at"`
+}
+
+type AchievementRequest struct {
+	Slug        string          `json:"slug"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Icon        string          `json:"icon"`
+	Rule        AchievementRule `json:"rule"`
+}
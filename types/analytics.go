@@ -0,0 +1,22 @@
+package types
+
+type ActivityDay struct {
+	Date    string `json:"date"` // YYYY-MM-DD, UTC
+	Matches int    `json:"matches"`
+}
+
+// ActivityResponse is the calendar of the matches played each day, days without matches included
+type ActivityResponse struct {
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Total int            `json:"total"`
+	Days  []*ActivityDay `json:"days"`
+}
+
+type LanguageStats struct {
+	Language       string  `json:"language"`
+	Matches        int     `json:"matches"`
+	Wins           int     `json:"wins"`
+	WinRate        float64 `json:"win_rate"` // 0 to 1
+	AvgTestsPassed float64 `json:"avg_tests_passed"`
+}
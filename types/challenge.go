@@ -1,5 +1,7 @@
 package types
 
+import "fmt"
+
 type Challenge struct {
 	Id      int `json:"id"`
 	OwnerId int `json:"owner_id"` // User.ID
@@ -8,18 +10,98 @@ type Challenge struct {
 	Description string `json:"description"`
 	Content     string `json:"content"` // markdown maybe the link to the file
 
-	TestCases []TestCase `json:"testCases"`
+	Difficulty         string   `json:"difficulty"`          // set by the author
+	ComputedDifficulty *string  `json:"computed_difficulty"` // from the solve rate, nil until enough matches were played
+	Tags               []string `json:"tags"`                // slugs
+	Status             string   `json:"status"`
+
+	TestCases       []TestCase `json:"testCases"`
+	HiddenTestCases []TestCase `json:"-"` // only written on creation, never returned
+
+	Templates []ChallengeTemplate `json:"-"` // only written, replaced on update when not nil
 
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
 
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+func IsChallengeDifficulty(difficulty string) bool {
+	return difficulty == DifficultyEasy || difficulty == DifficultyMedium || difficulty == DifficultyHard
+}
+
+// ChallengeFilter restricts the challenges listed or picked at random, zero values match everything
+type ChallengeFilter struct {
+	Tags       []string // the challenge must have every tag
+	Difficulty string   // computed difficulty, the author's one until enough matches were played
+	Status     string
+	OwnerId    int
+}
+
+const (
+	TestCaseVisibilityPublic = "public"
+	TestCaseVisibilityHidden = "hidden"
+)
+
+func IsTestCaseVisibility(visibility string) bool {
+	return visibility == TestCaseVisibilityPublic || visibility == TestCaseVisibilityHidden
+}
+
+const (
+	MaxChallengeTitleLength       = 50
+	MaxChallengeDescriptionLength = 255
+	MaxChallengeTags              = 5
+
+	MaxTestCases          = 100
+	MaxTestCaseNameLength = 50
+	MaxTestCaseFieldSize  = 64 << 10 // bytes of a single input or output
+	MaxTestCasesSize      = 1 << 20  // bytes of a whole list
+)
+
 type TestCase struct {
 	Name   string `json:"name"`
 	Input  string `json:"input"`
 	Output string `json:"output"`
 }
 
+// ChallengeTestCases holds both test lists, the hidden ones are left out
+// unless the viewer owns the challenge, is an admin or is the lobby service
+type ChallengeTestCases struct {
+	TestCases       []TestCase `json:"testCases"`
+	HiddenTestCases []TestCase `json:"hiddenTestCases,omitempty"`
+}
+
+// ValidateTestCases checks a list of tests of the same visibility against the limits
+func ValidateTestCases(testCases []TestCase) error {
+	if len(testCases) > MaxTestCases {
+		return fmt.Errorf("a challenge can't have more than %d tests of the same visibility", MaxTestCases)
+	}
+
+	size := 0
+	for i, testCase := range testCases {
+		if len(testCase.Name) > MaxTestCaseNameLength {
+			return fmt.Errorf("test %d: name can't be longer than %d characters", i, MaxTestCaseNameLength)
+		}
+		if len(testCase.Input) > MaxTestCaseFieldSize || len(testCase.Output) > MaxTestCaseFieldSize {
+			return fmt.Errorf("test %d: input and output can't be larger than %d bytes", i, MaxTestCaseFieldSize)
+		}
+		size += len(testCase.Name) + len(testCase.Input) + len(testCase.Output)
+	}
+	if size > MaxTestCasesSize {
+		return fmt.Errorf("tests can't be larger than %d bytes in total", MaxTestCasesSize)
+	}
+
+	return nil
+}
+
+type ReorderTestCasesRequest struct {
+	Order []int `json:"order"` // current indexes in their new order
+}
+
 type ChallengeFull struct {
 	Id    int `json:"id"`
 	Owner struct {
@@ -32,8 +114,20 @@ type ChallengeFull struct {
 	Description string `json:"description"`
 	Content     string `json:"content"` // markdown maybe the link to the file
 
+	Difficulty         string   `json:"difficulty"`
+	ComputedDifficulty *string  `json:"computed_difficulty"`
+	Tags               []string `json:"tags"`
+	Status             string   `json:"status"`
+
 	TestCases       []TestCase `json:"testCases"`
-	HiddenTestCases []TestCase `json:"hiddenTestCases"`
+	HiddenTestCases []TestCase `json:"hiddenTestCases,omitempty"`
+
+	// template of the language requested with `?language=`, left out when the author didn't write one
+	Template *ChallengeTemplate `json:"template,omitempty"`
+
+	// current revision, lobbies created with this challenge are pinned to it
+	Revision   int `json:"revision"`
+	RevisionId int `json:"revision_id"`
 
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
@@ -43,6 +137,10 @@ type CreateChallengeRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Content     string `json:"content"`
+	Difficulty  string `json:"difficulty"` // medium when empty
+
+	TestCases       []TestCase `json:"testCases"`
+	HiddenTestCases []TestCase `json:"hiddenTestCases"`
 }
 
 type UpdateChallengeRequest struct {
@@ -50,6 +148,11 @@ type UpdateChallengeRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Content     string `json:"content"`
+	Difficulty  string `json:"difficulty"` // unchanged when empty
+
+	// nil leaves the tests untouched
+	TestCases       *[]TestCase `json:"testCases"`
+	HiddenTestCases *[]TestCase `json:"hiddenTestCases"`
 }
 
 type ChallengeResponse struct {
@@ -63,6 +166,66 @@ type ChallengeResponse struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
+const (
+	ChallengeSortNewest       = "newest"
+	ChallengeSortMostPlayed   = "most_played"
+	ChallengeSortHighestRated = "highest_rated"
+)
+
+func IsChallengeSort(sort string) bool {
+	return sort == ChallengeSortNewest || sort == ChallengeSortMostPlayed || sort == ChallengeSortHighestRated
+}
+
+type ChallengeListQuery struct {
+	Filter ChallengeFilter
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+// ChallengeSummary is the projection of a challenge shown when browsing, without content and tests
+type ChallengeSummary struct {
+	Id    int `json:"id"`
+	Owner struct {
+		Id       int    `json:"id"`
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Avatar   string `json:"avatar"`
+	} `json:"owner"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	Difficulty         string   `json:"difficulty"`
+	ComputedDifficulty *string  `json:"computed_difficulty"`
+	Tags               []string `json:"tags"`
+
+	Status          string `json:"status"`
+	StatusUpdatedAt string `json:"status_updated_at"`
+
+	PlayCount   int      `json:"play_count"`   // ended lobbies
+	Rating      *float64 `json:"rating"`       // average of the players' ratings, nil until rated
+	RatingCount int      `json:"rating_count"` // number of ratings
+
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
 type ChallengeListResponse struct {
-	Challenges []ChallengeResponse `json:"challenges"`
+	Challenges []*ChallengeSummary `json:"challenges"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+const (
+	MinChallengeRating = 1
+	MaxChallengeRating = 5
+)
+
+type RateChallengeRequest struct {
+	Rating int `json:"rating"` // from 1 to 5
+}
+
+type ChallengeRating struct {
+	Rating      *float64 `json:"rating"` // average, nil until rated
+	RatingCount int      `json:"rating_count"`
+	UserRating  int      `json:"user_rating"` // rating given by the user, 0 if none
 }
@@ -0,0 +1,31 @@
+package types
+
+// DailyChallenge is the challenge published for a UTC day
+type DailyChallenge struct {
+	Date        string `json:"date"` // YYYY-MM-DD, UTC
+	ChallengeId int    `json:"challenge_id"`
+	Overridden  bool   `json:"overridden"` // chosen by an admin instead of the automatic selection
+}
+
+type DailyChallengeResponse struct {
+	Date     string `json:"date"`
+	StartsAt string `json:"starts_at"` // RFC 3339, UTC
+	EndsAt   string `json:"ends_at"`
+
+	Challenge    *Challenge `json:"challenge"`
+	SolversCount int        `json:"solvers_count"`
+
+	// only when authenticated
+	Solved *bool       `json:"solved,omitempty"`
+	Streak *UserStreak `json:"streak,omitempty"`
+}
+
+type UserStreak struct {
+	Current        int     `json:"current"`
+	Longest        int     `json:"longest"`
+	LastSolvedDate *string `json:"last_solved_date"`
+}
+
+type SetDailyChallengeRequest struct {
+	ChallengeId int `json:"challenge_id"`
+}
@@ -0,0 +1,24 @@
+package types
+
+const (
+	EmailTokenVerify    = "verify"     // verifies the current address
+	EmailTokenChangeOld = "change_old" // confirms a change from the current address
+	EmailTokenChangeNew = "change_new" // confirms a change from the new address
+
+	EmailStatusVerified = "verified"
+	EmailStatusPending  = "pending" // the other address of the change still has to confirm
+	EmailStatusChanged  = "changed"
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type ConfirmEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ConfirmEmailResponse struct {
+	Status string `json:"status"`
+	Email  string `json:"email"`
+}
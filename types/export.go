@@ -0,0 +1,64 @@
+package types
+
+import "encoding/json"
+
+const (
+	UserExportPending = "pending"
+	UserExportReady   = "ready"
+	UserExportFailed  = "failed"
+)
+
+type UserExport struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id"`
+	Token       string `json:"-"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	DownloadUrl string `json:"download_url,omitempty"`
+
+	ExpiresAt string `json:"expires_at"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// UserExportData is everything stored about a user, each field becomes a JSON file of the archive
+type UserExportData struct {
+	User        *User                   `json:"user"`
+	Auth        []*AuthEntry            `json:"auth"`
+	Stats       []*UserStatsParsed      `json:"stats"`
+	Challenges  []*UserExportChallenge  `json:"challenges"`
+	Submissions []*UserExportSubmission `json:"submissions"`
+	Sessions    []*Session              `json:"sessions"`
+}
+
+type UserExportChallenge struct {
+	Id          int             `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Content     string          `json:"content"`
+	TestCases   json.RawMessage `json:"testCases"`
+	HiddenTests json.RawMessage `json:"hiddenTestCases"`
+
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type UserExportSubmission struct {
+	LobbyUniqueId string  `json:"lobby_id"`
+	ChallengeId   int     `json:"challenge_id"`
+	Mode          string  `json:"mode"`
+	Code          *string `json:"code"`
+	Language      *string `json:"language"`
+	TestsPassed   int     `json:"tests_passed"`
+	Rank          *int    `json:"rank"`
+	ShowCode      bool    `json:"show_code"`
+	SubmittedAt   *string `json:"submitted_at"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+// Session is a refresh token, without the token itself
+type Session struct {
+	Id        int    `json:"id"`
+	ExpiresAt string `json:"expires_at"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package types
+
+const (
+	LeaderboardMetricRating = "rating"
+	LeaderboardMetricWins   = "wins"
+	LeaderboardMetricSolves = "solves"
+
+	LeaderboardWindowAll     = "all"
+	LeaderboardWindowMonthly = "monthly"
+	LeaderboardWindowWeekly  = "weekly"
+	LeaderboardWindowSeason  = "season"
+)
+
+type LeaderboardQuery struct {
+	Metric   string
+	Mode     string // empty for every mode
+	Language string // empty for every language
+	Window   string
+	SeasonId int
+	ViewerId int  // authenticated user, 0 if anonymous
+	Friends  bool // only the viewer and their friends
+	Cursor   string
+	Limit    int
+}
+
+type LeaderboardEntry struct {
+	Position int           `json:"position"`
+	Score    float64       `json:"score"`
+	User     *UserResponse `json:"user"`
+}
+
+type LeaderboardResponse struct {
+	Entries    []*LeaderboardEntry `json:"entries"`
+	Me         *LeaderboardEntry   `json:"me,omitempty"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	UpdatedAt  string              `json:"updated_at,omitempty"`
+}
+
+type Season struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	StartsAt string `json:"starts_at"`
+	EndsAt   string `json:"ends_at"`
+}
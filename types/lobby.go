@@ -12,7 +12,12 @@ type CreateLobbyRequest struct {
 		MaxPlayers       int      `json:"max_players"`
 		GameDuration     int      `json:"game_duration"`
 		AllowedLanguages []string `json:"allowed_languages"`
+		FriendsOnly      bool     `json:"friends_only"`
+		Ranked           bool     `json:"ranked"`
 	} `json:"settings"`
+
+	// revision the lobby is played on, the current one of the challenge when 0
+	ChallengeRevisionId int `json:"challenge_revision_id"`
 }
 
 type ShareLobbyCodeRequest struct {
@@ -34,6 +39,9 @@ type Lobby struct {
 	OwnerId     int    `json:"owner_id"`
 	UsersId     []int  `json:"users_id"`
 	Ended       bool   `json:"ended"`
+	Ranked      bool   `json:"ranked"`
+
+	ChallengeRevisionId int `json:"challenge_revision_id"` // pinned when the lobby is created
 
 	// Settings
 	Mode             string   `json:"mode"`
@@ -67,14 +75,16 @@ type LobbyUser struct {
 }
 
 type LobbyUserResult struct {
-	Id      int `json:"id"`
-	LobbyId int `json:"lobby_id"`
-	UserId  int `json:"user_id"`
+	Id       int    `json:"id"`
+	LobbyId  int    `json:"lobby_id"`
+	UserId   int    `json:"user_id"`
+	Username string `json:"username"` // links to the profile and to /v1/user/{username}/versus/{opponent}
 
 	Code        *string `json:"code"`
 	Language    *string `json:"language"`
 	TestsPassed int     `json:"tests_passed"`
 	ShowCode    bool    `json:"show_code"`
+	Rank        *int    `json:"rank"`
 
 	SubmittedAt string `json:"submitted_at"`
 	CreatedAt   string `json:"created_at"`
@@ -111,6 +121,7 @@ type SingleMatchResult struct {
 		Language    *string `json:"language"`
 		TestsPassed int     `json:"tests_passed"`
 		ShowCode    bool    `json:"show_code"`
+		Rank        *int    `json:"rank"`
 		SubmittedAt string  `json:"submitted_at"`
 	} `json:"player"`
 }
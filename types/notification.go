@@ -0,0 +1,45 @@
+package types
+
+const (
+	NotificationLobbyInvite       = "lobby_invite"
+	NotificationChallengeApproved = "challenge_approved"
+	NotificationChallengeRejected = "challenge_rejected"
+	NotificationNewFollower       = "new_follower"
+	NotificationMatchResults      = "match_results"
+)
+
+var NotificationTypes = []string{
+	NotificationLobbyInvite,
+	NotificationChallengeApproved,
+	NotificationChallengeRejected,
+	NotificationNewFollower,
+	NotificationMatchResults,
+}
+
+func IsNotificationType(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+type Notification struct {
+	Id     int            `json:"id"`
+	UserId int            `json:"-"`
+	Type   string         `json:"type"`
+	Data   map[string]any `json:"data"` // depends on the type, e.g. lobby_id or username of the follower
+	ReadAt *string        `json:"read_at"`
+
+	CreatedAt string `json:"created_at"`
+}
+
+type NotificationListResponse struct {
+	Notifications []*Notification `json:"notifications"`
+	UnreadCount   int             `json:"unread_count"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
+}
+
+// NotificationPreferences maps each notification type to whether the user wants to receive it
+type NotificationPreferences map[string]bool
@@ -0,0 +1,23 @@
+package types
+
+// fields of a challenge package that aren't tracked by the revisions
+const (
+	PackageFieldDifficulty = "difficulty"
+	PackageFieldTags       = "tags"
+)
+
+// ChallengeImportResult describes what an import of a challenge package changed, or would change on a dry run
+type ChallengeImportResult struct {
+	ChallengeId int              `json:"challenge_id"` // 0 on the dry run of a new challenge
+	Created     bool             `json:"created"`
+	DryRun      bool             `json:"dry_run"`
+	Diff        []RevisionChange `json:"diff"` // from the current challenge, or from an empty one when created
+
+	Conversion *ChallengeConversionReport `json:"conversion,omitempty"` // only for problems of other platforms
+}
+
+// ChallengeConversionReport lists what was lost converting a problem of another platform into a challenge
+type ChallengeConversionReport struct {
+	Format      string   `json:"format"`
+	Unconverted []string `json:"unconverted"` // parts of the problem left out or changed, one per line
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// UserPreferencesVersion is bumped on every breaking change of UserPreferences,
+// stored preferences of older versions are upgraded when read
+const UserPreferencesVersion = 2
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityPrivate   = "private"
+
+	EditorThemeSystem = "system"
+	EditorThemeLight  = "light"
+	EditorThemeDark   = "dark"
+
+	EditorKeybindingsDefault = "default"
+	EditorKeybindingsVim     = "vim"
+	EditorKeybindingsEmacs   = "emacs"
+)
+
+type EditorPreferences struct {
+	Theme       string `json:"theme"`
+	Keybindings string `json:"keybindings"`
+}
+
+// PrivacySettings tell who can see each part of the profile, with the Visibility* constants
+type PrivacySettings struct {
+	Profile string `json:"profile"`
+	Matches string `json:"matches"`
+	Code    string `json:"code"` // submitted code, on top of show_code of each match
+}
+
+type UserPreferences struct {
+	Version            int                     `json:"version"`
+	DefaultLanguage    string                  `json:"default_language"`
+	Editor             EditorPreferences       `json:"editor"`
+	ShareCodeByDefault bool                    `json:"share_code_by_default"` // seeds show_code of the lobbies joined
+	Notifications      NotificationPreferences `json:"notifications,omitempty"`
+	Privacy            PrivacySettings         `json:"privacy"`
+}
+
+func DefaultUserPreferences() *UserPreferences {
+	return &UserPreferences{
+		Version: UserPreferencesVersion,
+		Editor: EditorPreferences{
+			Theme:       EditorThemeSystem,
+			Keybindings: EditorKeybindingsDefault,
+		},
+		Privacy: PrivacySettings{
+			Profile: VisibilityPublic,
+			Matches: VisibilityPublic,
+			Code:    VisibilityPublic,
+		},
+	}
+}
+
+// UserPreferencesSchema is the JSON schema of the current version of UserPreferences
+var UserPreferencesSchema = fmt.Sprintf(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$id": "codeduel/user-preferences/v2",
+	"title": "User preferences",
+	"type": "object",
+	"required": ["version"],
+	"additionalProperties": false,
+	"properties": {
+		"version": { "const": 2 },
+		"default_language": { "type": "string", "maxLength": 50 },
+		"editor": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"theme": { "enum": ["system", "light", "dark"] },
+				"keybindings": { "enum": ["default", "vim", "emacs"] }
+			}
+		},
+		"share_code_by_default": { "type": "boolean" },
+		"notifications": {
+			"type": "object",
+			"propertyNames": { "enum": %s },
+			"additionalProperties": { "type": "boolean" }
+		},
+		"privacy": {
+			"type": "object",
+			"additionalProperties": false,
+			"properties": {
+				"profile": { "$ref": "#/$defs/visibility" },
+				"matches": { "$ref": "#/$defs/visibility" },
+				"code": { "$ref": "#/$defs/visibility" }
+			}
+		}
+	},
+	"$defs": {
+		"visibility": { "enum": ["public", "followers", "private"] }
+	}
+}`, notificationTypesJSON())
+
+func notificationTypesJSON() string {
+	types, _ := json.Marshal(NotificationTypes)
+	return string(types)
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestUserPreferencesSchemaNotificationTypes(t *testing.T) {
+	var schema struct {
+		Properties struct {
+			Notifications struct {
+				PropertyNames struct {
+					Enum []string `json:"enum"`
+				} `json:"propertyNames"`
+			} `json:"notifications"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(UserPreferencesSchema), &schema); err != nil {
+		t.Fatalf("the schema isn't valid JSON: %s", err)
+	}
+	if got := schema.Properties.Notifications.PropertyNames.Enum; !slices.Equal(got, NotificationTypes) {
+		t.Errorf("notification types in the schema = %q, want %q", got, NotificationTypes)
+	}
+}
@@ -0,0 +1,19 @@
+package types
+
+type UserRating struct {
+	Mode       string  `json:"mode"`
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+	Games      int     `json:"games"`
+
+	UpdatedAt string `json:"updated_at"`
+}
+
+type RatingHistoryEntry struct {
+	LobbyUniqueId string  `json:"lobby_id"`
+	Rating        float64 `json:"rating"`
+	Deviation     float64 `json:"deviation"`
+
+	CreatedAt string `json:"created_at"`
+}
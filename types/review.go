@@ -0,0 +1,55 @@
+package types
+
+const (
+	ChallengeStatusDraft     = "draft"
+	ChallengeStatusSubmitted = "submitted" // waiting in the moderators' queue
+	ChallengeStatusApproved  = "approved"  // the author can publish it
+	ChallengeStatusRejected  = "rejected"
+	ChallengeStatusPublished = "published" // playable in lobbies
+)
+
+// challengeStatusTransitions lists the statuses a challenge can move to from each status
+var challengeStatusTransitions = map[string][]string{
+	ChallengeStatusDraft:     {ChallengeStatusSubmitted},
+	ChallengeStatusSubmitted: {ChallengeStatusApproved, ChallengeStatusRejected, ChallengeStatusDraft},
+	ChallengeStatusApproved:  {ChallengeStatusPublished, ChallengeStatusDraft},
+	ChallengeStatusRejected:  {ChallengeStatusSubmitted, ChallengeStatusDraft},
+	ChallengeStatusPublished: {ChallengeStatusDraft},
+}
+
+func IsChallengeStatus(status string) bool {
+	_, ok := challengeStatusTransitions[status]
+	return ok
+}
+
+func CanTransitionChallenge(from, to string) bool {
+	for _, status := range challengeStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsChallengeReviewDecision reports whether moving to the status is a moderator's decision
+func IsChallengeReviewDecision(status string) bool {
+	return status == ChallengeStatusApproved || status == ChallengeStatusRejected
+}
+
+type SetChallengeStatusRequest struct {
+	Status  string `json:"status"`
+	Comment string `json:"comment"` // required when rejecting
+}
+
+// ChallengeReview is a status change of a challenge, with the comment of who made it
+type ChallengeReview struct {
+	Id               int    `json:"id"`
+	ChallengeId      int    `json:"challenge_id"`
+	ReviewerId       int    `json:"reviewer_id"`
+	ReviewerUsername string `json:"reviewer_username"`
+	FromStatus       string `json:"from_status"`
+	ToStatus         string `json:"to_status"`
+	Comment          string `json:"comment"`
+
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,49 @@
+package types
+
+// fields of a challenge tracked by its revisions
+const (
+	RevisionFieldTitle       = "title"
+	RevisionFieldDescription = "description"
+	RevisionFieldContent     = "content"
+	RevisionFieldTests       = "tests"
+	RevisionFieldHiddenTests = "hidden_tests"
+	RevisionFieldTemplates   = "templates"
+)
+
+// ChallengeRevision is an immutable snapshot of a challenge, saved by every change to its statement or tests
+type ChallengeRevision struct {
+	Id          int  `json:"id"`
+	ChallengeId int  `json:"challenge_id"`
+	Revision    int  `json:"revision"` // 1 for the creation, incremented by every change
+	AuthorId    int  `json:"author_id"`
+	RollbackOf  *int `json:"rollback_of"` // revision restored by this one
+
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Content     string `json:"content"`
+
+	TestCases       []TestCase `json:"testCases"`
+	HiddenTestCases []TestCase `json:"hiddenTestCases,omitempty"`
+
+	Templates []ChallengeTemplate `json:"templates"` // sorted by language
+
+	Diff []RevisionChange `json:"diff"` // changes from the previous revision
+
+	CreatedAt string `json:"created_at"`
+}
+
+// RevisionChange is the unified diff of a single field, tests are compared as indented JSON
+type RevisionChange struct {
+	Field string   `json:"field"`
+	Lines []string `json:"lines"`
+}
+
+type ChallengeRevisionSummary struct {
+	Id             int      `json:"id"`
+	Revision       int      `json:"revision"`
+	AuthorId       int      `json:"author_id"`
+	AuthorUsername string   `json:"author_username"`
+	RollbackOf     *int     `json:"rollback_of"`
+	ChangedFields  []string `json:"changed_fields"`
+	CreatedAt      string   `json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package types
+
+const (
+	SearchBackendMariaDB = "mariadb"
+	SearchBackendMemory  = "memory"
+)
+
+// searchable fields of a challenge
+const (
+	SearchFieldTitle       = "title"
+	SearchFieldDescription = "description"
+	SearchFieldContent     = "content"
+)
+
+type ChallengeSearchQuery struct {
+	Query  string
+	Filter ChallengeFilter
+	Limit  int
+}
+
+// ChallengeSearchResult is a challenge matching the query, the best matches first
+type ChallengeSearchResult struct {
+	Id                 int      `json:"id"`
+	OwnerId            int      `json:"owner_id"`
+	Title              string   `json:"title"`
+	Description        string   `json:"description"`
+	Difficulty         string   `json:"difficulty"`
+	ComputedDifficulty *string  `json:"computed_difficulty"`
+	Tags               []string `json:"tags"`
+
+	Score    float64         `json:"score"`
+	Snippets []SearchSnippet `json:"snippets"` // only the fields containing the query terms
+}
+
+// SearchSnippet is an excerpt of a field around the first match, the text is plain and must be escaped
+// before rendering it
+type SearchSnippet struct {
+	Field      string      `json:"field"` // title, description or content
+	Text       string      `json:"text"`
+	Highlights []TextRange `json:"highlights"`
+}
+
+// TextRange is a range of characters (unicode code points) of a text, end excluded
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type ChallengeSearchResponse struct {
+	Results []*ChallengeSearchResult `json:"results"`
+}
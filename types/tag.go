@@ -0,0 +1,18 @@
+package types
+
+// Tag is a topic of the challenges (arrays, graphs, dp...), the catalogue is managed by the moderators
+type Tag struct {
+	Id             int    `json:"id"`
+	Slug           string `json:"slug"`
+	Name           string `json:"name"`
+	ChallengeCount int    `json:"challenge_count"`
+}
+
+type TagRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type SetChallengeTagsRequest struct {
+	Tags []string `json:"tags"` // slugs, replacing the current tags
+}
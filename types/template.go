@@ -0,0 +1,36 @@
+package types
+
+import "fmt"
+
+const (
+	MaxTemplateSignatureLength = 255
+	MaxTemplateStarterCodeSize = 16 << 10 // bytes
+)
+
+// ChallengeTemplate is what the players of a language start from, so every language writes the same amount of boilerplate.
+// Templates are part of the revisions, a lobby pinned to a revision gets the templates of that revision.
+type ChallengeTemplate struct {
+	Language    string `json:"language"`     // Language.Name
+	Signature   string `json:"signature"`    // canonical signature of the function to implement
+	StarterCode string `json:"starter_code"` // code in the editor when the match starts
+	UpdatedAt   string `json:"updated_at,omitempty"`
+}
+
+type ChallengeTemplateRequest struct {
+	Signature   string `json:"signature"`
+	StarterCode string `json:"starter_code"`
+}
+
+// ValidateChallengeTemplate checks a template against the limits, an empty one is refused
+func ValidateChallengeTemplate(template *ChallengeTemplate) error {
+	if template.Signature == "" && template.StarterCode == "" {
+		return fmt.Errorf("%s template: signature or starter code required", template.Language)
+	}
+	if len(template.Signature) > MaxTemplateSignatureLength {
+		return fmt.Errorf("%s template: signature can't be longer than %d characters", template.Language, MaxTemplateSignatureLength)
+	}
+	if len(template.StarterCode) > MaxTemplateStarterCodeSize {
+		return fmt.Errorf("%s template: starter code can't be larger than %d bytes", template.Language, MaxTemplateStarterCodeSize)
+	}
+	return nil
+}
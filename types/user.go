@@ -1,5 +1,20 @@
 package types
 
+const (
+	UserRoleAdmin     = "admin"
+	UserRoleModerator = "moderator"
+	UserRoleDeleted   = "deleted"
+
+	// TombstoneUsername is the user inheriting the challenges and lobbies of deleted accounts,
+	// the brackets make it impossible to clash with a GitHub login
+	TombstoneUsername = "[deleted]"
+)
+
+// IsModeratorRole reports whether the role can moderate the challenges, admins included
+func IsModeratorRole(role string) bool {
+	return role == UserRoleModerator || role == UserRoleAdmin
+}
+
 type CreateUserRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
@@ -23,6 +38,7 @@ type User struct {
 	Name          string `json:"name"`
 	Username      string `json:"username"`
 	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 	Avatar        string `json:"avatar"`
 	BackgroundImg string `json:"background_img"`
 	Bio           string `json:"bio"`
@@ -33,10 +49,10 @@ type User struct {
 }
 
 type UserStats struct {
-	Id      int    `json:"id"`
-	UserId  int    `json:"user_id"`
-	StatsId int    `json:"stats_id"`
-	Stat    string `json:"stat"`
+	Id      int `json:"id"`
+	UserId  int `json:"user_id"`
+	StatsId int `json:"stats_id"`
+	Stat    int `json:"stat"`
 
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
@@ -45,12 +61,18 @@ type UserStats struct {
 type UserStatsParsed struct {
 	Id   int    `json:"id"`
 	Name string `json:"name"`
-	Stat string `json:"stat"`
+	Stat int    `json:"stat"`
 
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
 
+const (
+	StatGames = "Games"
+	StatWins  = "Wins"
+	StatTop3  = "Top 3"
+)
+
 type Stats struct {
 	Id   int    `json:"id"`
 	Name string `json:"name"`
@@ -58,19 +80,77 @@ type Stats struct {
 
 type ProfileResponse struct {
 	*User
-	Stats []*UserStatsParsed `json:"stats"`
+	Stats          []*UserStatsParsed `json:"stats"`
+	FollowersCount int                `json:"followers_count"`
+	FollowingCount int                `json:"following_count"`
+	Ratings        []*UserRating      `json:"ratings"`
+	Achievements   []*UserAchievement `json:"achievements"`
+	CurrentStreak  int                `json:"current_streak"`
+	LongestStreak  int                `json:"longest_streak"`
+	Rivals         []*Rival           `json:"rivals,omitempty"` // only if the match history is visible, see /v1/user/{username}/versus/{opponent}
+
+	// only in the profile of the authenticated user
+	UnreadNotifications *int `json:"unread_notifications,omitempty"`
+}
+
+type FollowCounts struct {
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+}
+
+type FriendPlaying struct {
+	User          UserResponse `json:"user"`
+	LobbyUniqueId string       `json:"lobby_id"`
+	Mode          string       `json:"mode"`
+	StartedAt     string       `json:"started_at"`
 }
 
 type UserResponse struct {
+	Id            int    `json:"id"`
 	Name          string `json:"name"`
 	Username      string `json:"username"`
 	Avatar        string `json:"avatar"`
 	BackgroundImg string `json:"background_img"`
 	Bio           string `json:"bio"`
 	Role          string `json:"role"`
+	GamesPlayed   int    `json:"games_played"`
 	CreatedAt     string `json:"created_at"`
 }
 
+const (
+	UserSortNewest = "newest"
+	UserSortGames  = "games"
+	UserSortRating = "rating"
+)
+
+type UserListQuery struct {
+	ViewerId int // authenticated user performing the search, 0 if anonymous
+	Search   string
+	Sort     string
+	Mode     string // game mode of the rating, when sorting by rating
+	Cursor   string
+	Limit    int
+}
+
+type UserListResponse struct {
+	Users      []*UserResponse `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type DeleteAccountConfirmation struct {
+	ConfirmationToken string `json:"confirmation_token"`
+	ExpiresAt         int64  `json:"expires_at"`
+}
+
+type DeleteAccountRequest struct {
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+type AccountDeletionPayload struct {
+	UserID  int    `json:"user_id" jwt:"sub"`
+	Purpose string `json:"purpose" jwt:"purpose"`
+}
+
 type RefreshTokenPayload struct {
 	UserID    int   `json:"user_id" jwt:"sub"`
 	ExpiresAt int64 `json:"expires_at" jwt:"exp"`
@@ -0,0 +1,51 @@
+package types
+
+const (
+	VersusWin  = "win"
+	VersusLoss = "loss"
+	VersusTie  = "tie"
+)
+
+// VersusRecord is the record of a user against an opponent, from the point of view of the user
+type VersusRecord struct {
+	Matches                int     `json:"matches"`
+	Wins                   int     `json:"wins"`
+	Losses                 int     `json:"losses"`
+	Ties                   int     `json:"ties"`
+	AvgTestsPassed         float64 `json:"avg_tests_passed"`
+	OpponentAvgTestsPassed float64 `json:"opponent_avg_tests_passed"`
+}
+
+type VersusModeRecord struct {
+	Mode string `json:"mode"`
+	VersusRecord
+}
+
+type VersusMatch struct {
+	LobbyUniqueId string `json:"lobby_id"`
+	Mode          string `json:"mode"`
+	ChallengeId   int    `json:"challenge_id"`
+	PlayedAt      string `json:"played_at"`
+
+	Rank                *int   `json:"rank"`
+	OpponentRank        *int   `json:"opponent_rank"`
+	TestsPassed         int    `json:"tests_passed"`
+	OpponentTestsPassed int    `json:"opponent_tests_passed"`
+	Result              string `json:"result"` // win, loss or tie by rank
+}
+
+type VersusResponse struct {
+	User     *UserResponse       `json:"user"`
+	Opponent *UserResponse       `json:"opponent"`
+	Overall  VersusRecord        `json:"overall"`
+	Modes    []*VersusModeRecord `json:"modes"`
+	Matches  []*VersusMatch      `json:"matches"`
+}
+
+// Rival is an opponent the user played against often
+type Rival struct {
+	User    *UserResponse `json:"user"`
+	Matches int           `json:"matches"`
+	Wins    int           `json:"wins"`
+	Losses  int           `json:"losses"`
+}
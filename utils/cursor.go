@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cursor is the position of the last row of a page when paginating with
+// keyset pagination: Value is the sort key and Id breaks the ties.
+type Cursor struct {
+	Value float64 `json:"v"`
+	Id    int     `json:"id"`
+}
+
+func EncodeCursor(cursor *Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns nil when the cursor is empty (first page)
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(b, cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// ParseLimit parses the `limit` query param, clamping it between 1 and max
+func ParseLimit(s string, def, max int) int {
+	limit := ToInt(s, def)
+	if limit < 1 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// ParseIntList parses a comma separated list of ints (e.g. "1,2,3")
+func ParseIntList(s string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// DailyDateLayout is the layout of the dates identifying the daily challenges
+const DailyDateLayout = time.DateOnly
+
+// DailyDate returns the UTC day `t` falls in, whatever the time zone of `t`
+func DailyDate(t time.Time) string {
+	return t.UTC().Format(DailyDateLayout)
+}
+
+// DailyWindow returns when the daily challenge of `date` starts and ends (exclusive)
+func DailyWindow(date string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(DailyDateLayout, date, time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+// PreviousDailyDate returns the day before `date`
+func PreviousDailyDate(date string) (string, error) {
+	start, _, err := DailyWindow(date)
+	if err != nil {
+		return "", err
+	}
+	return start.AddDate(0, 0, -1).Format(DailyDateLayout), nil
+}
+
+// PickDailyIndex deterministically picks one of `n` candidates for `date`, the same date always gives the same index
+func PickDailyIndex(date string, n int) int {
+	if n <= 0 {
+		return -1
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("daily:" + date))
+	return int(h.Sum64() % uint64(n))
+}
+
+// ExtendStreak returns the streak after a solve of `date`, given the current one and its last solved date (empty if none).
+// The streak goes on if the day before was solved and starts again otherwise, solving a day already counted changes nothing.
+func ExtendStreak(current, longest int, lastDate, date string) (int, int, error) {
+	previousDate, err := PreviousDailyDate(date)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case lastDate != "" && date <= lastDate:
+		return current, longest, nil
+	case lastDate == previousDate:
+		current++
+	default:
+		current = 1
+	}
+	return current, max(longest, current), nil
+}
+
+// CurrentStreak returns the streak as of `today`, a streak whose last solve is older than yesterday is broken
+func CurrentStreak(current int, lastDate, today string) (int, error) {
+	yesterday, err := PreviousDailyDate(today)
+	if err != nil {
+		return 0, err
+	}
+	if lastDate != today && lastDate != yesterday {
+		return 0, nil
+	}
+	return current, nil
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickDailyIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		date string
+		n    int
+	}{
+		{"single candidate", "2024-05-01", 1},
+		{"few candidates", "2024-05-01", 7},
+		{"many candidates", "2024-05-01", 1000},
+		{"leap day", "2024-02-29", 31},
+		{"new year", "2025-01-01", 31},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index := PickDailyIndex(test.date, test.n)
+			if index < 0 || index >= test.n {
+				t.Fatalf("PickDailyIndex(%q, %d) = %d, out of range", test.date, test.n, index)
+			}
+			for i := 0; i < 10; i++ {
+				if again := PickDailyIndex(test.date, test.n); again != index {
+					t.Fatalf("PickDailyIndex(%q, %d) = %d, then %d", test.date, test.n, index, again)
+				}
+			}
+		})
+	}
+
+	for _, n := range []int{0, -1} {
+		if index := PickDailyIndex("2024-05-01", n); index != -1 {
+			t.Errorf("PickDailyIndex with %d candidates = %d, want -1", n, index)
+		}
+	}
+}
+
+func TestPickDailyIndexChangesWithTheDate(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	picked := map[int]bool{}
+	for i := 0; i < 30; i++ {
+		picked[PickDailyIndex(DailyDate(clock.Now()), 100)] = true
+		clock.Advance(24 * time.Hour)
+	}
+	if len(picked) < 2 {
+		t.Fatalf("30 days picked the same challenge out of 100")
+	}
+}
+
+func TestDailyDate(t *testing.T) {
+	ahead := time.FixedZone("UTC+2", 2*60*60)
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{"midday", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), "2024-05-01"},
+		{"midnight", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "2024-05-01"},
+		{"last second", time.Date(2024, 5, 1, 23, 59, 59, 0, time.UTC), "2024-05-01"},
+		{"ahead of UTC", time.Date(2024, 5, 2, 1, 0, 0, 0, ahead), "2024-05-01"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DailyDate(test.time); got != test.want {
+				t.Errorf("DailyDate(%s) = %s, want %s", test.time, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtendStreak(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int
+		longest     int
+		lastDate    string
+		date        string
+		wantCurrent int
+		wantLongest int
+	}{
+		{"first solve", 0, 0, "", "2024-05-01", 1, 1},
+		{"consecutive day", 3, 3, "2024-04-30", "2024-05-01", 4, 4},
+		{"consecutive day under the longest", 2, 5, "2024-04-30", "2024-05-01", 3, 5},
+		{"consecutive day across the year", 1, 1, "2024-12-31", "2025-01-01", 2, 2},
+		{"gap", 4, 4, "2024-04-29", "2024-05-01", 1, 4},
+		{"same day repeat", 2, 3, "2024-05-01", "2024-05-01", 2, 3},
+		{"older day", 2, 3, "2024-05-01", "2024-04-30", 2, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, longest, err := ExtendStreak(test.current, test.longest, test.lastDate, test.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current != test.wantCurrent || longest != test.wantLongest {
+				t.Errorf("ExtendStreak = %d, %d, want %d, %d", current, longest, test.wantCurrent, test.wantLongest)
+			}
+		})
+	}
+
+	if _, _, err := ExtendStreak(0, 0, "", "yesterday"); err == nil {
+		t.Errorf("ExtendStreak accepted an invalid date")
+	}
+}
+
+func TestCurrentStreak(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastDate string
+		today    string
+		want     int
+	}{
+		{"solved today", "2024-05-01", "2024-05-01", 3},
+		{"solved yesterday", "2024-04-30", "2024-05-01", 3},
+		{"gap", "2024-04-29", "2024-05-01", 0},
+		{"never solved", "", "2024-05-01", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, err := CurrentStreak(3, test.lastDate, test.today)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current != test.want {
+				t.Errorf("CurrentStreak = %d, want %d", current, test.want)
+			}
+		})
+	}
+}
+
+// the solves are counted on the UTC day the match ends, as onDailyEvent does
+func TestStreakAcrossMidnight(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 23, 58, 0, 0, time.UTC))
+	current, longest, lastDate := 0, 0, ""
+	solve := func() {
+		t.Helper()
+		date := DailyDate(clock.Now())
+		var err error
+		if current, longest, err = ExtendStreak(current, longest, lastDate, date); err != nil {
+			t.Fatal(err)
+		}
+		lastDate = max(lastDate, date)
+	}
+
+	solve()
+	clock.Advance(time.Minute)
+	solve() // the same day again, before midnight
+	if current != 1 || lastDate != "2024-05-01" {
+		t.Fatalf("before midnight the streak is %d on %s, want 1 on 2024-05-01", current, lastDate)
+	}
+
+	clock.Advance(2 * time.Minute) // a match started before midnight ends after it
+	solve()
+	if current != 2 || lastDate != "2024-05-02" {
+		t.Fatalf("after midnight the streak is %d on %s, want 2 on 2024-05-02", current, lastDate)
+	}
+	if got, _ := CurrentStreak(current, lastDate, DailyDate(clock.Now())); got != 2 {
+		t.Errorf("CurrentStreak right after midnight = %d, want 2", got)
+	}
+
+	clock.Advance(48 * time.Hour)
+	if got, _ := CurrentStreak(current, lastDate, DailyDate(clock.Now())); got != 0 {
+		t.Errorf("CurrentStreak two days later = %d, want 0", got)
+	}
+	solve()
+	if current != 1 || longest != 2 {
+		t.Errorf("after a gap the streak is %d, longest %d, want 1, 2", current, longest)
+	}
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffContextLines is the number of unchanged lines kept around every change
+const DiffContextLines = 3
+
+// maxDiffCells bounds the size of the LCS table, bigger texts are diffed as a whole replacement
+const maxDiffCells = 4_000_000
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// DiffLines returns the hunks of the unified diff between the two texts, without the file headers.
+// Every line is prefixed by ' ', '-' or '+', the result is empty when the texts are equal.
+func DiffLines(before, after string) []string {
+	if before == after {
+		return []string{}
+	}
+
+	return unifiedHunks(diffOps(splitLines(before), splitLines(after)))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffOps aligns the lines on their longest common subsequence, after trimming the common prefix and suffix
+func diffOps(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if (n+1)*(m+1) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the length of the LCS of midA[i:] and midB[j:]
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case midA[i] == midB[j]:
+				ops = append(ops, diffOp{' ', midA[i]})
+				i++
+				j++
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				ops = append(ops, diffOp{'-', midA[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', midB[j]})
+				j++
+			}
+		}
+		for ; i < n; i++ {
+			ops = append(ops, diffOp{'-', midA[i]})
+		}
+		for ; j < m; j++ {
+			ops = append(ops, diffOp{'+', midB[j]})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// unifiedHunks groups the changes closer than twice the context into "@@ -a,b +c,d @@" hunks
+func unifiedHunks(ops []diffOp) []string {
+	// position of every op in the old and new text
+	posA, posB := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	changes := []int{}
+	for i, op := range ops {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if op.kind != '+' {
+			posA[i+1]++
+		}
+		if op.kind != '-' {
+			posB[i+1]++
+		}
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+
+	lines := []string{}
+	for k := 0; k < len(changes); {
+		last := k
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*DiffContextLines {
+			last++
+		}
+		start := max(0, changes[k]-DiffContextLines)
+		end := min(len(ops), changes[last]+DiffContextLines+1)
+
+		countA, countB := posA[end]-posA[start], posB[end]-posB[start]
+		startA, startB := posA[start]+1, posB[start]+1
+		if countA == 0 {
+			startA--
+		}
+		if countB == 0 {
+			startB--
+		}
+		lines = append(lines, fmt.Sprintf("@@ -%d,%d +%d,%d @@", startA, countA, startB, countB))
+		for _, op := range ops[start:end] {
+			lines = append(lines, string(op.kind)+op.line)
+		}
+
+		k = last + 1
+	}
+	return lines
+}
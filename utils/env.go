@@ -45,6 +45,18 @@ type Config struct {
 	JWTRefreshTokenExpiresInMinutes int
 
 	ServiceToken string
+
+	UserExportExpiresInMinutes int
+	LeaderboardRefreshMinutes  int
+
+	SMTPHost                   string
+	SMTPPort                   string
+	SMTPUsername               string
+	SMTPPassword               string
+	MailFrom                   string
+	EmailTokenExpiresInMinutes int
+
+	SearchBackend string
 }
 
 var config *Config
@@ -96,6 +108,18 @@ func LoadConfig() *Config {
 			JWTRefreshTokenExpiresInMinutes: ToInt(GetEnv("JWT_REFRESH_TOKEN_EXPIRES_IN_MINUTES", "43200"), 60*24*30), // 30 days
 
 			ServiceToken: GetEnv("SERVICE_TOKEN", "yeahSuperToken"),
+
+			UserExportExpiresInMinutes: ToInt(GetEnv("USER_EXPORT_EXPIRES_IN_MINUTES", "1440"), 60*24), // 24 hours
+			LeaderboardRefreshMinutes:  ToInt(GetEnv("LEADERBOARD_REFRESH_MINUTES", "5"), 5),
+
+			SMTPHost:                   GetEnv("SMTP_HOST", ""), // emails are only logged when empty
+			SMTPPort:                   GetEnv("SMTP_PORT", "587"),
+			SMTPUsername:               GetEnv("SMTP_USERNAME", ""),
+			SMTPPassword:               GetEnv("SMTP_PASSWORD", ""),
+			MailFrom:                   GetEnv("MAIL_FROM", "CodeDuel <noreply@codeduel.it>"),
+			EmailTokenExpiresInMinutes: ToInt(GetEnv("EMAIL_TOKEN_EXPIRES_IN_MINUTES", "1440"), 60*24), // 24 hours
+
+			SearchBackend: GetEnv("SEARCH_BACKEND", "mariadb"), // mariadb or memory
 		}
 	}
 
@@ -0,0 +1,117 @@
+package utils
+
+import "math"
+
+// Glicko-2 rating system, see http://www.glicko.net/glicko/glicko2.pdf
+
+const (
+	Glicko2DefaultRating     = 1500.0
+	Glicko2DefaultDeviation  = 350.0
+	Glicko2DefaultVolatility = 0.06
+
+	glicko2Scale     = 173.7178
+	glicko2Tau       = 0.5 // constrains the volatility change over time
+	glicko2Tolerance = 0.000001
+)
+
+type Glicko2Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+// Glicko2Result is the outcome of a game against an opponent: 1 win, 0.5 draw, 0 loss
+type Glicko2Result struct {
+	Opponent Glicko2Rating
+	Score    float64
+}
+
+func NewGlicko2Rating() Glicko2Rating {
+	return Glicko2Rating{
+		Rating:     Glicko2DefaultRating,
+		Deviation:  Glicko2DefaultDeviation,
+		Volatility: Glicko2DefaultVolatility,
+	}
+}
+
+// UpdateGlicko2 returns the rating of the player after a rating period with the given results
+func UpdateGlicko2(player Glicko2Rating, results []Glicko2Result) Glicko2Rating {
+	mu := (player.Rating - Glicko2DefaultRating) / glicko2Scale
+	phi := player.Deviation / glicko2Scale
+	sigma := player.Volatility
+
+	// a player that didn't play only gets their deviation increased
+	if len(results) == 0 {
+		return Glicko2Rating{
+			Rating:     player.Rating,
+			Deviation:  math.Min(math.Sqrt(phi*phi+sigma*sigma)*glicko2Scale, Glicko2DefaultDeviation),
+			Volatility: sigma,
+		}
+	}
+
+	var vInverse, deltaSum float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - Glicko2DefaultRating) / glicko2Scale
+		phiJ := result.Opponent.Deviation / glicko2Scale
+
+		g := glicko2G(phiJ)
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+
+		vInverse += g * g * e * (1 - e)
+		deltaSum += g * (result.Score - e)
+	}
+	v := 1 / vInverse
+	delta := v * deltaSum
+
+	newSigma := glicko2Volatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + newSigma*newSigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*deltaSum
+
+	return Glicko2Rating{
+		Rating:     newMu*glicko2Scale + Glicko2DefaultRating,
+		Deviation:  newPhi * glicko2Scale,
+		Volatility: newSigma,
+	}
+}
+
+func glicko2G(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// glicko2Volatility finds the new volatility with the Illinois algorithm (step 5 of the paper)
+func glicko2Volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glicko2Tau*glicko2Tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glicko2Tau) < 0 {
+			k++
+		}
+		B = a - k*glicko2Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glicko2Tolerance {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA = fA / 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
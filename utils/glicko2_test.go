@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func assertNear(t *testing.T, name string, got, want, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("%s = %f, want %f ± %g", name, got, want, tolerance)
+	}
+}
+
+func TestUpdateGlicko2(t *testing.T) {
+	// the example of section 3 of Glickman's paper
+	paperPlayer := Glicko2Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	paperResults := []Glicko2Result{
+		{Opponent: Glicko2Rating{Rating: 1400, Deviation: 30, Volatility: 0.06}, Score: 1},
+		{Opponent: Glicko2Rating{Rating: 1550, Deviation: 100, Volatility: 0.06}, Score: 0},
+		{Opponent: Glicko2Rating{Rating: 1700, Deviation: 300, Volatility: 0.06}, Score: 0},
+	}
+
+	tests := []struct {
+		name    string
+		player  Glicko2Rating
+		results []Glicko2Result
+		want    Glicko2Rating
+	}{
+		{"paper example", paperPlayer, paperResults, Glicko2Rating{Rating: 1464.06, Deviation: 151.52, Volatility: 0.05999}},
+		{"no games", paperPlayer, nil, Glicko2Rating{Rating: 1500, Deviation: 200.2714, Volatility: 0.06}},
+		{"no games at the default deviation", NewGlicko2Rating(), nil, NewGlicko2Rating()},
+		{"draw between equals", NewGlicko2Rating(), []Glicko2Result{{Opponent: NewGlicko2Rating(), Score: 0.5}}, Glicko2Rating{Rating: 1500, Deviation: 290.32, Volatility: 0.06}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := UpdateGlicko2(test.player, test.results)
+			assertNear(t, "rating", got.Rating, test.want.Rating, 0.01)
+			assertNear(t, "deviation", got.Deviation, test.want.Deviation, 0.01)
+			assertNear(t, "volatility", got.Volatility, test.want.Volatility, 0.00001)
+		})
+	}
+}
+
+func TestUpdateGlicko2Direction(t *testing.T) {
+	opponent := NewGlicko2Rating()
+	tests := []struct {
+		name  string
+		score float64
+		gains bool
+	}{
+		{"win", 1, true},
+		{"loss", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := UpdateGlicko2(NewGlicko2Rating(), []Glicko2Result{{Opponent: opponent, Score: test.score}})
+			if (got.Rating > Glicko2DefaultRating) != test.gains {
+				t.Errorf("rating after a %s = %f", test.name, got.Rating)
+			}
+			if got.Deviation >= Glicko2DefaultDeviation {
+				t.Errorf("deviation after a game = %f, want it to shrink", got.Deviation)
+			}
+		})
+	}
+}
+
+func TestGlicko2VolatilityConverges(t *testing.T) {
+	// the paper's values, then a surprise larger than the expected variance (B = ln(Δ² - φ² - v))
+	// and a small one (B found by stepping down from a)
+	tests := []struct {
+		name                 string
+		phi, sigma, v, delta float64
+		want                 float64
+	}{
+		{"paper example", 1.1513, 0.06, 1.7785, -0.4834, 0.05999},
+		{"large surprise", 0.1, 0.06, 0.5, 3, 0},
+		{"small surprise", 1.5, 0.06, 2, 0.01, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := glicko2Volatility(test.phi, test.sigma, test.v, test.delta)
+			if math.IsNaN(got) || math.IsInf(got, 0) || got <= 0 {
+				t.Fatalf("glicko2Volatility = %f", got)
+			}
+			if test.want != 0 {
+				assertNear(t, "volatility", got, test.want, 0.00001)
+			}
+
+			// the result is the root of f of step 5
+			a := math.Log(test.sigma * test.sigma)
+			x := math.Log(got * got)
+			ex := math.Exp(x)
+			d := test.phi*test.phi + test.v + ex
+			f := ex*(test.delta*test.delta-test.phi*test.phi-test.v-ex)/(2*d*d) - (x-a)/(glicko2Tau*glicko2Tau)
+			assertNear(t, "f(ln σ'²)", f, 0, 0.0001)
+		})
+	}
+
+	if got := glicko2Volatility(0.1, 0.06, 0.5, 3); got <= 0.06 {
+		t.Errorf("volatility after a large surprise = %f, want it to grow", got)
+	}
+}
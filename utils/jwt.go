@@ -171,3 +171,14 @@ func GenerateAccessToken(user *types.User) (*JWT, error) {
 		"role":     user.Role,
 	})
 }
+
+const AccountDeletionPurpose = "delete_account"
+
+// GenerateAccountDeletionToken creates the short-lived token confirming an account deletion
+func GenerateAccountDeletionToken(userId int) (*JWT, error) {
+	return CreateJWT(&jwt.MapClaims{
+		"sub":     userId,
+		"purpose": AccountDeletionPurpose,
+		"exp":     time.Now().Add(time.Minute * 10).Unix(),
+	})
+}
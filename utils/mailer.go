@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends the transactional emails (verification links, ...)
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer returns an SMTP mailer when SMTP_HOST is set, otherwise a mailer that only logs the emails
+func NewMailer(config *Config) Mailer {
+	if config.SMTPHost == "" {
+		return LogMailer{}
+	}
+
+	return &SMTPMailer{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+		From:     config.MailFrom,
+	}
+}
+
+// LogMailer prints the emails instead of sending them, for development
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("%s to: %s, subject: %s\n%s", GetLogTag("mail"), to, subject, body)
+	return nil
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+	// From may have a display name, the envelope only takes the address
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %s", m.From, err.Error())
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, from.Address, []string{to}, []byte(message))
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minSearchTermLength drops the terms too short to be indexed
+const minSearchTermLength = 2
+
+// SearchTerms splits the text in lowercase words, the order and duplicates are kept
+func SearchTerms(text string) []string {
+	terms := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isNotWordRune) {
+		if len([]rune(word)) >= minSearchTermLength {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Snippet cuts about `width` characters of the text around the first word matching one of the terms,
+// returning the excerpt and the ranges of the matching words in it. `ok` is false when nothing matches.
+func Snippet(text string, terms []string, width int) (snippet string, highlights [][2]int, ok bool) {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		wanted[strings.ToLower(term)] = true
+	}
+
+	runes := []rune(text)
+	matches := [][2]int{}
+	for start := 0; start < len(runes); {
+		if isNotWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && !isNotWordRune(runes[end]) {
+			end++
+		}
+		if wanted[strings.ToLower(string(runes[start:end]))] {
+			matches = append(matches, [2]int{start, end})
+		}
+		start = end
+	}
+	if len(matches) == 0 {
+		return "", nil, false
+	}
+
+	from := max(0, matches[0][0]-width/4)
+	to := min(len(runes), from+width)
+	from = max(0, min(from, to-width))
+
+	prefix, suffix := "", ""
+	if from > 0 {
+		prefix = "…"
+	}
+	if to < len(runes) {
+		suffix = "…"
+	}
+	offset := len([]rune(prefix)) - from
+
+	excerpt := make([]rune, 0, to-from)
+	for _, r := range runes[from:to] {
+		if unicode.IsSpace(r) {
+			r = ' '
+		}
+		excerpt = append(excerpt, r)
+	}
+
+	highlights = [][2]int{}
+	for _, match := range matches {
+		if match[0] >= from && match[1] <= to {
+			highlights = append(highlights, [2]int{match[0] + offset, match[1] + offset})
+		}
+	}
+
+	return prefix + string(excerpt) + suffix, highlights, true
+}
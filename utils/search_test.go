@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"lowercase words", "Two Sum", []string{"two", "sum"}},
+		{"punctuation", "sum, of-two (numbers)!", []string{"sum", "of", "two", "numbers"}},
+		{"one letter words are dropped", "a b cd e", []string{"cd"}},
+		{"digits", "x 42 7", []string{"42"}},
+		{"duplicates are kept", "sum sum Sum", []string{"sum", "sum", "sum"}},
+		{"unicode letters", "Città è bella", []string{"città", "bella"}},
+		{"empty", "  ", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SearchTerms(test.text); !slices.Equal(got, test.want) {
+				t.Errorf("SearchTerms(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 40) + "target " + strings.Repeat("more ", 40)
+	tests := []struct {
+		name       string
+		text       string
+		terms      []string
+		width      int
+		wantOk     bool
+		wantPrefix bool
+		wantSuffix bool
+		wantWords  []string // the highlighted words, in order
+	}{
+		{"no match", "find the sum", []string{"product"}, 40, false, false, false, nil},
+		{"partial words don't match", "summary", []string{"sum"}, 40, false, false, false, nil},
+		{"whole text", "find the sum of two", []string{"sum"}, 40, true, false, false, []string{"sum"}},
+		{"case insensitive", "Sum of SUM", []string{"sum"}, 40, true, false, false, []string{"Sum", "SUM"}},
+		{"every term", "the sum of two numbers", []string{"sum", "numbers"}, 40, true, false, false, []string{"sum", "numbers"}},
+		{"cut on both sides", long, []string{"target"}, 40, true, true, true, []string{"target"}},
+		{"match past the width is not highlighted", "sum " + strings.Repeat("x", 50) + " sum", []string{"sum"}, 20, true, false, true, []string{"sum"}},
+		{"unicode", "è la città più bella", []string{"città"}, 40, true, false, false, []string{"città"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snippet, highlights, ok := Snippet(test.text, test.terms, test.width)
+			if ok != test.wantOk {
+				t.Fatalf("Snippet ok = %v, want %v", ok, test.wantOk)
+			}
+			if !ok {
+				return
+			}
+
+			if strings.HasPrefix(snippet, "…") != test.wantPrefix || strings.HasSuffix(snippet, "…") != test.wantSuffix {
+				t.Errorf("Snippet = %q, want prefix %v and suffix %v", snippet, test.wantPrefix, test.wantSuffix)
+			}
+			runes := []rune(snippet)
+			words := []string{}
+			for _, highlight := range highlights {
+				if highlight[0] < 0 || highlight[1] > len(runes) || highlight[0] >= highlight[1] {
+					t.Fatalf("highlight %v out of the %d characters of %q", highlight, len(runes), snippet)
+				}
+				words = append(words, string(runes[highlight[0]:highlight[1]]))
+			}
+			if !slices.Equal(words, test.wantWords) {
+				t.Errorf("highlighted %q, want %q", words, test.wantWords)
+			}
+		})
+	}
+}
+
+func TestSnippetReplacesNewlines(t *testing.T) {
+	snippet, _, _ := Snippet("first line\nsum\tsecond", []string{"sum"}, 40)
+	if snippet != "first line sum second" {
+		t.Errorf("Snippet = %q, want the whitespace replaced by spaces", snippet)
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"strconv"
 	"strings"
 )
@@ -37,3 +38,10 @@ func GenerateRandomString(n int) string {
 	rand.Read(b)
 	return base64.StdEncoding.EncodeToString(b)
 }
+
+// GenerateRandomToken returns a random hex string of n bytes, safe to use in URLs
+func GenerateRandomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}